@@ -0,0 +1,37 @@
+// Command config prints the effective configuration of the service, with
+// secrets masked, after applying the defaults, the config file, the
+// environment and the flags it is given, e.g.
+//
+//	go run ./cmd/config
+//	go run ./cmd/config --config config.yaml --server-address :9090
+//
+// It exits with status 1, after listing the problems, when the
+// configuration is invalid.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jordanlanch/stori-test/internal/config"
+)
+
+func main() {
+	env, err := config.Load(".env", os.Args[1:])
+	if errors.Is(err, config.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := env.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := env.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jordanlanch/stori-test/internal/app"
+	"github.com/jordanlanch/stori-test/internal/config"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
+	csvreader "github.com/jordanlanch/stori-test/internal/interface/csvreader"
+)
+
+// runNow stands in for the job queue: the CLI runs the job it submits
+// itself, right away.
+type runNow struct{}
+
+func (runNow) Enqueue(ctx context.Context, jobID string) error { return nil }
+
+func runIngest(ctx context.Context, args []string) error {
+	fs := newFlags("ingest", "ingest [flags] FILE",
+		"Ingests the transactions of the CSV file FILE for an account and sends its statement,\nrecording the run as a processing job like those submitted through the API.")
+	accountID := fs.String("account", "", "account the transactions belong to (default DEFAULT_ACCOUNT_ID)")
+	period := fs.String("period", "", "restrict the statement to this month (YYYY-MM)")
+	format := fs.formatFlag("table", "json")
+	if err := fs.parse(args, 1); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "table", "json"); err != nil {
+		return err
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+	file := fs.Arg(0)
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+
+	db, err := app.OpenDatabase(env)
+	if err != nil {
+		return err
+	}
+	redisClient := app.NewRedisClient(env)
+	defer redisClient.Close()
+	emailService, err := app.NewEmailService(env)
+	if err != nil {
+		return err
+	}
+
+	transactionUseCase := app.NewTransactionUseCase(env, db, redisClient, app.NewCache(env, redisClient), emailService, file)
+	jobUseCase := usecase.NewJobUseCase(repository.NewDBJobRepository(db), runNow{}, transactionUseCase, env.JobTimeoutSec, 1, env.DefaultAccountID)
+
+	job, err := jobUseCase.SubmitProcessing(ctx, domain.ProcessRequest{AccountID: *accountID, Period: *period})
+	if err != nil {
+		return err
+	}
+	// The outcome is recorded in the job.
+	_ = jobUseCase.RunJob(ctx, job.ID)
+	if job, err = jobUseCase.GetJob(ctx, job.ID); err != nil {
+		return err
+	}
+	if err := writeJobs(os.Stdout, *format, []domain.Job{*job}); err != nil {
+		return err
+	}
+	if job.State != domain.JobStateSucceeded {
+		return fmt.Errorf("import %s %s: %s", job.ID, job.State, job.Error)
+	}
+	return nil
+}
+
+func runSummary(ctx context.Context, args []string) error {
+	fs := newFlags("summary", "summary [flags]", "Prints the summary of the transactions stored for an account.")
+	accountID := fs.String("account", "", "account to summarise (default DEFAULT_ACCOUNT_ID)")
+	period := fs.String("period", "", "only summarise this month (YYYY-MM)")
+	format := fs.formatFlag("table", "json")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "table", "json"); err != nil {
+		return err
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+	if *accountID == "" {
+		*accountID = env.DefaultAccountID
+	}
+
+	db, err := app.OpenDatabase(env)
+	if err != nil {
+		return err
+	}
+	accountUseCase := usecase.NewAccountUseCase(repository.NewDBTransactionRepository(db, env.CSVFilePath))
+	summary, err := accountUseCase.GetSummary(ctx, *accountID, *period)
+	if err != nil {
+		return err
+	}
+	return writeSummary(os.Stdout, *format, summary)
+}
+
+func runStatement(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "render":
+			return runStatementRender(ctx, args[1:])
+		case "send":
+			return runStatementSend(ctx, args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: stori statement render|send [flags]")
+	return fmt.Errorf("%w: expected render or send", errUsage)
+}
+
+func runStatementRender(ctx context.Context, args []string) error {
+	fs := newFlags("statement render", "statement render [flags]",
+		"Renders the statement of a CSV file or of the transactions stored for an account, without sending it.")
+	csvPath := fs.String("csv", "", "CSV file to render the statement for")
+	accountID := fs.String("account", "", "account whose stored transactions are rendered (default DEFAULT_ACCOUNT_ID)")
+	format := fs.formatFlag("html", "text", "json")
+	out := fs.String("out", "", "write the statement to this file instead of stdout")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "html", "text", "json"); err != nil {
+		return err
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+	if *csvPath != "" && *accountID != "" {
+		return fmt.Errorf("%w: -csv and -account are exclusive", errUsage)
+	}
+
+	emailService, err := app.NewEmailService(env)
+	if err != nil {
+		return err
+	}
+	req := domain.PreviewRequest{AccountID: *accountID}
+	var dbRepo usecase.TransactionRepository
+	if *csvPath != "" {
+		if req.Transactions, err = csvreader.NewCSVReader(*csvPath).ReadTransactions(); err != nil {
+			return fmt.Errorf("reading %s: %w", *csvPath, err)
+		}
+	} else {
+		db, err := app.OpenDatabase(env)
+		if err != nil {
+			return err
+		}
+		dbRepo = repository.NewDBTransactionRepository(db, env.CSVFilePath)
+	}
+
+	statementUseCase := usecase.NewStatementUseCase(dbRepo, nil, emailService, env.DefaultAccountID)
+	rendered, err := statementUseCase.PreviewStatement(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	switch *format {
+	case "text":
+		_, err = fmt.Fprint(w, rendered.Text)
+	case "json":
+		err = writeJSON(w, rendered)
+	default:
+		_, err = fmt.Fprint(w, rendered.HTML)
+	}
+	return err
+}
+
+func runStatementSend(ctx context.Context, args []string) error {
+	fs := newFlags("statement send", "statement send [flags]",
+		"Sends the statement of the transactions stored for an account, archived like those sent on ingestion.")
+	accountID := fs.String("account", "", "account whose statement is sent (default DEFAULT_ACCOUNT_ID)")
+	period := fs.String("period", "", "only include this month (YYYY-MM); the statement is filed under the current month otherwise")
+	format := fs.formatFlag("table", "json")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "table", "json"); err != nil {
+		return err
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+
+	statementUseCase, err := newStatementUseCase(env)
+	if err != nil {
+		return err
+	}
+	statement, sendErr := statementUseCase.SendStatement(ctx, *accountID, *period)
+	if statement != nil && statement.ID != 0 {
+		if err := writeStatements(os.Stdout, *format, []domain.Statement{*statement}); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+func runMigrate(ctx context.Context, args []string) error {
+	fs := newFlags("migrate", "migrate [flags] up|down|reset|status",
+		"Applies every pending migration (up), rolls back the last one (down) or all of them (reset) or lists the pending ones (status).\nThe migrations are embedded in the binary.")
+	format := fs.formatFlag("table", "json")
+	if err := fs.parse(args, 1); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "table", "json"); err != nil {
+		return err
+	}
+	switch fs.Arg(0) {
+	case "up", "down", "reset", "status":
+	default:
+		return fmt.Errorf("%w: unknown migration command %q", errUsage, fs.Arg(0))
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+
+	db, err := app.OpenDatabase(env)
+	if err != nil {
+		return err
+	}
+	migrator, err := app.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	switch fs.Arg(0) {
+	case "up":
+		if _, err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	case "down":
+		if _, err := migrator.Down(ctx); err != nil {
+			return err
+		}
+	case "reset":
+		if _, err := migrator.Reset(ctx); err != nil {
+			return err
+		}
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return writeMigrationStatus(os.Stdout, *format, status)
+}
+
+func runImports(ctx context.Context, args []string) error {
+	fs := newFlags("imports", "imports [flags]", "Lists the imports, i.e. the processing jobs, newest first.")
+	accountID := fs.String("account", "", "only list the imports of this account")
+	state := fs.String("state", "", "only list the imports in this state: queued, running, retrying, succeeded or failed")
+	limit := fs.Int("limit", 50, "maximum number of imports listed")
+	format := fs.formatFlag("table", "json")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "table", "json"); err != nil {
+		return err
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+
+	db, err := app.OpenDatabase(env)
+	if err != nil {
+		return err
+	}
+	jobUseCase := usecase.NewJobUseCase(repository.NewDBJobRepository(db), nil, nil, env.JobTimeoutSec, 1, env.DefaultAccountID)
+	jobs, err := jobUseCase.ListJobs(ctx, domain.JobFilter{AccountID: *accountID, State: *state, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return writeJobs(os.Stdout, *format, jobs)
+}
+
+func runRedrive(ctx context.Context, args []string) error {
+	fs := newFlags("redrive", "redrive [flags]",
+		"Resends, oldest first, the statements whose delivery failed and that have not been resent yet.\nIt exits with status 1 if any of them fails again.")
+	accountID := fs.String("account", "", "only resend the statements of this account")
+	sinceAgo := fs.Duration("since", 0, "only resend the statements created within this duration, e.g. 24h (default all)")
+	limit := fs.Int("limit", 50, "maximum number of statements resent")
+	dryRun := fs.Bool("dry-run", false, "list the statements that would be resent without sending anything")
+	format := fs.formatFlag("table", "json")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "table", "json"); err != nil {
+		return err
+	}
+	env, err := fs.env()
+	if err != nil {
+		return err
+	}
+
+	statementUseCase, err := newStatementUseCase(env)
+	if err != nil {
+		return err
+	}
+	filter := domain.StatementFilter{AccountID: *accountID, Since: since(*sinceAgo), Limit: *limit}
+	if *dryRun {
+		filter.Status = domain.StatementStatusFailed
+		filter.NotResent = true
+		failed, err := statementUseCase.ListStatements(ctx, filter)
+		if err != nil {
+			return err
+		}
+		return writeStatements(os.Stdout, *format, failed)
+	}
+
+	resent, redriveErr := statementUseCase.RedriveFailed(ctx, filter)
+	if err := writeStatements(os.Stdout, *format, resent); err != nil {
+		return err
+	}
+	if redriveErr != nil {
+		return redriveErr
+	}
+	failed := 0
+	for _, statement := range resent {
+		if statement.Status == domain.StatementStatusFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d statements failed again", failed, len(resent))
+	}
+	return nil
+}
+
+func newStatementUseCase(env *config.Env) (usecase.StatementUseCase, error) {
+	db, err := app.OpenDatabase(env)
+	if err != nil {
+		return nil, err
+	}
+	emailService, err := app.NewEmailService(env)
+	if err != nil {
+		return nil, err
+	}
+	return app.NewStatementUseCase(env, db, emailService), nil
+}
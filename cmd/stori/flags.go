@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/config"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/logging"
+)
+
+// commandFlags are the flags of a command, along with those every command
+// takes.
+type commandFlags struct {
+	*flag.FlagSet
+	config string
+}
+
+// newFlags returns the flags of the command name, invoked as described by
+// usage, e.g. "ingest [flags] FILE".
+func newFlags(name, usage, description string) *commandFlags {
+	fs := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	fs.StringVar(&fs.config, "config", "", "config file (default CONFIG_FILE or .env)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: stori %s\n\n%s\n\nFlags:\n", usage, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args, expecting nargs positional arguments.
+func (fs *commandFlags) parse(args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return fmt.Errorf("%w: expected %d arguments, got %d", errUsage, nargs, fs.NArg())
+	}
+	return nil
+}
+
+// env loads the configuration and sets up logging to stderr, leaving stdout
+// to the results.
+func (fs *commandFlags) env() (*config.Env, error) {
+	var env *config.Env
+	var err error
+	if fs.config != "" {
+		env, err = config.Load("", []string{"--config", fs.config})
+	} else {
+		env, err = config.Load(".env", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading the configuration: %w", err)
+	}
+	if err := env.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	logger, err := logging.New(os.Stderr, logging.Config{Level: env.LogLevel, Format: env.LogFormat, RedactPII: env.LogRedactPII})
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return env, nil
+}
+
+// formatFlag adds the -format flag choosing between the formats, the
+// first being the default.
+func (fs *commandFlags) formatFlag(formats ...string) *string {
+	return fs.String("format", formats[0], fmt.Sprintf("output format: %v", formats))
+}
+
+// checkFormat returns a usage error unless format is one of formats.
+func checkFormat(format string, formats ...string) error {
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: -format must be one of %v", errUsage, formats)
+}
+
+// since returns the time d ago, or nil when d is zero.
+func since(d time.Duration) *time.Time {
+	if d <= 0 {
+		return nil
+	}
+	t := time.Now().Add(-d)
+	return &t
+}
@@ -0,0 +1,88 @@
+// Command stori runs the operations of the service from the command line,
+// with the same configuration, use cases and repositories as the server,
+// e.g.
+//
+//	go run ./cmd/stori ingest -account acc-1 transactions.csv
+//	go run ./cmd/stori summary -account acc-1 -period 2024-07 -format json
+//	go run ./cmd/stori statement render -csv transactions.csv -format text
+//	go run ./cmd/stori statement send -account acc-1 -period 2024-07
+//	go run ./cmd/stori migrate up
+//	go run ./cmd/stori imports -state failed
+//	go run ./cmd/stori redrive -since 24h -dry-run
+//
+// Every command takes a -config flag naming the config file, which is
+// otherwise found as by the server. Logs are written to stderr, results to
+// stdout. It exits with status 1 when a command fails and 2 on usage errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// errUsage marks errors caused by how a command was invoked.
+var errUsage = errors.New("usage error")
+
+// commands are the subcommands of stori, each given the arguments that
+// follow its name.
+var commands = map[string]struct {
+	run     func(ctx context.Context, args []string) error
+	summary string
+}{
+	"ingest":    {runIngest, "ingest a transactions file and send its statement"},
+	"summary":   {runSummary, "print the summary of an account's transactions"},
+	"statement": {runStatement, "render or send a statement"},
+	"migrate":   {runMigrate, "apply, roll back or list the database migrations"},
+	"imports":   {runImports, "list the imports, i.e. the processing jobs"},
+	"redrive":   {runRedrive, "resend the statements whose delivery failed"},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+			usage()
+			return
+		}
+		fmt.Fprintf(os.Stderr, "stori: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := cmd.run(ctx, os.Args[2:])
+	stop()
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(os.Stderr, "stori %s: %v\n", os.Args[1], err)
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "stori %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: stori <command> [flags] [arguments]\n\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun stori <command> -h for the flags of a command.")
+}
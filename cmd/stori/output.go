@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeTable writes rows under header as aligned columns.
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeJobs(w io.Writer, format string, jobs []domain.Job) error {
+	if format == "json" {
+		return writeJSON(w, jobs)
+	}
+	rows := make([][]string, 0, len(jobs))
+	for _, job := range jobs {
+		rows = append(rows, []string{
+			job.ID, job.AccountID, job.Period, job.State,
+			strconv.Itoa(job.RowsRead), strconv.Itoa(job.RowsSaved), optionalID(job.StatementID),
+			formatTime(&job.CreatedAt), job.Error,
+		})
+	}
+	return writeTable(w, []string{"ID", "ACCOUNT", "PERIOD", "STATE", "READ", "SAVED", "STATEMENT", "CREATED", "ERROR"}, rows)
+}
+
+func writeStatements(w io.Writer, format string, statements []domain.Statement) error {
+	if format == "json" {
+		return writeJSON(w, statements)
+	}
+	rows := make([][]string, 0, len(statements))
+	for _, s := range statements {
+		rows = append(rows, []string{
+			strconv.Itoa(s.ID), s.AccountID, s.Period, s.Recipient, s.Status, optionalID(s.ResentFromID),
+			formatTime(&s.CreatedAt), s.Error,
+		})
+	}
+	return writeTable(w, []string{"ID", "ACCOUNT", "PERIOD", "RECIPIENT", "STATUS", "RESENT FROM", "CREATED", "ERROR"}, rows)
+}
+
+func writeMigrationStatus(w io.Writer, format string, status *domain.MigrationStatus) error {
+	if format == "json" {
+		return writeJSON(w, status)
+	}
+	fmt.Fprintf(w, "Schema version: %d\n", status.Version)
+	if len(status.Pending) == 0 {
+		_, err := fmt.Fprintln(w, "No pending migrations")
+		return err
+	}
+	rows := make([][]string, 0, len(status.Pending))
+	for _, version := range status.Pending {
+		rows = append(rows, []string{strconv.FormatInt(version, 10)})
+	}
+	fmt.Fprintln(w)
+	return writeTable(w, []string{"PENDING"}, rows)
+}
+
+// writeSummary writes a statement summary, as built by the use cases.
+func writeSummary(w io.Writer, format string, summary map[string]interface{}) error {
+	if format == "json" {
+		return writeJSON(w, summary)
+	}
+	fmt.Fprintf(w, "Total balance: %.2f\n\n", summary["TotalBalance"])
+	months, _ := summary["MonthlyData"].([]map[string]interface{})
+	rows := make([][]string, 0, len(months))
+	for _, month := range months {
+		rows = append(rows, []string{
+			fmt.Sprint(month["Month"]), fmt.Sprint(month["Transactions"]),
+			fmt.Sprintf("%.2f", month["AverageDebit"]), fmt.Sprintf("%.2f", month["AverageCredit"]),
+		})
+	}
+	return writeTable(w, []string{"MONTH", "TRANSACTIONS", "AVERAGE DEBIT", "AVERAGE CREDIT"}, rows)
+}
+
+func optionalID(id *int) string {
+	if id == nil {
+		return "-"
+	}
+	return strconv.Itoa(*id)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,227 @@
+// Package app wires the infrastructure shared by the server and the command
+// line tools from the configuration.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jordanlanch/stori-test/internal/config"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/email"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/lock"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/logging"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/migrate"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/tracing"
+	"github.com/jordanlanch/stori-test/storage"
+	"github.com/pressly/goose/v3"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// OpenDatabase connects to the database selected by DB_DRIVER.
+func OpenDatabase(env *config.Env) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch env.DBDriver {
+	case "sqlite":
+		dialector = sqlite.Open(sqliteDSN(env))
+	default:
+		sqlDB, err := openPostgres(env)
+		if err != nil {
+			return nil, err
+		}
+		dialector = postgres.New(postgres.Config{Conn: sqlDB})
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logging.GormLogger{SlowThreshold: 200 * time.Millisecond}})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// openPostgres connects to Postgres. New connections authenticate with the
+// current password, so it can be rotated without a restart.
+func openPostgres(env *config.Env) (*sql.DB, error) {
+	secrets := env.Secrets()
+	dsn := fmt.Sprintf("host=%s user=%s dbname=%s port=%d sslmode=disable",
+		env.DBHost, env.DBUser, env.DBName, env.DBPort)
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database settings: %w", err)
+	}
+	return stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(_ context.Context, cfg *pgx.ConnConfig) error {
+		cfg.Password = secrets.Get("DB_PASSWORD")
+		return nil
+	})), nil
+}
+
+// sqliteDSN opens DB_PATH for concurrent use: in WAL mode readers do not
+// block the writer, transactions take the write lock as they begin rather
+// than failing to upgrade a read lock, and a writer waits up to
+// SQLITE_BUSY_TIMEOUT_MS for another one to finish.
+func sqliteDSN(env *config.Env) string {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", strconv.Itoa(env.SQLiteBusyMs))
+	params.Set("_txlock", "immediate")
+	params.Set("_foreign_keys", "on")
+	return env.DBPath + "?" + params.Encode()
+}
+
+// NewMigrator returns the migrator of db, applying the migrations embedded
+// in the binary for its driver.
+func NewMigrator(db *gorm.DB) (*migrate.Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := storage.Migrations(db.Name())
+	if err != nil {
+		return nil, err
+	}
+	dialect := goose.DialectPostgres
+	if db.Name() == "sqlite" {
+		dialect = goose.DialectSQLite3
+	}
+	return migrate.NewMigrator(sqlDB, dialect, migrations)
+}
+
+// PrepareSchema applies the pending migrations when MIGRATE_ON_START is set,
+// then fails if the schema is still behind: the service must not run
+// against a schema it does not know.
+func PrepareSchema(ctx context.Context, env *config.Env, migrator *migrate.Migrator) error {
+	if env.MigrateOnStart {
+		if _, err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("applying migrations: %w", err)
+		}
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("reading the schema version: %w", err)
+	}
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("%d pending migrations, from version %d: run stori migrate up or set MIGRATE_ON_START=true", len(status.Pending), status.Pending[0])
+	}
+	return nil
+}
+
+// NewRedisClient returns a Redis client whose dials, reads and writes time
+// out after REDIS_TIMEOUT_SEC. New connections authenticate with the current
+// password, so it can be rotated without a restart.
+func NewRedisClient(env *config.Env) *redis.Client {
+	secrets := env.Secrets()
+	timeout := time.Duration(env.RedisTimeoutSec) * time.Second
+	client := redis.NewClient(&redis.Options{
+		Addr:         fmt.Sprintf("%s:%d", env.RedisHost, env.RedisPort),
+		DB:           0,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		OnConnect: func(ctx context.Context, cn *redis.Conn) error {
+			if password := secrets.Get("REDIS_PASSWORD"); password != "" {
+				return cn.Auth(ctx, password).Err()
+			}
+			return nil
+		},
+	})
+	client.AddHook(tracing.RedisHook{})
+	return client
+}
+
+// Cache is the transaction cache, whose TTL follows CACHE_DURATION_SEC on
+// reload.
+type Cache interface {
+	usecase.CacheRepository
+	SetTTL(cacheDurationSec int)
+}
+
+// NewCache returns the transaction cache selected by CACHE_BACKEND: Redis,
+// shared by the replicas, or the memory of the process.
+func NewCache(env *config.Env, redisClient *redis.Client) Cache {
+	if env.CacheBackend == "memory" {
+		return repository.NewMemoryCacheRepository(env.CacheMaxEntries, env.CacheDurationSec)
+	}
+	return repository.NewCacheTransactionRepository(redisClient, env.CacheDurationSec)
+}
+
+// NewLocker returns the run leases selected by LOCK_BACKEND: Redis, shared
+// by the replicas, or the memory of the process, for a single replica.
+func NewLocker(env *config.Env, redisClient *redis.Client) usecase.Locker {
+	if env.LockBackend == "memory" {
+		return lock.NewMemoryLocker()
+	}
+	return lock.NewRedisLocker(redisClient, time.Duration(env.LockTTLSec)*time.Second)
+}
+
+// NewTransactionUseCase returns the pipeline ingesting the CSV file at
+// csvPath, caching its transactions in cache and leasing its runs from the
+// locker selected by LOCK_BACKEND.
+func NewTransactionUseCase(env *config.Env, db *gorm.DB, redisClient *redis.Client, cache usecase.CacheRepository, emailService usecase.EmailService, csvPath string) usecase.TransactionUseCase {
+	return usecase.NewTransactionUseCase(
+		repository.NewDBTransactionRepository(db, csvPath),
+		cache,
+		repository.NewDBStatementRepository(db),
+		emailService,
+		NewLocker(env, redisClient),
+		env.CacheDurationSec,
+		env.CacheRequired,
+		env.DefaultAccountID,
+	)
+}
+
+// NewStatementUseCase returns the statements of the transactions stored in
+// db, archived in db as they are sent.
+func NewStatementUseCase(env *config.Env, db *gorm.DB, emailService usecase.EmailService) usecase.StatementUseCase {
+	return usecase.NewStatementUseCase(
+		repository.NewDBTransactionRepository(db, env.CSVFilePath),
+		repository.NewDBStatementRepository(db),
+		emailService,
+		env.DefaultAccountID,
+	)
+}
+
+// NewEmailService returns the service sending statements, signing them
+// with DKIM when a key is configured and picking up a rotated
+// EMAIL_PASSWORD.
+func NewEmailService(env *config.Env) (*email.EmailService, error) {
+	var dkimSigner *email.DKIMSigner
+	if env.DKIMKeyPath != "" {
+		var err error
+		dkimSigner, err = email.NewDKIMSigner(email.DKIMConfig{
+			Domain:         env.DKIMDomain,
+			Selector:       env.DKIMSelector,
+			PrivateKeyPath: env.DKIMKeyPath,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loading DKIM key: %w", err)
+		}
+	}
+	emailService := email.NewEmailService(email.Config{
+		From:       env.EmailFrom,
+		To:         env.EmailTo,
+		Password:   env.EmailPassword,
+		SMTPHost:   env.SMTPHost,
+		SMTPPort:   env.SMTPPort,
+		Fake:       env.FakeEmail,
+		ArchiveDir: env.EmailArchiveDir,
+		DKIM:       dkimSigner,
+	})
+	env.Secrets().OnChange(func(key, value string) {
+		if key == "EMAIL_PASSWORD" {
+			emailService.SetPassword(value)
+		}
+	})
+	return emailService, nil
+}
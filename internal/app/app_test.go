@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/config"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sqliteEnv(t *testing.T) *config.Env {
+	t.Helper()
+	return &config.Env{DBDriver: "sqlite", DBPath: filepath.Join(t.TempDir(), "stori.db"), SQLiteBusyMs: 5000}
+}
+
+func TestPrepareSchema_SQLite(t *testing.T) {
+	env := sqliteEnv(t)
+	db, err := OpenDatabase(env)
+	require.NoError(t, err)
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	assert.ErrorContains(t, PrepareSchema(ctx, env, migrator), "pending migrations", "the schema is empty")
+
+	env.MigrateOnStart = true
+	require.NoError(t, PrepareSchema(ctx, env, migrator))
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, status.Pending)
+
+	var journalMode string
+	require.NoError(t, db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	assert.Equal(t, "wal", journalMode)
+}
+
+func TestSQLiteRepositories(t *testing.T) {
+	env := sqliteEnv(t)
+	env.MigrateOnStart = true
+	db, err := OpenDatabase(env)
+	require.NoError(t, err)
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, PrepareSchema(ctx, env, migrator))
+
+	transactions := repository.NewDBTransactionRepository(db, "")
+	fence := &domain.Fence{Key: "acc-1", Token: 2}
+	require.NoError(t, transactions.SaveTransactions(ctx, []domain.Transaction{
+		{AccountID: "acc-1", Date: "7/15", Amount: 60.5},
+		{AccountID: "acc-1", Date: "7/28", Amount: -10.306},
+	}, fence))
+	saved, err := transactions.GetTransactionsByAccount(ctx, "acc-1")
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.Equal(t, 60.5, saved[0].Amount)
+	assert.Equal(t, -10.31, saved[1].Amount, "amounts are rounded to cents, as NUMERIC(10, 2) does")
+
+	assert.ErrorIs(t, transactions.SaveTransactions(ctx, []domain.Transaction{{AccountID: "acc-1", Date: "8/1", Amount: 1}}, &domain.Fence{Key: "acc-1", Token: 1}), domain.ErrStaleLease)
+	assert.Error(t, transactions.SaveTransactions(ctx, []domain.Transaction{{AccountID: "acc-1", Date: "8/1", Amount: 100000000}}, nil), "out of NUMERIC(10, 2) range")
+
+	statements := repository.NewDBStatementRepository(db)
+	statement := &domain.Statement{AccountID: "acc-1", Recipient: "customer@example.com", Subject: "Your statement", Period: "2024-07",
+		Summary: map[string]interface{}{"TotalBalance": 50.19}, HTMLBody: "<p>50.19</p>", TextBody: "50.19", BodyHash: "hash", Status: domain.StatementStatusSent}
+	require.NoError(t, statements.CreateStatement(ctx, statement))
+	found, err := statements.ListStatements(ctx, domain.StatementFilter{AccountID: "acc-1", Status: domain.StatementStatusSent, NotResent: true})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, 50.19, found[0].Summary["TotalBalance"])
+
+	jobs := repository.NewDBJobRepository(db)
+	job := &domain.Job{ID: "job-1", AccountID: "acc-1", State: domain.JobStateSucceeded, Stages: []domain.StageTiming{}, StatementID: &statement.ID}
+	require.NoError(t, jobs.CreateJob(ctx, job))
+	listed, err := jobs.ListJobs(ctx, domain.JobFilter{AccountID: "acc-1"})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, statement.ID, *listed[0].StatementID)
+}
@@ -0,0 +1,315 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/config"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/auth"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/health"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/idempotency"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/logging"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/migrate"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/queue"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/ratelimit"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/scheduler"
+	"github.com/jordanlanch/stori-test/internal/interface/api/controller"
+	"github.com/jordanlanch/stori-test/internal/interface/api/middleware"
+	"github.com/jordanlanch/stori-test/internal/interface/api/openapi"
+	"github.com/jordanlanch/stori-test/internal/interface/api/router"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Server is the HTTP API with the job workers and the scheduler behind it.
+type Server struct {
+	Handler *gin.Engine
+	// Reloader applies configuration changes to the running server.
+	Reloader *config.Reloader
+
+	db          *gorm.DB
+	redisClient *redis.Client
+	jobQueue    queue.Queue
+	scheduler   *scheduler.Scheduler
+}
+
+// NewServer connects to the database and Redis, prepares the schema and
+// wires the API, then starts the job workers and, with SCHEDULE_ENABLED,
+// the scheduler, which runs until ctx is done. load reads the configuration
+// again on reloads.
+func NewServer(ctx context.Context, env *config.Env, load func() (*config.Env, error)) (_ *Server, err error) {
+	redisClient := NewRedisClient(env)
+	db, err := OpenDatabase(env)
+	if err != nil {
+		redisClient.Close()
+		return nil, fmt.Errorf("connecting to the database: %w", err)
+	}
+	server := &Server{db: db, redisClient: redisClient}
+	defer func() {
+		if err != nil {
+			_ = server.Close(context.Background())
+		}
+	}()
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return nil, fmt.Errorf("loading the migrations: %w", err)
+	}
+	if err := PrepareSchema(ctx, env, migrator); err != nil {
+		return nil, err
+	}
+
+	cacheRepo := NewCache(env, redisClient)
+	emailService, err := NewEmailService(env)
+	if err != nil {
+		return nil, fmt.Errorf("setting up email: %w", err)
+	}
+	dbRepo := repository.NewDBTransactionRepository(db, env.CSVFilePath)
+	jobRepo := repository.NewDBJobRepository(db)
+	transactionUseCase := NewTransactionUseCase(env, db, redisClient, cacheRepo, emailService, env.CSVFilePath)
+	jobQueue, maxAttempts := newJobQueue(env, redisClient)
+	jobUseCase := usecase.NewJobUseCase(jobRepo, jobQueue, transactionUseCase, env.JobTimeoutSec, maxAttempts, env.DefaultAccountID)
+	// Jobs the queue gives up on, buffered at shutdown or stuck, end as
+	// failed.
+	jobQueue.OnAbandon(jobUseCase.AbandonJob)
+	statementUseCase := NewStatementUseCase(env, db, emailService)
+
+	schedule, err := cron.ParseStandard(env.ScheduleCron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCHEDULE_CRON: %w", err)
+	}
+	scheduleUseCase := usecase.NewScheduleUseCase(repository.NewDBScheduleRunRepository(db), jobUseCase, schedule, domain.NewBusinessCalendar(env.ScheduleHolidayList()), env.ScheduleAccountList(), env.ScheduleCatchUp, time.Now())
+
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(repository.NewDBAPIKeyRepository(db), env.APIKeyGraceSec)
+	authenticate, err := newAuthenticate(env, apiKeyUseCase)
+	if err != nil {
+		return nil, fmt.Errorf("setting up authentication: %w", err)
+	}
+
+	// Requests are handled well within a minute, after which a key left
+	// in progress by a crashed replica can be reused.
+	idempotent := middleware.Idempotency(newIdempotencyStore(env, redisClient, time.Minute))
+
+	spec, validation, err := newOpenAPI(env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	policies, err := ratePolicies(env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limits: %w", err)
+	}
+	policySet := middleware.NewRatePolicySet(policies)
+	rateLimit := middleware.RateLimit(newRateLimiter(env, redisClient), policySet)
+
+	// Rate limits, cache TTL, email templates and recipient and log level
+	// are reloaded; an invalid configuration is rejected and the running
+	// one kept.
+	server.Reloader = config.NewReloader(env, load)
+	server.Reloader.Check(func(env *config.Env) error {
+		_, err := ratePolicies(env)
+		return err
+	})
+	server.Reloader.Check(func(*config.Env) error { return emailService.CheckTemplates() })
+	server.Reloader.OnReload(func(env *config.Env) {
+		// Every check has passed by now.
+		policies, _ := ratePolicies(env)
+		policySet.Set(policies)
+		cacheRepo.SetTTL(env.CacheDurationSec)
+		emailService.SetRecipient(env.EmailTo)
+		if err := logging.SetLevel(env.LogLevel); err != nil {
+			slog.Warn("Failed to set the log level", "error", err)
+		}
+		if err := emailService.ReloadTemplates(); err != nil {
+			slog.Warn("Failed to reload the email templates", "error", err)
+		}
+	})
+
+	server.Handler = router.SetupRouter(router.Controllers{
+		Transaction: &controller.TransactionController{UseCase: jobUseCase},
+		Job:         &controller.JobController{UseCase: jobUseCase},
+		Statement:   &controller.StatementController{UseCase: statementUseCase},
+		Schedule:    &controller.ScheduleController{UseCase: scheduleUseCase},
+		APIKey:      &controller.APIKeyController{UseCase: apiKeyUseCase},
+		Account:     &controller.AccountController{UseCase: usecase.NewAccountUseCase(dbRepo)},
+		Spec:        spec,
+		Health: &controller.HealthController{
+			UseCase: usecase.NewHealthUseCase(newHealthChecks(env, db, redisClient, migrator), time.Duration(env.HealthTimeoutSec)*time.Second),
+		},
+		Metrics: &controller.MetricsController{Handler: metrics.Handler()},
+		Config:  &controller.ConfigController{Reloader: server.Reloader},
+	}, authenticate, rateLimit, idempotent, env.DefaultAccountID, append([]gin.HandlerFunc{middleware.RequestID(), middleware.Tracing(), middleware.Logger(), middleware.Metrics()}, validation...)...)
+
+	if err := jobQueue.Start(context.Background(), env.JobWorkers, metrics.Job(jobUseCase.RunJob)); err != nil {
+		return nil, fmt.Errorf("starting job workers: %w", err)
+	}
+	server.jobQueue = jobQueue
+	metrics.ObserveJobQueue(jobRepo.CountByState, domain.JobStateQueued, domain.JobStateRetrying, domain.JobStateRunning)
+	if env.ScheduleEnabled {
+		server.scheduler = scheduler.NewScheduler(scheduleUseCase, time.Minute)
+		server.scheduler.Start(ctx)
+	}
+	return server, nil
+}
+
+// Close waits for the scheduler to stop, lets the job workers finish what
+// they are running, cancelling the jobs still running when ctx is done, and
+// closes the Redis and database connections. Statements are emailed by the
+// jobs sending them, so none is left unsent once they are drained.
+func (s *Server) Close(ctx context.Context) error {
+	var errs []error
+	if s.scheduler != nil {
+		s.scheduler.Wait()
+	}
+	if s.jobQueue != nil {
+		if err := s.jobQueue.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("draining jobs: %w", err))
+		}
+	}
+	if err := s.redisClient.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing Redis: %w", err))
+	}
+	sqlDB, err := s.db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("closing database: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// newJobQueue builds the job queue selected by JOB_QUEUE_BACKEND and returns
+// how many times it delivers a failing job.
+func newJobQueue(env *config.Env, redisClient *redis.Client) (queue.Queue, int) {
+	if env.JobQueueBackend == "memory" {
+		return queue.NewMemoryQueue(env.JobQueueSize), 1
+	}
+	hostname, _ := os.Hostname()
+	return queue.NewRedisStreamQueue(redisClient, queue.RedisStreamConfig{
+		Consumer:          fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		VisibilityTimeout: time.Duration(env.JobVisibilitySec) * time.Second,
+		MaxAttempts:       env.JobMaxAttempts,
+		RetryBackoff:      time.Duration(env.JobRetryBackoff) * time.Second,
+	}), env.JobMaxAttempts
+}
+
+// newRateLimiter returns the limiter selected by RATE_LIMIT_BACKEND: Redis,
+// whose limits hold across the replicas, or the memory of the process, for a
+// single replica.
+func newRateLimiter(env *config.Env, redisClient *redis.Client) middleware.RateLimiter {
+	if env.RateLimitBackend == "memory" {
+		return ratelimit.NewMemoryLimiter()
+	}
+	return ratelimit.NewRedisLimiter(redisClient)
+}
+
+// newIdempotencyStore returns the store selected by IDEMPOTENCY_BACKEND,
+// holding a key in progress for at most lockTTL.
+func newIdempotencyStore(env *config.Env, redisClient *redis.Client, lockTTL time.Duration) middleware.IdempotencyStore {
+	ttl := time.Duration(env.IdempotencyTTL) * time.Second
+	if env.IdempotencyStore == "memory" {
+		return idempotency.NewMemoryStore(lockTTL, ttl)
+	}
+	return idempotency.NewRedisStore(redisClient, lockTTL, ttl)
+}
+
+// newHealthChecks lists the dependencies checked for readiness: the
+// database, named after DB_DRIVER, and the migrations, reported with the
+// schema version, are required, as is Redis unless nothing that needs it is
+// kept there, and it is not checked when nothing is; the CSV source and,
+// unless FAKE_EMAIL is set, the mail server are optional.
+func newHealthChecks(env *config.Env, db *gorm.DB, redisClient *redis.Client, migrator *migrate.Migrator) []usecase.HealthCheck {
+	checks := []usecase.HealthCheck{
+		{Name: env.DBDriver, Required: true, Check: health.Database(db)},
+		{Name: "migrations", Required: true, Inspect: health.Migrations(migrator.Status)},
+		{Name: "csv_source", Check: health.File(env.CSVFilePath)},
+	}
+	if env.RedisUsed() {
+		checks = append(checks, usecase.HealthCheck{Name: "redis", Required: env.RedisRequired(), Check: health.Redis(redisClient)})
+	}
+	if !env.FakeEmail {
+		checks = append(checks, usecase.HealthCheck{Name: "mail", Check: health.SMTP(env.SMTPHost, env.SMTPPort)})
+	}
+	return checks
+}
+
+// ratePolicies returns the rate limits: RATE_LIMIT requests per second per
+// RATE_LIMIT_KEY by default, overridden per route by RATE_LIMIT_ROUTES.
+func ratePolicies(env *config.Env) (middleware.RatePolicies, error) {
+	burst := env.RateLimitBurst
+	if burst <= 0 {
+		burst = env.RateLimit
+	}
+	return middleware.ParseRatePolicies(env.RateLimitRoutes, middleware.RatePolicy{
+		Limit: domain.RateLimit{Rate: env.RateLimit, Period: time.Second, Burst: burst},
+		Key:   env.RateLimitKey,
+	})
+}
+
+// newOpenAPI loads the OpenAPI document and builds the middleware
+// validating requests against it, and with OPENAPI_VALIDATE_RESPONSES also
+// logging responses that do not match it.
+func newOpenAPI(env *config.Env) (*controller.SpecController, []gin.HandlerFunc, error) {
+	doc, err := openapi.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	document, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	validation := []gin.HandlerFunc{openapi.ValidateRequests(doc)}
+	if env.ValidateResponse {
+		validation = append(validation, openapi.ValidateResponses(doc, func(c *gin.Context, err error) {
+			slog.WarnContext(c.Request.Context(), "response does not match the OpenAPI document", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		}))
+	}
+	return &controller.SpecController{Document: document}, validation, nil
+}
+
+// newAuthenticate builds the authentication middleware, accepting API keys
+// and, with a JWKS configured, bearer tokens. AUTH_BOOTSTRAP_KEY is stored
+// as an admin key. With AUTH_ENABLED false every request is let through.
+func newAuthenticate(env *config.Env, apiKeys usecase.APIKeyUseCase) (gin.HandlerFunc, error) {
+	if !env.AuthEnabled {
+		return middleware.Anonymous(), nil
+	}
+	if env.AuthBootstrapKey != "" {
+		err := apiKeys.EnsureKey(context.Background(), "bootstrap", env.AuthBootstrapKey, []string{domain.ScopeAdmin})
+		if err != nil {
+			return nil, fmt.Errorf("store the bootstrap API key: %w", err)
+		}
+	}
+	if env.JWTJWKSURL == "" && env.JWTJWKSFile == "" {
+		return middleware.Authenticate(apiKeys, nil), nil
+	}
+
+	jwks, err := auth.NewJWKS(context.Background(), auth.JWKSConfig{
+		URL:             env.JWTJWKSURL,
+		File:            env.JWTJWKSFile,
+		RefreshInterval: time.Duration(env.JWTJWKSRefresh) * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	verifier := auth.NewJWTVerifier(jwks, auth.JWTConfig{
+		Issuer:        env.JWTIssuer,
+		Audience:      env.JWTAudience,
+		AccountClaim:  env.JWTAccountClaim,
+		DefaultScopes: env.JWTDefaultScopeList(),
+		Leeway:        30 * time.Second,
+	})
+	return middleware.Authenticate(apiKeys, verifier), nil
+}
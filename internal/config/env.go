@@ -1,84 +1,356 @@
 package config
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// Env is the configuration of the service. Each field is set by the
+// variable named in its mapstructure tag, validated by its validate tag, and
+// masked when printed if its secret tag is set. Secrets can also be read
+// from files and secret managers, see Secrets. Fields with a reload tag can
+// be changed without a restart, see Reloader.
 type Env struct {
-	AppEnv           string `mapstructure:"APP_ENV" required:"true"`
-	ServerAddress    string `mapstructure:"SERVER_ADDRESS" required:"true"`
-	ContextTimeout   int    `mapstructure:"CONTEXT_TIMEOUT" required:"true"`
-	RedisHost        string `mapstructure:"REDIS_HOST" required:"true"`
-	RedisPort        int    `mapstructure:"REDIS_PORT" required:"true"`
-	RedisPassword    string `mapstructure:"REDIS_PASSWORD"`
-	EmailFrom        string `mapstructure:"EMAIL_FROM" required:"true"`
-	EmailTo          string `mapstructure:"EMAIL_TO" required:"true"`
-	EmailPassword    string `mapstructure:"EMAIL_PASSWORD" required:"true"`
-	SMTPHost         string `mapstructure:"SMTP_HOST" required:"true"`
-	SMTPPort         int    `mapstructure:"SMTP_PORT" required:"true"`
-	CSVFilePath      string `mapstructure:"CSV_FILE_PATH" required:"true"`
-	FakeEmail        bool   `mapstructure:"FAKE_EMAIL" required:"true"`
-	RateLimit        int    `mapstructure:"RATE_LIMIT" required:"true"`
-	RedisTimeoutSec  int    `mapstructure:"REDIS_TIMEOUT_SEC" required:"true"`
-	CacheDurationSec int    `mapstructure:"CACHE_DURATION_SEC" required:"true"`
-	DBHost           string `mapstructure:"DB_HOST" required:"true"`
-	DBUser           string `mapstructure:"DB_USER" required:"true"`
-	DBPassword       string `mapstructure:"DB_PASSWORD" required:"true"`
-	DBName           string `mapstructure:"DB_NAME" required:"true"`
-	DBPort           int    `mapstructure:"DB_PORT" required:"true"`
+	AppEnv           string  `mapstructure:"APP_ENV" validate:"required"`
+	ServerAddress    string  `mapstructure:"SERVER_ADDRESS" validate:"required,hostname_port"`
+	RedisHost        string  `mapstructure:"REDIS_HOST" validate:"required"`
+	RedisPort        int     `mapstructure:"REDIS_PORT" validate:"min=1,max=65535"`
+	RedisPassword    string  `mapstructure:"REDIS_PASSWORD" secret:"true"`
+	EmailFrom        string  `mapstructure:"EMAIL_FROM" validate:"required,email"`
+	EmailTo          string  `mapstructure:"EMAIL_TO" validate:"required,email" reload:"true"`
+	EmailPassword    string  `mapstructure:"EMAIL_PASSWORD" validate:"required" secret:"true"`
+	SMTPHost         string  `mapstructure:"SMTP_HOST" validate:"required,hostname_rfc1123"`
+	SMTPPort         int     `mapstructure:"SMTP_PORT" validate:"min=1,max=65535"`
+	CSVFilePath      string  `mapstructure:"CSV_FILE_PATH" validate:"required"`
+	FakeEmail        bool    `mapstructure:"FAKE_EMAIL"`
+	EmailArchiveDir  string  `mapstructure:"EMAIL_ARCHIVE_DIR"`
+	DefaultAccountID string  `mapstructure:"DEFAULT_ACCOUNT_ID" validate:"required"`
+	DKIMDomain       string  `mapstructure:"DKIM_DOMAIN" validate:"omitempty,fqdn"`
+	DKIMSelector     string  `mapstructure:"DKIM_SELECTOR"`
+	DKIMKeyPath      string  `mapstructure:"DKIM_PRIVATE_KEY_PATH"`
+	RateLimit        int     `mapstructure:"RATE_LIMIT" validate:"min=1" reload:"true"`
+	RateLimitBurst   int     `mapstructure:"RATE_LIMIT_BURST" validate:"min=0" reload:"true"`
+	RateLimitKey     string  `mapstructure:"RATE_LIMIT_KEY" validate:"oneof=ip client account" reload:"true"`
+	RateLimitRoutes  string  `mapstructure:"RATE_LIMIT_ROUTES" reload:"true"`
+	RateLimitBackend string  `mapstructure:"RATE_LIMIT_BACKEND" validate:"oneof=redis memory"`
+	RedisTimeoutSec  int     `mapstructure:"REDIS_TIMEOUT_SEC" validate:"min=1"`
+	JobTimeoutSec    int     `mapstructure:"JOB_TIMEOUT_SEC" validate:"min=1"`
+	JobWorkers       int     `mapstructure:"JOB_WORKERS" validate:"min=1"`
+	JobQueueSize     int     `mapstructure:"JOB_QUEUE_SIZE" validate:"min=1"`
+	JobQueueBackend  string  `mapstructure:"JOB_QUEUE_BACKEND" validate:"oneof=redis memory"`
+	LockTTLSec       int     `mapstructure:"LOCK_TTL_SEC" validate:"min=1"`
+	LockBackend      string  `mapstructure:"LOCK_BACKEND" validate:"oneof=redis memory"`
+	JobVisibilitySec int     `mapstructure:"JOB_VISIBILITY_TIMEOUT_SEC" validate:"min=1"`
+	JobMaxAttempts   int     `mapstructure:"JOB_MAX_ATTEMPTS" validate:"min=1"`
+	JobRetryBackoff  int     `mapstructure:"JOB_RETRY_BACKOFF_SEC" validate:"min=0"`
+	ScheduleEnabled  bool    `mapstructure:"SCHEDULE_ENABLED"`
+	ScheduleCron     string  `mapstructure:"SCHEDULE_CRON" validate:"required"`
+	ScheduleAccounts string  `mapstructure:"SCHEDULE_ACCOUNTS"`
+	ScheduleHolidays string  `mapstructure:"SCHEDULE_HOLIDAYS"`
+	ScheduleCatchUp  int     `mapstructure:"SCHEDULE_MAX_CATCH_UP" validate:"min=0"`
+	AuthEnabled      bool    `mapstructure:"AUTH_ENABLED"`
+	AuthBootstrapKey string  `mapstructure:"AUTH_BOOTSTRAP_KEY" validate:"omitempty,min=32" secret:"true"`
+	APIKeyGraceSec   int     `mapstructure:"API_KEY_ROTATION_GRACE_SEC" validate:"min=0"`
+	JWTJWKSURL       string  `mapstructure:"JWT_JWKS_URL" validate:"omitempty,url"`
+	JWTJWKSFile      string  `mapstructure:"JWT_JWKS_FILE"`
+	JWTJWKSRefresh   int     `mapstructure:"JWT_JWKS_REFRESH_SEC" validate:"min=1"`
+	JWTIssuer        string  `mapstructure:"JWT_ISSUER"`
+	JWTAudience      string  `mapstructure:"JWT_AUDIENCE"`
+	JWTAccountClaim  string  `mapstructure:"JWT_ACCOUNT_CLAIM" validate:"required"`
+	JWTDefaultScopes string  `mapstructure:"JWT_DEFAULT_SCOPES"`
+	IdempotencyTTL   int     `mapstructure:"IDEMPOTENCY_TTL_SEC" validate:"min=1"`
+	IdempotencyStore string  `mapstructure:"IDEMPOTENCY_BACKEND" validate:"oneof=redis memory"`
+	ValidateResponse bool    `mapstructure:"OPENAPI_VALIDATE_RESPONSES"`
+	HealthTimeoutSec int     `mapstructure:"HEALTH_CHECK_TIMEOUT_SEC" validate:"min=1"`
+	MigrateOnStart   bool    `mapstructure:"MIGRATE_ON_START"`
+	ShutdownTimeout  int     `mapstructure:"SHUTDOWN_TIMEOUT_SEC" validate:"min=1"`
+	OTelServiceName  string  `mapstructure:"OTEL_SERVICE_NAME" validate:"required"`
+	OTelExporter     string  `mapstructure:"OTEL_TRACES_EXPORTER" validate:"oneof=none otlp stdout"`
+	OTelEndpoint     string  `mapstructure:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"omitempty,url"`
+	OTelFile         string  `mapstructure:"OTEL_TRACES_FILE"`
+	OTelSampleRatio  float64 `mapstructure:"OTEL_TRACES_SAMPLE_RATIO" validate:"min=0,max=1"`
+	LogLevel         string  `mapstructure:"LOG_LEVEL" validate:"oneof=debug info warn error" reload:"true"`
+	LogFormat        string  `mapstructure:"LOG_FORMAT" validate:"oneof=json text"`
+	LogRedactPII     bool    `mapstructure:"LOG_REDACT_PII"`
+	VaultAddr        string  `mapstructure:"VAULT_ADDR" validate:"omitempty,url"`
+	VaultToken       string  `mapstructure:"VAULT_TOKEN" secret:"true"`
+	SecretsRefresh   int     `mapstructure:"SECRETS_REFRESH_SEC" validate:"min=0"`
+	CacheDurationSec int     `mapstructure:"CACHE_DURATION_SEC" validate:"min=0" reload:"true"`
+	CacheBackend     string  `mapstructure:"CACHE_BACKEND" validate:"oneof=redis memory"`
+	CacheMaxEntries  int     `mapstructure:"CACHE_MAX_ENTRIES" validate:"min=1"`
+	CacheRequired    bool    `mapstructure:"CACHE_REQUIRED"`
+	DBDriver         string  `mapstructure:"DB_DRIVER" validate:"oneof=postgres sqlite"`
+	DBHost           string  `mapstructure:"DB_HOST" validate:"required_if=DBDriver postgres"`
+	DBUser           string  `mapstructure:"DB_USER" validate:"required_if=DBDriver postgres"`
+	DBPassword       string  `mapstructure:"DB_PASSWORD" validate:"required_if=DBDriver postgres" secret:"true"`
+	DBName           string  `mapstructure:"DB_NAME" validate:"required_if=DBDriver postgres"`
+	DBPort           int     `mapstructure:"DB_PORT" validate:"min=1,max=65535"`
+	DBPath           string  `mapstructure:"DB_PATH" validate:"required_if=DBDriver sqlite"`
+	SQLiteBusyMs     int     `mapstructure:"SQLITE_BUSY_TIMEOUT_MS" validate:"min=0"`
+
+	file    string
+	secrets *Secrets
 }
 
-func NewEnv(envFile string) *Env {
-	viper.SetConfigType("env")
-	viper.SetConfigFile(envFile)
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Can't find the file %s: %s", envFile, err)
-	}
+// ErrHelp is returned by Load when the flags ask for help, which has been
+// printed.
+var ErrHelp = pflag.ErrHelp
 
-	viper.AutomaticEnv()
-	viper.SetDefault("CONTEXT_TIMEOUT", 5)
-	viper.SetDefault("REDIS_PORT", 6379)
-	viper.SetDefault("SMTP_PORT", 587)
-	viper.SetDefault("FAKE_EMAIL", false)
-	viper.SetDefault("RATE_LIMIT", 1000)
-	viper.SetDefault("REDIS_TIMEOUT_SEC", 5)
-	viper.SetDefault("CACHE_DURATION_SEC", 600)
-	viper.SetDefault("DB_PORT", 5432)
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the config file, the environment variables and the command
+// line flags in args. Every variable has a flag named after it in lower case
+// with dashes, e.g. --server-address for SERVER_ADDRESS. The config file is
+// the one given by --config or CONFIG_FILE, or defaultFile when it exists.
+// Its format follows its extension: .yaml or .yml, .toml, and dotenv
+// otherwise.
+func Load(defaultFile string, args []string) (*Env, error) {
+	v := viper.New()
+	flags := pflag.NewFlagSet("stori-test", pflag.ContinueOnError)
+	configFile := flags.String("config", "", "configuration file (YAML, TOML or dotenv)")
+	for _, field := range fields() {
+		key := field.Tag.Get("mapstructure")
+		name := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			flags.Bool(name, false, key)
+		case reflect.Int:
+			flags.Int(name, 0, key)
+		case reflect.Float64:
+			flags.Float64(name, 0, key)
+		default:
+			flags.String(name, "", key)
+		}
+		if err := v.BindPFlag(key, flags.Lookup(name)); err != nil {
+			return nil, err
+		}
+		if err := v.BindEnv(key); err != nil {
+			return nil, err
+		}
+		if field.Tag.Get("secret") == "true" {
+			flags.String(name+"-file", "", key+"_FILE")
+			if err := v.BindPFlag(key+"_FILE", flags.Lookup(name+"-file")); err != nil {
+				return nil, err
+			}
+			if err := v.BindEnv(key + "_FILE"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	setDefaults(v)
 
 	var env Env
-	if err := viper.Unmarshal(&env); err != nil {
-		log.Fatalf("Environment can't be loaded: %s", err)
+
+	file, required := *configFile, true
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file == "" {
+		file, required = defaultFile, false
+	}
+	if file != "" {
+		if _, err := os.Stat(file); err == nil || required {
+			v.SetConfigFile(file)
+			switch ext := strings.TrimPrefix(filepath.Ext(file), "."); ext {
+			case "yaml", "yml", "toml":
+				v.SetConfigType(ext)
+			default:
+				v.SetConfigType("env")
+			}
+			if err := v.ReadInConfig(); err != nil {
+				return nil, fmt.Errorf("reading config file %s: %w", file, err)
+			}
+			env.file = file
+		}
 	}
 
+	if err := v.Unmarshal(&env); err != nil {
+		return nil, fmt.Errorf("decoding configuration: %w", err)
+	}
+	if err := env.resolveSecrets(v); err != nil {
+		return nil, err
+	}
 	if env.AppEnv == "development" {
-		log.Println("The App is running in development environment")
+		slog.Info("The App is running in development environment")
 	}
+	return &env, nil
+}
 
-	return &env
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("REDIS_PORT", 6379)
+	v.SetDefault("SMTP_PORT", 587)
+	v.SetDefault("FAKE_EMAIL", false)
+	v.SetDefault("RATE_LIMIT", 1000)
+	v.SetDefault("RATE_LIMIT_KEY", "ip")
+	v.SetDefault("RATE_LIMIT_BACKEND", "redis")
+	v.SetDefault("REDIS_TIMEOUT_SEC", 5)
+	v.SetDefault("CACHE_DURATION_SEC", 600)
+	v.SetDefault("CACHE_BACKEND", "redis")
+	v.SetDefault("CACHE_MAX_ENTRIES", 1000)
+	v.SetDefault("CACHE_REQUIRED", false)
+	v.SetDefault("JOB_TIMEOUT_SEC", 60)
+	v.SetDefault("JOB_WORKERS", 2)
+	v.SetDefault("JOB_QUEUE_SIZE", 100)
+	v.SetDefault("JOB_QUEUE_BACKEND", "redis")
+	v.SetDefault("LOCK_BACKEND", "redis")
+	v.SetDefault("LOCK_TTL_SEC", 30)
+	v.SetDefault("JOB_VISIBILITY_TIMEOUT_SEC", 120)
+	v.SetDefault("JOB_MAX_ATTEMPTS", 3)
+	v.SetDefault("JOB_RETRY_BACKOFF_SEC", 5)
+	v.SetDefault("SCHEDULE_ENABLED", false)
+	v.SetDefault("SCHEDULE_CRON", "0 6 1 * *")
+	v.SetDefault("SCHEDULE_MAX_CATCH_UP", 3)
+	v.SetDefault("AUTH_ENABLED", true)
+	v.SetDefault("API_KEY_ROTATION_GRACE_SEC", 86400)
+	v.SetDefault("JWT_JWKS_REFRESH_SEC", 3600)
+	v.SetDefault("JWT_ACCOUNT_CLAIM", "accounts")
+	v.SetDefault("JWT_DEFAULT_SCOPES", "read")
+	v.SetDefault("IDEMPOTENCY_TTL_SEC", 86400)
+	v.SetDefault("IDEMPOTENCY_BACKEND", "redis")
+	v.SetDefault("OPENAPI_VALIDATE_RESPONSES", false)
+	v.SetDefault("HEALTH_CHECK_TIMEOUT_SEC", 2)
+	v.SetDefault("MIGRATE_ON_START", false)
+	v.SetDefault("SHUTDOWN_TIMEOUT_SEC", 30)
+	v.SetDefault("OTEL_SERVICE_NAME", "stori-test")
+	v.SetDefault("OTEL_TRACES_EXPORTER", "none")
+	v.SetDefault("OTEL_TRACES_SAMPLE_RATIO", 1.0)
+	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("LOG_FORMAT", "json")
+	v.SetDefault("LOG_REDACT_PII", false)
+	v.SetDefault("SECRETS_REFRESH_SEC", 300)
+	v.SetDefault("DB_DRIVER", "postgres")
+	v.SetDefault("DB_PORT", 5432)
+	v.SetDefault("DB_PATH", "stori.db")
+	v.SetDefault("SQLITE_BUSY_TIMEOUT_MS", 5000)
+	v.SetDefault("DEFAULT_ACCOUNT_ID", "default")
 }
 
-func (e *Env) Validate() error {
-	requiredFields := []string{
-		"APP_ENV",
-		"SERVER_ADDRESS",
-		"REDIS_HOST",
-		"EMAIL_FROM",
-		"EMAIL_TO",
-		"EMAIL_PASSWORD",
-		"SMTP_HOST",
-		"CSV_FILE_PATH",
-		"DB_HOST",
-		"DB_USER",
-		"DB_PASSWORD",
-		"DB_NAME",
-	}
-	for _, field := range requiredFields {
-		if viper.GetString(field) == "" {
-			return fmt.Errorf("required environment variable %s not set", field)
+// resolveSecrets reads the secrets kept in files and secret managers into
+// their fields. The Vault token, needed to read the others, can only be read
+// from a file.
+func (e *Env) resolveSecrets(v *viper.Viper) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	providers := map[string]SecretProvider{}
+	secrets := NewSecrets(providers)
+	if err := secrets.Add(ctx, "VAULT_TOKEN", e.VaultToken, v.GetString("VAULT_TOKEN_FILE")); err != nil {
+		return err
+	}
+	if e.VaultAddr != "" {
+		providers["vault"] = &VaultProvider{
+			Addr:   e.VaultAddr,
+			Token:  func() string { return secrets.Get("VAULT_TOKEN") },
+			Client: &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+
+	value := reflect.ValueOf(e).Elem()
+	for _, field := range fields() {
+		if field.Tag.Get("secret") != "true" {
+			continue
+		}
+		key := field.Tag.Get("mapstructure")
+		f := value.FieldByIndex(field.Index)
+		if key != "VAULT_TOKEN" {
+			if err := secrets.Add(ctx, key, f.String(), v.GetString(key+"_FILE")); err != nil {
+				return err
+			}
 		}
+		f.SetString(secrets.Get(key))
 	}
+	e.secrets = secrets
 	return nil
 }
+
+// Secrets returns the current values of the secret settings, which change
+// when secrets kept in files or secret managers are rotated.
+func (e *Env) Secrets() *Secrets {
+	if e.secrets == nil {
+		e.secrets = NewSecrets(nil)
+	}
+	return e.secrets
+}
+
+// Print writes the configuration to w as KEY=value lines, in dotenv format,
+// with the values of secrets masked.
+func (e *Env) Print(w io.Writer) error {
+	value := reflect.ValueOf(e).Elem()
+	for _, field := range fields() {
+		v := fmt.Sprint(value.FieldByIndex(field.Index).Interface())
+		if field.Tag.Get("secret") == "true" && v != "" {
+			v = "********"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", field.Tag.Get("mapstructure"), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields returns the settings of Env, in declaration order.
+func fields() []reflect.StructField {
+	t := reflect.TypeOf(Env{})
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.IsExported() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ScheduleAccountList returns the accounts with scheduled statement runs,
+// the default account unless SCHEDULE_ACCOUNTS lists them.
+func (e *Env) ScheduleAccountList() []string {
+	if accounts := splitList(e.ScheduleAccounts); len(accounts) > 0 {
+		return accounts
+	}
+	return []string{e.DefaultAccountID}
+}
+
+// RedisRequired reports whether the service cannot work without Redis: it
+// holds the job queue, the run leases or a cache that runs must store to.
+// Otherwise only rate limits, idempotency keys and the cache, which degrade
+// without it, may be kept there.
+func (e *Env) RedisRequired() bool {
+	return e.JobQueueBackend == "redis" || e.LockBackend == "redis" || (e.CacheBackend == "redis" && e.CacheRequired)
+}
+
+// RedisUsed reports whether anything is kept in Redis at all.
+func (e *Env) RedisUsed() bool {
+	return e.RedisRequired() || e.CacheBackend == "redis" || e.RateLimitBackend == "redis" || e.IdempotencyStore == "redis"
+}
+
+// ScheduleHolidayList returns the SCHEDULE_HOLIDAYS dates.
+func (e *Env) ScheduleHolidayList() []string {
+	return splitList(e.ScheduleHolidays)
+}
+
+// JWTDefaultScopeList returns the JWT_DEFAULT_SCOPES scopes.
+func (e *Env) JWTDefaultScopeList() []string {
+	return splitList(e.JWTDefaultScopes)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
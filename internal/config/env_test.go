@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Layers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+app_env: production
+server_address: ":8080"
+rate_limit: 50
+job_workers: 4
+db_host: db.internal
+`)
+	t.Setenv("JOB_WORKERS", "8")
+	t.Setenv("DB_HOST", "db.env")
+
+	env, err := Load("", []string{"--config", file, "--db-host", "db.flag"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "production", env.AppEnv)
+	assert.Equal(t, 50, env.RateLimit, "file over defaults")
+	assert.Equal(t, 8, env.JobWorkers, "environment over file")
+	assert.Equal(t, "db.flag", env.DBHost, "flags over environment")
+	assert.Equal(t, 5432, env.DBPort, "defaults")
+	assert.True(t, env.AuthEnabled, "unset boolean flags keep the default")
+}
+
+func TestLoad_ConfigFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.toml", "server_address = \":9090\"\njob_queue_backend = \"memory\"\n"},
+		{".env", "SERVER_ADDRESS=:9090\nJOB_QUEUE_BACKEND=memory\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeFile(t, tt.name, tt.content))
+
+			env, err := Load("", nil)
+			require.NoError(t, err)
+			assert.Equal(t, ":9090", env.ServerAddress)
+			assert.Equal(t, "memory", env.JobQueueBackend)
+		})
+	}
+}
+
+func TestLoad_ConfigFileMissing(t *testing.T) {
+	env, err := Load(filepath.Join(t.TempDir(), ".env"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "redis", env.JobQueueBackend)
+
+	_, err = Load("", []string{"--config", filepath.Join(t.TempDir(), "config.yaml")})
+	assert.ErrorContains(t, err, "reading config file")
+}
+
+func validEnv(t *testing.T) *Env {
+	t.Helper()
+	env, err := Load(writeFile(t, ".env", `
+APP_ENV=test
+SERVER_ADDRESS=:8080
+REDIS_HOST=localhost
+EMAIL_FROM=statements@stori.test
+EMAIL_TO=customer@example.com
+EMAIL_PASSWORD=secret
+SMTP_HOST=smtp.stori.test
+CSV_FILE_PATH=test/transactions.csv
+DB_HOST=localhost
+DB_USER=postgres
+DB_PASSWORD=postgres_password
+DB_NAME=stori
+`), nil)
+	require.NoError(t, err)
+	return env
+}
+
+func TestValidate(t *testing.T) {
+	env := validEnv(t)
+	require.NoError(t, env.Validate())
+
+	env.DBHost = ""
+	env.SMTPPort = 70000
+	env.EmailTo = "customer"
+	env.JWTJWKSURL = "not a url"
+	env.RateLimitKey = "user"
+	env.AuthBootstrapKey = "short"
+	env.ScheduleCron = "every day"
+
+	err := env.Validate()
+	require.Error(t, err)
+	for _, problem := range []string{
+		"DB_HOST is required",
+		"SMTP_PORT must be at most 65535, got 70000",
+		`EMAIL_TO must be an email address, got "customer"`,
+		`JWT_JWKS_URL must be a URL, got "not a url"`,
+		`RATE_LIMIT_KEY must be one of ip, client, account, got "user"`,
+		"AUTH_BOOTSTRAP_KEY must be at least 32 characters long",
+		`invalid SCHEDULE_CRON "every day"`,
+	} {
+		assert.ErrorContains(t, err, problem)
+	}
+	assert.NotContains(t, err.Error(), "short")
+}
+
+func TestValidate_PlaceholderBootstrapKey(t *testing.T) {
+	env := validEnv(t)
+	env.AuthBootstrapKey = "change-me-to-a-long-random-admin-key"
+	assert.EqualError(t, env.Validate(), "AUTH_BOOTSTRAP_KEY is the public example value, set a random one")
+}
+
+func TestValidate_SQLite(t *testing.T) {
+	env := validEnv(t)
+	env.DBDriver = "sqlite"
+	env.DBHost, env.DBUser, env.DBName = "", "", ""
+	env.DBPassword = ""
+	require.NoError(t, env.Validate(), "Postgres settings are not needed")
+
+	env.DBPath = ""
+	assert.EqualError(t, env.Validate(), "DB_PATH is required")
+
+	env.DBDriver = "mysql"
+	assert.ErrorContains(t, env.Validate(), `DB_DRIVER must be one of postgres, sqlite, got "mysql"`)
+}
+
+func TestRedisRequired(t *testing.T) {
+	env := validEnv(t)
+	assert.True(t, env.RedisRequired())
+
+	env.JobQueueBackend, env.LockBackend, env.CacheBackend = "memory", "memory", "memory"
+	assert.False(t, env.RedisRequired(), "rate limits and idempotency keys do without Redis")
+
+	env.CacheBackend = "redis"
+	assert.False(t, env.RedisRequired())
+	env.CacheRequired = true
+	assert.True(t, env.RedisRequired())
+}
+
+func TestRedisUsed(t *testing.T) {
+	env := validEnv(t)
+	assert.True(t, env.RedisUsed())
+
+	env.JobQueueBackend, env.LockBackend, env.CacheBackend = "memory", "memory", "memory"
+	assert.True(t, env.RedisUsed(), "rate limits and idempotency keys are kept in Redis")
+
+	env.RateLimitBackend, env.IdempotencyStore = "memory", "memory"
+	assert.False(t, env.RedisUsed())
+}
+
+func TestPrint(t *testing.T) {
+	env := validEnv(t)
+
+	var out bytes.Buffer
+	require.NoError(t, env.Print(&out))
+	assert.Contains(t, out.String(), "SERVER_ADDRESS=:8080\n")
+	assert.Contains(t, out.String(), "DB_PASSWORD=********\n")
+	assert.Contains(t, out.String(), "REDIS_PASSWORD=\n")
+	assert.NotContains(t, out.String(), "postgres_password")
+}
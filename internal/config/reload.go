@@ -0,0 +1,155 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// Reloader applies changes to the settings with a reload tag while the
+// service runs. A reload loads the configuration again and validates it; an
+// invalid one is rejected and the running configuration is kept. Changes
+// to other settings are reported as requiring a restart and not applied.
+type Reloader struct {
+	load func() (*Env, error)
+
+	mu       sync.Mutex
+	current  *Env
+	checks   []func(env *Env) error
+	onReload []func(env *Env)
+}
+
+// NewReloader returns a reloader of env, reloaded with load.
+func NewReloader(env *Env, load func() (*Env, error)) *Reloader {
+	return &Reloader{load: load, current: env}
+}
+
+// Check registers fn to reject a reloaded configuration before any of it is
+// applied, e.g. when it cannot be put into effect.
+func (r *Reloader) Check(fn func(env *Env) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, fn)
+}
+
+// OnReload registers fn to apply every reloaded configuration. It is given
+// the running configuration with the reloadable settings updated.
+func (r *Reloader) OnReload(fn func(env *Env)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReload = append(r.onReload, fn)
+}
+
+// Reload loads and applies the configuration, recording the outcome in the
+// audit log along with trigger, what asked for it (file, signal or api),
+// and the caller of ctx. It fails with domain.ErrUnprocessable when the
+// configuration is invalid.
+func (r *Reloader) Reload(ctx context.Context, trigger string) (*domain.ConfigReload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	audit := []any{"audit", true, "trigger", trigger}
+	if principal, ok := domain.PrincipalFromContext(ctx); ok {
+		audit = append(audit, "actor", principal.Subject)
+	}
+
+	next, err := r.load()
+	if err == nil {
+		err = next.Validate()
+	}
+	if err == nil {
+		for _, check := range r.checks {
+			if err = check(next); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "configuration reload rejected", append(audit, "error", err)...)
+		return nil, domain.NewError(domain.ErrUnprocessable, "invalid_config",
+			"the configuration is invalid: "+strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+
+	result := &domain.ConfigReload{Changed: []string{}, RestartRequired: []string{}}
+	updated := *r.current
+	current, reloaded, target := reflect.ValueOf(r.current).Elem(), reflect.ValueOf(next).Elem(), reflect.ValueOf(&updated).Elem()
+	for _, field := range fields() {
+		was, now := current.FieldByIndex(field.Index), reloaded.FieldByIndex(field.Index)
+		// Secrets are kept current by Secrets.
+		if field.Tag.Get("secret") == "true" || was.Equal(now) {
+			continue
+		}
+		key := field.Tag.Get("mapstructure")
+		if field.Tag.Get("reload") != "true" {
+			result.RestartRequired = append(result.RestartRequired, key)
+			continue
+		}
+		result.Changed = append(result.Changed, key)
+		target.FieldByIndex(field.Index).Set(now)
+	}
+	r.current = &updated
+	for _, apply := range r.onReload {
+		apply(r.current)
+	}
+
+	slog.InfoContext(ctx, "configuration reloaded", append(audit, "changed", result.Changed, "restart_required", result.RestartRequired)...)
+	return result, nil
+}
+
+// Current returns the running configuration.
+func (r *Reloader) Current() *Env {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Watch reloads the configuration whenever the config file it was loaded
+// from changes, until ctx is done. Changes are applied once the file has
+// not been written to for settle, as editors and deployments may replace
+// it in several steps. Without a config file it returns at once.
+func (r *Reloader) Watch(ctx context.Context, settle time.Duration) error {
+	file := r.Current().file
+	if file == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// The directory is watched, as files replaced by renaming one over them
+	// stop being watched.
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watching %s: %w", file, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if filepath.Clean(event.Name) == filepath.Clean(file) && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					timer = time.After(settle)
+				}
+			case err := <-watcher.Errors:
+				slog.WarnContext(ctx, "watching the config file failed", "file", file, "error", err)
+			case <-timer:
+				timer = nil
+				// A rejected reload has been logged.
+				_, _ = r.Reload(ctx, "file")
+			}
+		}
+	}()
+	return nil
+}
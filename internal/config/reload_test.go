@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requiredSettings are the settings without a default, for config files
+// to be valid.
+const requiredSettings = `
+app_env: test
+server_address: ":8080"
+redis_host: localhost
+email_from: statements@stori.test
+email_to: customer@example.com
+email_password: secret
+smtp_host: smtp.stori.test
+csv_file_path: test/transactions.csv
+db_host: localhost
+db_user: postgres
+db_password: postgres_password
+db_name: stori
+`
+
+// newFileReloader returns a reloader of a config file with the required
+// settings and content, and a function rewriting its content.
+func newFileReloader(t *testing.T, content string) (*Reloader, func(content string)) {
+	t.Helper()
+	file := writeFile(t, "config.yaml", requiredSettings+content)
+	env, err := Load("", []string{"--config", file})
+	require.NoError(t, err)
+	rewrite := func(content string) {
+		require.NoError(t, os.WriteFile(file, []byte(requiredSettings+content), 0o600))
+	}
+	return NewReloader(env, func() (*Env, error) { return Load("", []string{"--config", file}) }), rewrite
+}
+
+func TestReloader_Reload(t *testing.T) {
+	reloader, rewrite := newFileReloader(t, "rate_limit: 10\nlog_level: info\njob_workers: 2\n")
+	var applied *Env
+	reloader.OnReload(func(env *Env) { applied = env })
+
+	rewrite("rate_limit: 20\nlog_level: debug\njob_workers: 4\n")
+	result, err := reloader.Reload(context.Background(), "api")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"RATE_LIMIT", "LOG_LEVEL"}, result.Changed)
+	assert.Equal(t, []string{"JOB_WORKERS"}, result.RestartRequired)
+	require.NotNil(t, applied)
+	assert.Equal(t, 20, applied.RateLimit)
+	assert.Equal(t, "debug", applied.LogLevel)
+	assert.Equal(t, 2, applied.JobWorkers, "settings requiring a restart are not applied")
+	assert.Same(t, applied, reloader.Current())
+}
+
+func TestReloader_RejectsInvalidConfig(t *testing.T) {
+	reloader, rewrite := newFileReloader(t, "rate_limit: 10\n")
+	running := reloader.Current()
+	reloader.OnReload(func(*Env) { t.Error("an invalid configuration was applied") })
+
+	rewrite("rate_limit: 0\n")
+	_, err := reloader.Reload(context.Background(), "api")
+	assert.ErrorIs(t, err, domain.ErrUnprocessable)
+	assert.ErrorContains(t, err, "RATE_LIMIT")
+	assert.Same(t, running, reloader.Current())
+}
+
+func TestReloader_Check(t *testing.T) {
+	reloader, rewrite := newFileReloader(t, "rate_limit_routes: \"\"\n")
+	reloader.Check(func(env *Env) error {
+		if env.RateLimitRoutes != "" {
+			return errors.New("invalid RATE_LIMIT_ROUTES")
+		}
+		return nil
+	})
+
+	rewrite("rate_limit_routes: \"GET /jobs/:id=often\"\n")
+	_, err := reloader.Reload(context.Background(), "signal")
+	assert.ErrorContains(t, err, "invalid RATE_LIMIT_ROUTES")
+	assert.Empty(t, reloader.Current().RateLimitRoutes)
+}
+
+func TestReloader_Watch(t *testing.T) {
+	reloader, rewrite := newFileReloader(t, "cache_duration_sec: 60\n")
+	applied := make(chan *Env, 1)
+	reloader.OnReload(func(env *Env) { applied <- env })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, reloader.Watch(ctx, 10*time.Millisecond))
+
+	rewrite("cache_duration_sec: 120\n")
+	select {
+	case env := <-applied:
+		assert.Equal(t, 120, env.CacheDurationSec)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change to the config file was not applied")
+	}
+}
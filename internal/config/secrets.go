@@ -0,0 +1,155 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider looks up secrets kept outside the configuration, such as
+// in a secret manager.
+type SecretProvider interface {
+	// Secret returns the secret ref points to.
+	Secret(ctx context.Context, ref string) (string, error)
+}
+
+// Secrets holds the current values of the secret settings, those with a
+// secret tag. A secret is read from the file named by its *_FILE variable
+// when set, e.g. DB_PASSWORD_FILE, and from a provider when its value has the
+// form <scheme>:<ref>, e.g. vault:secret/data/stori#db_password; otherwise
+// its value is used as is. Secrets read from files or providers can be
+// refreshed without a restart.
+type Secrets struct {
+	providers map[string]SecretProvider
+
+	mu       sync.RWMutex
+	secrets  map[string]*secret
+	onChange []func(key, value string)
+}
+
+type secret struct {
+	// file or ref is where the secret is read from; neither is set for
+	// secrets given as plain values.
+	file  string
+	ref   string
+	value string
+}
+
+// NewSecrets returns secrets resolved by providers, keyed by the scheme of
+// the references they resolve.
+func NewSecrets(providers map[string]SecretProvider) *Secrets {
+	return &Secrets{providers: providers, secrets: map[string]*secret{}}
+}
+
+// Add resolves the secret key, read from file when not empty and otherwise
+// from value, which may be a provider reference.
+func (s *Secrets) Add(ctx context.Context, key, value, file string) error {
+	sec := &secret{file: file, value: value}
+	if file == "" {
+		if scheme, ref, ok := strings.Cut(value, ":"); ok && s.providers[scheme] != nil {
+			sec.ref = scheme + ":" + ref
+		}
+	}
+	if sec.file != "" || sec.ref != "" {
+		resolved, err := s.resolve(ctx, sec)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", key, err)
+		}
+		sec.value = resolved
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[key] = sec
+	return nil
+}
+
+// Get returns the current value of the secret key.
+func (s *Secrets) Get(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sec, ok := s.secrets[key]; ok {
+		return sec.value
+	}
+	return ""
+}
+
+// OnChange registers fn to be called with the new value of every secret
+// that changes on refresh.
+func (s *Secrets) OnChange(fn func(key, value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+// Refresh reads again the secrets kept in files and providers. A secret
+// that cannot be read keeps its value.
+func (s *Secrets) Refresh(ctx context.Context) error {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.secrets))
+	for key, sec := range s.secrets {
+		if sec.file != "" || sec.ref != "" {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+
+	var errs []error
+	for _, key := range keys {
+		s.mu.RLock()
+		sec := *s.secrets[key]
+		s.mu.RUnlock()
+
+		value, err := s.resolve(ctx, &sec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resolving %s: %w", key, err))
+			continue
+		}
+		if value == sec.value {
+			continue
+		}
+
+		s.mu.Lock()
+		s.secrets[key].value = value
+		onChange := s.onChange
+		s.mu.Unlock()
+		slog.InfoContext(ctx, "secret rotated", "key", key)
+		for _, fn := range onChange {
+			fn(key, value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Watch refreshes the secrets every interval until ctx is done.
+func (s *Secrets) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "refreshing secrets failed", "error", err)
+			}
+		}
+	}
+}
+
+func (s *Secrets) resolve(ctx context.Context, sec *secret) (string, error) {
+	if sec.file != "" {
+		content, err := os.ReadFile(sec.file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	scheme, ref, _ := strings.Cut(sec.ref, ":")
+	return s.providers[scheme].Secret(ctx, ref)
+}
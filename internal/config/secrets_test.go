@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vaultStandIn serves KV version 2 secrets the way Vault does, to requests
+// carrying token.
+type vaultStandIn struct {
+	mu      sync.Mutex
+	token   string
+	secrets map[string]map[string]string
+}
+
+func (v *vaultStandIn) set(path, field, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.secrets[path] == nil {
+		v.secrets[path] = map[string]string{}
+	}
+	v.secrets[path][field] = value
+}
+
+func (v *vaultStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if r.Header.Get("X-Vault-Token") != v.token {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	data, ok := v.secrets[strings.TrimPrefix(r.URL.Path, "/v1/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}},
+	})
+}
+
+func newVault(t *testing.T) (*vaultStandIn, *httptest.Server) {
+	vault := &vaultStandIn{token: "s.root", secrets: map[string]map[string]string{}}
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+	return vault, server
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "from-file\n"))
+
+	env, err := Load("", []string{"--redis-password-file", writeFile(t, "redis_password", "redis-secret")})
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", env.DBPassword)
+	assert.Equal(t, "redis-secret", env.RedisPassword)
+	assert.Equal(t, "from-file", env.Secrets().Get("DB_PASSWORD"))
+
+	t.Setenv("EMAIL_PASSWORD_FILE", "/nonexistent/email_password")
+	_, err = Load("", nil)
+	assert.ErrorContains(t, err, "resolving EMAIL_PASSWORD")
+}
+
+func TestLoad_Vault(t *testing.T) {
+	vault, server := newVault(t)
+	vault.set("secret/data/stori", "db_password", "from-vault")
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN_FILE", writeFile(t, "vault_token", "s.root"))
+	t.Setenv("DB_PASSWORD", "vault:secret/data/stori#db_password")
+	t.Setenv("EMAIL_PASSWORD", "plain:text")
+
+	env, err := Load("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "from-vault", env.DBPassword)
+	assert.Equal(t, "plain:text", env.EmailPassword, "values of unknown schemes are used as is")
+
+	t.Setenv("DB_PASSWORD", "vault:secret/data/stori#missing")
+	_, err = Load("", nil)
+	assert.ErrorContains(t, err, `vault: secret/data/stori has no field "missing"`)
+}
+
+func TestSecrets_Refresh(t *testing.T) {
+	vault, server := newVault(t)
+	vault.set("secret/data/stori", "db_password", "v1")
+	passwordFile := writeFile(t, "email_password", "e1")
+
+	secrets := NewSecrets(map[string]SecretProvider{
+		"vault": &VaultProvider{Addr: server.URL, Token: func() string { return "s.root" }},
+	})
+	ctx := context.Background()
+	require.NoError(t, secrets.Add(ctx, "DB_PASSWORD", "vault:secret/data/stori#db_password", ""))
+	require.NoError(t, secrets.Add(ctx, "EMAIL_PASSWORD", "", passwordFile))
+	require.NoError(t, secrets.Add(ctx, "REDIS_PASSWORD", "static", ""))
+
+	changed := map[string]string{}
+	secrets.OnChange(func(key, value string) { changed[key] = value })
+
+	require.NoError(t, secrets.Refresh(ctx))
+	assert.Empty(t, changed)
+
+	vault.set("secret/data/stori", "db_password", "v2")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("e2"), 0o600))
+	require.NoError(t, secrets.Refresh(ctx))
+	assert.Equal(t, map[string]string{"DB_PASSWORD": "v2", "EMAIL_PASSWORD": "e2"}, changed)
+	assert.Equal(t, "v2", secrets.Get("DB_PASSWORD"))
+	assert.Equal(t, "static", secrets.Get("REDIS_PASSWORD"))
+
+	// A secret that cannot be read keeps its value.
+	require.NoError(t, os.Remove(passwordFile))
+	assert.Error(t, secrets.Refresh(ctx))
+	assert.Equal(t, "e2", secrets.Get("EMAIL_PASSWORD"))
+}
+
+type failingProvider struct{}
+
+func (failingProvider) Secret(context.Context, string) (string, error) {
+	return "", errors.New("sealed")
+}
+
+func TestSecrets_ProviderError(t *testing.T) {
+	secrets := NewSecrets(map[string]SecretProvider{"kms": failingProvider{}})
+	err := secrets.Add(context.Background(), "DB_PASSWORD", "kms:db", "")
+	assert.EqualError(t, err, "resolving DB_PASSWORD: sealed")
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/robfig/cron/v3"
+)
+
+// placeholderBootstrapKey is the AUTH_BOOTSTRAP_KEY example of earlier
+// .env.example files, which is public and must never be an admin key.
+const placeholderBootstrapKey = "change-me-to-a-long-random-admin-key"
+
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+	return v
+}()
+
+// Validate checks every field against its validate tag, then the settings
+// that depend on each other, and reports every problem found.
+func (e *Env) Validate() error {
+	var errs []error
+	var fieldErrs validator.ValidationErrors
+	if err := validate.Struct(e); errors.As(err, &fieldErrs) {
+		for _, fieldErr := range fieldErrs {
+			errs = append(errs, fieldError(fieldErr))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := cron.ParseStandard(e.ScheduleCron); e.ScheduleCron != "" && err != nil {
+		errs = append(errs, fmt.Errorf("invalid SCHEDULE_CRON %q: %w", e.ScheduleCron, err))
+	}
+	for _, day := range e.ScheduleHolidayList() {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			errs = append(errs, fmt.Errorf("invalid date %q in SCHEDULE_HOLIDAYS, expected YYYY-MM-DD", day))
+		}
+	}
+	if e.JWTJWKSURL != "" && e.JWTJWKSFile != "" {
+		errs = append(errs, errors.New("set only one of JWT_JWKS_URL and JWT_JWKS_FILE"))
+	}
+	for _, scope := range e.JWTDefaultScopeList() {
+		if scope != "ingest" && scope != "read" && scope != "admin" {
+			errs = append(errs, fmt.Errorf("invalid scope %q in JWT_DEFAULT_SCOPES, expected ingest, read or admin", scope))
+		}
+	}
+	if e.AuthBootstrapKey == placeholderBootstrapKey {
+		errs = append(errs, errors.New("AUTH_BOOTSTRAP_KEY is the public example value, set a random one"))
+	}
+	if e.DKIMKeyPath != "" && (e.DKIMDomain == "" || e.DKIMSelector == "") {
+		errs = append(errs, errors.New("DKIM_DOMAIN and DKIM_SELECTOR are required when DKIM_PRIVATE_KEY_PATH is set"))
+	}
+	return errors.Join(errs...)
+}
+
+// fieldError describes a failed validate tag in terms of the variable.
+func fieldError(err validator.FieldError) error {
+	key := err.Field()
+	switch err.Tag() {
+	case "required", "required_if":
+		return fmt.Errorf("%s is required", key)
+	case "min":
+		if err.Kind() == reflect.String {
+			return fmt.Errorf("%s must be at least %s characters long", key, err.Param())
+		}
+		return fmt.Errorf("%s must be at least %s, got %v", key, err.Param(), err.Value())
+	case "max":
+		return fmt.Errorf("%s must be at most %s, got %v", key, err.Param(), err.Value())
+	case "oneof":
+		return fmt.Errorf("%s must be one of %s, got %q", key, strings.ReplaceAll(err.Param(), " ", ", "), err.Value())
+	case "url":
+		return fmt.Errorf("%s must be a URL, got %q", key, err.Value())
+	case "email":
+		return fmt.Errorf("%s must be an email address, got %q", key, err.Value())
+	case "hostname_port":
+		return fmt.Errorf("%s must be [host]:port, got %q", key, err.Value())
+	case "hostname_rfc1123", "fqdn":
+		return fmt.Errorf("%s must be a host name, got %q", key, err.Value())
+	}
+	return fmt.Errorf("%s is invalid (%s), got %q", key, err.Tag(), err.Value())
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// VaultProvider reads secrets from the HTTP API of HashiCorp Vault or a
+// compatible server. References have the form <path>#<field>, the path
+// being that of the API without the /v1/ prefix, e.g.
+// secret/data/stori#db_password for the db_password field of a KV version 2
+// secret; KV version 1 paths are read the same way.
+type VaultProvider struct {
+	Addr string
+	// Token returns the token sent in the X-Vault-Token header, read again
+	// on every lookup so that it can be rotated.
+	Token  func() string
+	Client *http.Client
+}
+
+func (p *VaultProvider) Secret(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid vault reference %q, expected <path>#<field>", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(p.Addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token())
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: reading %s: %s", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: reading %s: %w", path, err)
+	}
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+		data = nested
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault: %s has no field %q", path, field)
+	}
+	return value, nil
+}
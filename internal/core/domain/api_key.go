@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// API key scopes: what a key is allowed to call.
+const (
+	ScopeIngest = "ingest"
+	ScopeRead   = "read"
+	ScopeAdmin  = "admin"
+)
+
+// Scopes are all the scopes a key can be granted.
+var Scopes = []string{ScopeIngest, ScopeRead, ScopeAdmin}
+
+// APIKey is a client credential. Only the SHA-256 Hash of the key is stored;
+// Prefix, its first characters, identifies it in listings and logs.
+type APIKey struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Hash        string     `json:"-" gorm:"uniqueIndex"`
+	Scopes      []string   `json:"scopes" gorm:"serializer:json"`
+	RotatedFrom string     `json:"rotated_from,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Principal is the caller authenticated by the key. Keys are service
+// credentials, so they are not restricted to accounts.
+func (k *APIKey) Principal() *Principal {
+	return &Principal{Subject: "api_key:" + k.ID, Scopes: k.Scopes, AllAccounts: true}
+}
+
+// Active reports whether the key can be used at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// APIKeyRequest asks for a key named Name with Scopes, expiring after TTLSec
+// seconds unless it is 0.
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	TTLSec int      `json:"ttl_sec,omitempty"`
+}
+
+// IssuedAPIKey is a newly created key with its secret, which is only ever
+// returned here.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
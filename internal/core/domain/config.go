@@ -0,0 +1,8 @@
+package domain
+
+// ConfigReload is the outcome of a configuration reload: the settings that
+// were applied, and those that changed but only take effect on restart.
+type ConfigReload struct {
+	Changed         []string `json:"changed"`
+	RestartRequired []string `json:"restart_required"`
+}
@@ -0,0 +1,65 @@
+package domain
+
+import "errors"
+
+// Error kinds. Errors surfaced to API clients wrap one of them, which decides
+// the response status; anything else is an internal error.
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	// ErrNotFound is returned by repositories when the requested record does not exist.
+	ErrNotFound       = errors.New("not found")
+	ErrSourceNotFound = errors.New("source not found")
+	ErrConflict       = errors.New("conflict")
+	ErrUnprocessable  = errors.New("unprocessable request")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrUnavailable    = errors.New("upstream unavailable")
+)
+
+// Error is an error of a known Kind with a stable Code and a Message that is
+// safe to show to clients. The underlying Err, which may mention hosts, paths
+// or queries, is only part of Error().
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Err     error
+}
+
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func WrapError(kind error, code, message string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// ErrRunExists is returned when an account already has a schedule run for
+// the same scheduled time, e.g. recorded by another instance.
+var ErrRunExists = errors.New("schedule run already exists")
+
+// ErrRunInProgress is returned when another run is already processing the
+// same account and file.
+var ErrRunInProgress = NewError(ErrConflict, "run_in_progress", "a run for this account is already in progress")
+
+// ErrStaleLease is returned when a write is fenced off because a newer lease
+// holder has written since the caller's lease was granted.
+var ErrStaleLease = NewError(ErrConflict, "stale_lease", "processing lease is stale")
+
+// ErrLeaseLost is returned when a run's lease expired before it finished.
+var ErrLeaseLost = NewError(ErrConflict, "lease_lost", "processing lease lost")
@@ -0,0 +1,37 @@
+package domain
+
+// Statuses of a dependency check.
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// Statuses of the service as a whole: degraded when only optional
+// dependencies are down, unavailable when a required one is.
+const (
+	HealthReady       = "ready"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+// DependencyStatus is the outcome of checking one dependency.
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	Required  bool   `json:"required"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	// Details are what the check found out, e.g. the schema version.
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthReport is the status of the service and of each dependency, keyed by
+// dependency name.
+type HealthReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// Ready reports whether every required dependency is up.
+func (r HealthReport) Ready() bool {
+	return r.Status != HealthUnavailable
+}
@@ -0,0 +1,13 @@
+package domain
+
+// IdempotencyRecord tracks a request made with an Idempotency-Key: while
+// Completed is false it is still being handled by the holder of Token, and
+// afterwards it holds the response to replay.
+type IdempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`
+	Token       string            `json:"token"`
+	Completed   bool              `json:"completed"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
@@ -0,0 +1,64 @@
+package domain
+
+import "time"
+
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateRetrying  = "retrying"
+	JobStateFailed    = "failed"
+)
+
+// ProcessRequest asks for the transactions of an account to be ingested and
+// its statement sent. An empty AccountID means the default account; a Period
+// (2006-01) restricts the statement to the transactions of that month.
+type ProcessRequest struct {
+	AccountID string `json:"account_id"`
+	Period    string `json:"period,omitempty"`
+}
+
+// StageTiming records how long one stage of the processing pipeline took.
+type StageTiming struct {
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ProcessResult describes a run of the processing pipeline. It is returned
+// even when the run fails, covering the stages reached so far.
+type ProcessResult struct {
+	AccountID   string        `json:"account_id"`
+	Stages      []StageTiming `json:"stages"`
+	RowsRead    int           `json:"rows_read"`
+	RowsSaved   int           `json:"rows_saved"`
+	CacheHit    bool          `json:"cache_hit"`
+	StatementID *int          `json:"statement_id,omitempty"`
+}
+
+// Job is an asynchronous run of the processing pipeline.
+type Job struct {
+	ID          string        `json:"id" gorm:"primaryKey"`
+	AccountID   string        `json:"account_id"`
+	Period      string        `json:"period,omitempty"`
+	State       string        `json:"state"`
+	Stages      []StageTiming `json:"stages" gorm:"serializer:json"`
+	RowsRead    int           `json:"rows_read"`
+	RowsSaved   int           `json:"rows_saved"`
+	CacheHit    bool          `json:"cache_hit"`
+	StatementID *int          `json:"statement_id,omitempty"`
+	Attempts    int           `json:"attempts"`
+	Error       string        `json:"error,omitempty"`
+	RequestID   string        `json:"request_id,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	StartedAt   *time.Time    `json:"started_at,omitempty"`
+	FinishedAt  *time.Time    `json:"finished_at,omitempty"`
+}
+
+// JobFilter narrows a job listing; zero values are ignored.
+type JobFilter struct {
+	AccountID string
+	State     string
+	Limit     int
+}
@@ -0,0 +1,12 @@
+package domain
+
+// Fence identifies the lease a write is made under. Tokens grow with every
+// lease granted on Key, so storage can reject writes from an older holder.
+type Fence struct {
+	Key   string `gorm:"primaryKey"`
+	Token int64
+}
+
+func (Fence) TableName() string {
+	return "lock_fences"
+}
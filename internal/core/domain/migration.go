@@ -0,0 +1,8 @@
+package domain
+
+// MigrationStatus describes the database schema: the latest migration
+// applied and those not applied yet, by version.
+type MigrationStatus struct {
+	Version int64   `json:"version"`
+	Pending []int64 `json:"pending"`
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "context"
+
+// Principal is the authenticated caller of a request: an API key or the
+// subject of a bearer token. Callers are restricted to Accounts unless
+// AllAccounts is set.
+type Principal struct {
+	Subject     string
+	Scopes      []string
+	Accounts    []string
+	AllAccounts bool
+}
+
+// HasScope reports whether the caller was granted scope. Admins hold every
+// scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether the caller may act on accountID.
+func (p *Principal) CanAccess(accountID string) bool {
+	if p.AllAccounts {
+		return true
+	}
+	for _, account := range p.Accounts {
+		if account == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the caller of the request.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller carried by ctx, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
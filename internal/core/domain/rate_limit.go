@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// RateLimit allows Rate requests per Period on average, and bursts of up to
+// Burst requests.
+type RateLimit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// RateLimitResult is the outcome of checking a request against a RateLimit.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until a denied request would be allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the limit is fully replenished.
+	ResetAfter time.Duration
+}
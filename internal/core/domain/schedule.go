@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+// ScheduleRun records a scheduled statement run for one account, whether it
+// was submitted on time or caught up after downtime.
+type ScheduleRun struct {
+	ID           int       `json:"id" gorm:"primaryKey"`
+	AccountID    string    `json:"account_id" gorm:"uniqueIndex:idx_schedule_runs_account_time"`
+	Period       string    `json:"period"`
+	ScheduledFor time.Time `json:"scheduled_for" gorm:"uniqueIndex:idx_schedule_runs_account_time"`
+	CatchUp      bool      `json:"catch_up"`
+	JobID        string    `json:"job_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ScheduleRunFilter narrows a run listing; zero values are ignored.
+type ScheduleRunFilter struct {
+	// Accounts restricts the runs to these accounts when not nil; an empty
+	// non-nil slice matches no run.
+	Accounts []string
+	Limit    int
+}
+
+// BusinessCalendar tells business days from weekends and holidays.
+type BusinessCalendar struct {
+	// Holidays holds dates formatted as 2006-01-02.
+	Holidays map[string]bool
+}
+
+func NewBusinessCalendar(holidays []string) BusinessCalendar {
+	calendar := BusinessCalendar{Holidays: make(map[string]bool, len(holidays))}
+	for _, day := range holidays {
+		calendar.Holidays[day] = true
+	}
+	return calendar
+}
+
+func (c BusinessCalendar) IsBusinessDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.Holidays[t.Format("2006-01-02")]
+}
+
+// NextBusinessDay returns t if it falls on a business day, otherwise the same
+// time of day on the next business day.
+func (c BusinessCalendar) NextBusinessDay(t time.Time) time.Time {
+	for !c.IsBusinessDay(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
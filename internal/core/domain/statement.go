@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+const (
+	StatementStatusPending = "pending"
+	StatementStatusSent    = "sent"
+	StatementStatusFailed  = "failed"
+)
+
+// RenderedEmail is a statement rendered from the summary templates, ready to
+// be previewed or sent to To.
+type RenderedEmail struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// PreviewRequest selects the transactions a statement preview is rendered
+// from: either the given transactions (e.g. parsed from an uploaded CSV) or,
+// when Transactions is nil, the ones stored for AccountID.
+type PreviewRequest struct {
+	AccountID    string
+	Transactions []Transaction
+}
+
+// Statement is the archived record of a statement email, kept whether or not
+// delivery succeeded so it can be looked up and resent later.
+type Statement struct {
+	ID           int                    `json:"id" gorm:"primaryKey"`
+	AccountID    string                 `json:"account_id"`
+	Recipient    string                 `json:"recipient"`
+	Subject      string                 `json:"subject"`
+	Period       string                 `json:"period"`
+	Summary      map[string]interface{} `json:"summary" gorm:"serializer:json"`
+	HTMLBody     string                 `json:"html_body,omitempty"`
+	TextBody     string                 `json:"text_body,omitempty"`
+	BodyHash     string                 `json:"body_hash"`
+	MessageID    string                 `json:"message_id,omitempty"`
+	Status       string                 `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	ResentFromID *int                   `json:"resent_from_id,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	SentAt       *time.Time             `json:"sent_at,omitempty"`
+}
+
+// Email rebuilds the email that was (or will be) sent for the statement.
+func (s *Statement) Email() *RenderedEmail {
+	return &RenderedEmail{
+		To:      s.Recipient,
+		Subject: s.Subject,
+		HTML:    s.HTMLBody,
+		Text:    s.TextBody,
+	}
+}
+
+// StatementFilter narrows a statement listing; zero values are ignored.
+// Since and Until bound the creation time. NotResent keeps the statements
+// that have not been resent yet.
+type StatementFilter struct {
+	AccountID string
+	Recipient string
+	Period    string
+	Status    string
+	Since     *time.Time
+	Until     *time.Time
+	NotResent bool
+	Limit     int
+	Offset    int
+}
@@ -1,7 +1,8 @@
 package domain
 
 type Transaction struct {
-	ID     int     `json:"id" gorm:"primaryKey"`
-	Date   string  `json:"date"`
-	Amount float64 `json:"amount"`
+	ID        int     `json:"id" gorm:"primaryKey"`
+	AccountID string  `json:"account_id"`
+	Date      string  `json:"date"`
+	Amount    float64 `json:"amount"`
 }
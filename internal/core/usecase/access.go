@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// canAccess reports whether the caller of ctx may see the records of
+// accountID. Calls made outside of a request, by the scheduler, the job
+// workers or the command line, carry no caller and see every account.
+func canAccess(ctx context.Context, accountID string) bool {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	return !ok || principal.CanAccess(accountID)
+}
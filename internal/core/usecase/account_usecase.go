@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+type AccountUseCase interface {
+	// ListTransactions returns the transactions stored for an account, only
+	// those of period (2006-01) if set.
+	ListTransactions(ctx context.Context, accountID, period string) ([]domain.Transaction, error)
+	// GetSummary returns the statement summary of an account's transactions,
+	// only those of period (2006-01) if set.
+	GetSummary(ctx context.Context, accountID, period string) (map[string]interface{}, error)
+}
+
+type accountUseCaseImpl struct {
+	DBRepo TransactionRepository
+}
+
+func NewAccountUseCase(dbRepo TransactionRepository) AccountUseCase {
+	return &accountUseCaseImpl{DBRepo: dbRepo}
+}
+
+func (uc *accountUseCaseImpl) ListTransactions(ctx context.Context, accountID, period string) ([]domain.Transaction, error) {
+	transactions, err := uc.DBRepo.GetTransactionsByAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if period != "" {
+		transactions = transactionsInPeriod(transactions, period)
+	}
+	if transactions == nil {
+		transactions = []domain.Transaction{}
+	}
+	return transactions, nil
+}
+
+func (uc *accountUseCaseImpl) GetSummary(ctx context.Context, accountID, period string) (map[string]interface{}, error) {
+	transactions, err := uc.ListTransactions(ctx, accountID, period)
+	if err != nil {
+		return nil, err
+	}
+	return generateHTMLSummary(transactions), nil
+}
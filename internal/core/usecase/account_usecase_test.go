@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountUseCase(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	useCase := NewAccountUseCase(mockDBRepo)
+
+	transactions := []domain.Transaction{
+		{ID: 1, AccountID: "acc-1", Date: "3/4", Amount: 60.5},
+		{ID: 2, AccountID: "acc-1", Date: "3/9", Amount: -10.3},
+		{ID: 3, AccountID: "acc-1", Date: "4/2", Amount: 20},
+	}
+	mockDBRepo.On("GetTransactionsByAccount", mock.Anything, "acc-1").Return(transactions, nil)
+	mockDBRepo.On("GetTransactionsByAccount", mock.Anything, "acc-2").Return(nil, nil)
+
+	listed, err := useCase.ListTransactions(context.Background(), "acc-1", "2024-03")
+	require.NoError(t, err)
+	assert.Equal(t, transactions[:2], listed)
+
+	listed, err = useCase.ListTransactions(context.Background(), "acc-2", "")
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Transaction{}, listed)
+
+	summary, err := useCase.GetSummary(context.Background(), "acc-1", "")
+	require.NoError(t, err)
+	assert.InDelta(t, 70.2, summary["TotalBalance"], 0.001)
+	assert.Len(t, summary["MonthlyData"], 2)
+}
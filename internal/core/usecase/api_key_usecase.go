@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+const (
+	apiKeyPrefix = "stori_"
+	// apiKeyPrefixLen is how much of a key is kept in the clear to tell keys
+	// apart: the "stori_" marker and 8 hex characters.
+	apiKeyPrefixLen = len(apiKeyPrefix) + 8
+)
+
+var errInvalidAPIKey = domain.NewError(domain.ErrUnauthorized, "invalid_api_key", "invalid, expired or revoked API key")
+
+type APIKeyUseCase interface {
+	CreateKey(ctx context.Context, req domain.APIKeyRequest) (*domain.IssuedAPIKey, error)
+	ListKeys(ctx context.Context) ([]domain.APIKey, error)
+	// RotateKey issues a replacement for a key, with the same name, scopes
+	// and lifetime; the old key keeps working for the rotation grace period.
+	RotateKey(ctx context.Context, id string) (*domain.IssuedAPIKey, error)
+	RevokeKey(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, key string) (*domain.APIKey, error)
+	// EnsureKey stores key with the given name and scopes unless it is
+	// already stored.
+	EnsureKey(ctx context.Context, name, key string, scopes []string) error
+}
+
+type APIKeyRepository interface {
+	CreateKey(ctx context.Context, key *domain.APIKey) error
+	UpdateKey(ctx context.Context, key *domain.APIKey) error
+	GetKey(ctx context.Context, id string) (*domain.APIKey, error)
+	GetKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error)
+	ListKeys(ctx context.Context) ([]domain.APIKey, error)
+}
+
+type apiKeyUseCaseImpl struct {
+	KeyRepo       APIKeyRepository
+	RotationGrace time.Duration
+}
+
+func NewAPIKeyUseCase(keyRepo APIKeyRepository, rotationGraceSec int) APIKeyUseCase {
+	return &apiKeyUseCaseImpl{
+		KeyRepo:       keyRepo,
+		RotationGrace: time.Duration(rotationGraceSec) * time.Second,
+	}
+}
+
+func (uc *apiKeyUseCaseImpl) CreateKey(ctx context.Context, req domain.APIKeyRequest) (*domain.IssuedAPIKey, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, domain.NewError(domain.ErrInvalidInput, "invalid_name", "name is required")
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+	if req.TTLSec < 0 {
+		return nil, domain.NewError(domain.ErrInvalidInput, "invalid_ttl_sec", "ttl_sec must be a non-negative integer")
+	}
+
+	var expiresAt *time.Time
+	if req.TTLSec > 0 {
+		t := time.Now().Add(time.Duration(req.TTLSec) * time.Second)
+		expiresAt = &t
+	}
+	return uc.issue(ctx, strings.TrimSpace(req.Name), req.Scopes, expiresAt, "")
+}
+
+func (uc *apiKeyUseCaseImpl) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return uc.KeyRepo.ListKeys(ctx)
+}
+
+func (uc *apiKeyUseCaseImpl) RotateKey(ctx context.Context, id string) (*domain.IssuedAPIKey, error) {
+	old, err := uc.getKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return nil, domain.NewError(domain.ErrConflict, "api_key_inactive", "only active API keys can be rotated")
+	}
+
+	var expiresAt *time.Time
+	if old.ExpiresAt != nil {
+		t := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		expiresAt = &t
+	}
+	issued, err := uc.issue(ctx, old.Name, old.Scopes, expiresAt, old.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	graceEnd := now.Add(uc.RotationGrace)
+	if old.ExpiresAt == nil || graceEnd.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &graceEnd
+	}
+	if err := uc.KeyRepo.UpdateKey(ctx, old); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+func (uc *apiKeyUseCaseImpl) RevokeKey(ctx context.Context, id string) error {
+	key, err := uc.getKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return uc.KeyRepo.UpdateKey(ctx, key)
+}
+
+// Authenticate returns the stored key matching key, failing with
+// domain.ErrUnauthorized if there is none or it is expired or revoked.
+func (uc *apiKeyUseCaseImpl) Authenticate(ctx context.Context, key string) (*domain.APIKey, error) {
+	stored, err := uc.KeyRepo.GetKeyByHash(ctx, hashAPIKey(key))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !stored.Active(time.Now()) {
+		return nil, errInvalidAPIKey
+	}
+	return stored, nil
+}
+
+func (uc *apiKeyUseCaseImpl) EnsureKey(ctx context.Context, name, key string, scopes []string) error {
+	_, err := uc.KeyRepo.GetKeyByHash(ctx, hashAPIKey(key))
+	if !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+	return uc.KeyRepo.CreateKey(ctx, &domain.APIKey{
+		ID:     uuid.NewString(),
+		Name:   name,
+		Prefix: keyPrefix(key),
+		Hash:   hashAPIKey(key),
+		Scopes: scopes,
+	})
+}
+
+// issue generates and stores a new key, returning it with its secret.
+func (uc *apiKeyUseCaseImpl) issue(ctx context.Context, name string, scopes []string, expiresAt *time.Time, rotatedFrom string) (*domain.IssuedAPIKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	stored := domain.APIKey{
+		ID:          uuid.NewString(),
+		Name:        name,
+		Prefix:      keyPrefix(key),
+		Hash:        hashAPIKey(key),
+		Scopes:      scopes,
+		RotatedFrom: rotatedFrom,
+		ExpiresAt:   expiresAt,
+	}
+	if err := uc.KeyRepo.CreateKey(ctx, &stored); err != nil {
+		return nil, err
+	}
+	return &domain.IssuedAPIKey{APIKey: stored, Key: key}, nil
+}
+
+func (uc *apiKeyUseCaseImpl) getKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	key, err := uc.KeyRepo.GetKey(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.WrapError(domain.ErrNotFound, "api_key_not_found", "API key not found", err)
+	}
+	return key, err
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return domain.NewError(domain.ErrInvalidInput, "invalid_scopes", "at least one scope is required")
+	}
+	for _, scope := range scopes {
+		valid := false
+		for _, known := range domain.Scopes {
+			valid = valid || scope == known
+		}
+		if !valid {
+			return domain.NewError(domain.ErrInvalidInput, "invalid_scopes", "scopes must be ingest, read or admin")
+		}
+	}
+	return nil
+}
+
+// hashAPIKey hashes a key for storage. Keys are random, so a fast unsalted
+// hash is enough to make a leaked table useless.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyPrefix(key string) string {
+	if len(key) > apiKeyPrefixLen {
+		return key[:apiKeyPrefixLen]
+	}
+	return key
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateKey(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) UpdateKey(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestCreateKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	useCase := NewAPIKeyUseCase(mockRepo, 3600)
+
+	var stored *domain.APIKey
+	mockRepo.On("CreateKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*domain.APIKey)
+	}).Return(nil)
+
+	issued, err := useCase.CreateKey(context.Background(), domain.APIKeyRequest{Name: "ingest job", Scopes: []string{domain.ScopeIngest}, TTLSec: 60})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(issued.Key, "stori_"))
+	assert.Equal(t, issued.Key[:14], stored.Prefix)
+	assert.Equal(t, hashAPIKey(issued.Key), stored.Hash)
+	assert.NotContains(t, stored.Hash, issued.Key)
+	assert.Equal(t, []string{domain.ScopeIngest}, stored.Scopes)
+	require.NotNil(t, stored.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *stored.ExpiresAt, 5*time.Second)
+}
+
+func TestCreateKey_Invalid(t *testing.T) {
+	useCase := NewAPIKeyUseCase(new(MockAPIKeyRepository), 3600)
+
+	tests := []struct {
+		req  domain.APIKeyRequest
+		code string
+	}{
+		{domain.APIKeyRequest{Scopes: []string{domain.ScopeRead}}, "invalid_name"},
+		{domain.APIKeyRequest{Name: "reports"}, "invalid_scopes"},
+		{domain.APIKeyRequest{Name: "reports", Scopes: []string{"write"}}, "invalid_scopes"},
+		{domain.APIKeyRequest{Name: "reports", Scopes: []string{domain.ScopeRead}, TTLSec: -1}, "invalid_ttl_sec"},
+	}
+	for _, tt := range tests {
+		_, err := useCase.CreateKey(context.Background(), tt.req)
+		var domainErr *domain.Error
+		require.ErrorAs(t, err, &domainErr)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		assert.Equal(t, tt.code, domainErr.Code)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	keys := map[string]*domain.APIKey{
+		"stori_active":  {ID: "1", Scopes: []string{domain.ScopeRead}},
+		"stori_expired": {ID: "2", ExpiresAt: &past},
+		"stori_revoked": {ID: "3", RevokedAt: &past},
+	}
+	mockRepo := new(MockAPIKeyRepository)
+	for key, stored := range keys {
+		mockRepo.On("GetKeyByHash", mock.Anything, hashAPIKey(key)).Return(stored, nil)
+	}
+	mockRepo.On("GetKeyByHash", mock.Anything, mock.Anything).Return(nil, domain.ErrNotFound)
+	useCase := NewAPIKeyUseCase(mockRepo, 3600)
+
+	key, err := useCase.Authenticate(context.Background(), "stori_active")
+	require.NoError(t, err)
+	assert.Equal(t, "1", key.ID)
+
+	for _, raw := range []string{"stori_expired", "stori_revoked", "stori_unknown"} {
+		_, err := useCase.Authenticate(context.Background(), raw)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized, raw)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	useCase := NewAPIKeyUseCase(mockRepo, 3600)
+
+	createdAt := time.Now().Add(-time.Hour)
+	expiresAt := createdAt.Add(30 * 24 * time.Hour)
+	old := &domain.APIKey{ID: "old", Name: "reports", Scopes: []string{domain.ScopeRead}, CreatedAt: createdAt, ExpiresAt: &expiresAt}
+	mockRepo.On("GetKey", mock.Anything, "old").Return(old, nil)
+	mockRepo.On("CreateKey", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("UpdateKey", mock.Anything, old).Return(nil)
+
+	issued, err := useCase.RotateKey(context.Background(), "old")
+	require.NoError(t, err)
+	assert.Equal(t, "reports", issued.Name)
+	assert.Equal(t, "old", issued.RotatedFrom)
+	assert.Equal(t, []string{domain.ScopeRead}, issued.Scopes)
+	require.NotNil(t, issued.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *issued.ExpiresAt, 5*time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *old.ExpiresAt, 5*time.Second)
+	assert.Nil(t, old.RevokedAt)
+}
+
+func TestRotateKey_Revoked(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	useCase := NewAPIKeyUseCase(mockRepo, 3600)
+
+	revokedAt := time.Now()
+	mockRepo.On("GetKey", mock.Anything, "old").Return(&domain.APIKey{ID: "old", RevokedAt: &revokedAt}, nil)
+
+	_, err := useCase.RotateKey(context.Background(), "old")
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	mockRepo.AssertNotCalled(t, "CreateKey", mock.Anything, mock.Anything)
+}
+
+func TestRevokeKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	useCase := NewAPIKeyUseCase(mockRepo, 3600)
+
+	key := &domain.APIKey{ID: "1"}
+	mockRepo.On("GetKey", mock.Anything, "1").Return(key, nil)
+	mockRepo.On("GetKey", mock.Anything, "2").Return(nil, domain.ErrNotFound)
+	mockRepo.On("UpdateKey", mock.Anything, key).Return(nil)
+
+	require.NoError(t, useCase.RevokeKey(context.Background(), "1"))
+	assert.NotNil(t, key.RevokedAt)
+	assert.ErrorIs(t, useCase.RevokeKey(context.Background(), "2"), domain.ErrNotFound)
+}
+
+func TestEnsureKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	useCase := NewAPIKeyUseCase(mockRepo, 3600)
+
+	mockRepo.On("GetKeyByHash", mock.Anything, hashAPIKey("stori_stored")).Return(&domain.APIKey{ID: "1"}, nil)
+	mockRepo.On("GetKeyByHash", mock.Anything, hashAPIKey("stori_new_bootstrap_key")).Return(nil, domain.ErrNotFound)
+	mockRepo.On("CreateKey", mock.Anything, mock.MatchedBy(func(key *domain.APIKey) bool {
+		return key.Name == "bootstrap" && key.Hash == hashAPIKey("stori_new_bootstrap_key") && key.Prefix == "stori_new_boot"
+	})).Return(nil).Once()
+
+	require.NoError(t, useCase.EnsureKey(context.Background(), "bootstrap", "stori_stored", []string{domain.ScopeAdmin}))
+	require.NoError(t, useCase.EnsureKey(context.Background(), "bootstrap", "stori_new_bootstrap_key", []string{domain.ScopeAdmin}))
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// HealthCheck checks one dependency of the service.
+type HealthCheck struct {
+	Name string
+	// Required dependencies gate readiness; the others only degrade it.
+	Required bool
+	Check    func(ctx context.Context) error
+	// Inspect, when set, is run instead of Check and also returns details
+	// reported along with the status.
+	Inspect func(ctx context.Context) (map[string]interface{}, error)
+}
+
+type HealthUseCase interface {
+	// Readiness checks every dependency concurrently, each bounded by the
+	// check timeout, logging why the ones that are down failed.
+	Readiness(ctx context.Context) domain.HealthReport
+}
+
+type healthUseCaseImpl struct {
+	Checks  []HealthCheck
+	Timeout time.Duration
+}
+
+func NewHealthUseCase(checks []HealthCheck, timeout time.Duration) HealthUseCase {
+	return &healthUseCaseImpl{Checks: checks, Timeout: timeout}
+}
+
+func (uc *healthUseCaseImpl) Readiness(ctx context.Context) domain.HealthReport {
+	statuses := make([]domain.DependencyStatus, len(uc.Checks))
+	var wg sync.WaitGroup
+	for i, check := range uc.Checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			statuses[i] = uc.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := domain.HealthReport{
+		Status:       domain.HealthReady,
+		Dependencies: make(map[string]domain.DependencyStatus, len(uc.Checks)),
+	}
+	for i, check := range uc.Checks {
+		status := statuses[i]
+		report.Dependencies[check.Name] = status
+		if status.Status == domain.DependencyUp {
+			continue
+		}
+		if check.Required {
+			report.Status = domain.HealthUnavailable
+		} else if report.Status == domain.HealthReady {
+			report.Status = domain.HealthDegraded
+		}
+	}
+	return report
+}
+
+func (uc *healthUseCaseImpl) run(ctx context.Context, check HealthCheck) domain.DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, uc.Timeout)
+	defer cancel()
+
+	// A check ignoring its context still reports down after the timeout.
+	start := time.Now()
+	type result struct {
+		details map[string]interface{}
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if check.Inspect != nil {
+			details, err := check.Inspect(ctx)
+			done <- result{details, err}
+			return
+		}
+		done <- result{err: check.Check(ctx)}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+	err := res.err
+	status := domain.DependencyStatus{
+		Status:    domain.DependencyUp,
+		Required:  check.Required,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Details:   res.details,
+	}
+	if err != nil {
+		status.Status = domain.DependencyDown
+		status.Error = err.Error()
+		slog.WarnContext(ctx, "dependency check failed", "dependency", check.Name, "required", check.Required, "error", err)
+	}
+	return status
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthUseCase(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	hang := func(ctx context.Context) error { select {} }
+
+	tests := []struct {
+		name   string
+		checks []HealthCheck
+		status string
+	}{
+		{"all up", []HealthCheck{{Name: "postgres", Required: true, Check: up}, {Name: "mail", Required: false, Check: up}}, domain.HealthReady},
+		{"optional down", []HealthCheck{{Name: "postgres", Required: true, Check: up}, {Name: "mail", Required: false, Check: down}}, domain.HealthDegraded},
+		{"required down", []HealthCheck{{Name: "postgres", Required: true, Check: down}, {Name: "mail", Required: false, Check: down}}, domain.HealthUnavailable},
+		{"required hangs", []HealthCheck{{Name: "postgres", Required: true, Check: hang}, {Name: "mail", Required: false, Check: up}}, domain.HealthUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewHealthUseCase(tt.checks, 50*time.Millisecond).Readiness(context.Background())
+			assert.Equal(t, tt.status, report.Status)
+			assert.Equal(t, tt.status != domain.HealthUnavailable, report.Ready())
+			assert.Len(t, report.Dependencies, len(tt.checks))
+		})
+	}
+
+	report := NewHealthUseCase([]HealthCheck{{Name: "postgres", Required: true, Check: hang}, {Name: "redis", Required: true, Check: down}}, 50*time.Millisecond).Readiness(context.Background())
+	assert.Equal(t, domain.DependencyStatus{Status: domain.DependencyDown, Required: true, LatencyMs: report.Dependencies["postgres"].LatencyMs, Error: context.DeadlineExceeded.Error()}, report.Dependencies["postgres"])
+	assert.Equal(t, "connection refused", report.Dependencies["redis"].Error)
+
+	inspect := func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"version": int64(2)}, errors.New("pending migrations: 3")
+	}
+	report = NewHealthUseCase([]HealthCheck{{Name: "migrations", Required: true, Inspect: inspect}}, 50*time.Millisecond).Readiness(context.Background())
+	assert.Equal(t, domain.HealthUnavailable, report.Status)
+	assert.Equal(t, map[string]interface{}{"version": int64(2)}, report.Dependencies["migrations"].Details)
+	assert.Equal(t, "pending migrations: 3", report.Dependencies["migrations"].Error)
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type JobUseCase interface {
+	SubmitProcessing(ctx context.Context, req domain.ProcessRequest) (*domain.Job, error)
+	GetJob(ctx context.Context, id string) (*domain.Job, error)
+	// ListJobs lists the processing jobs matching filter, newest first.
+	ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error)
+	RunJob(ctx context.Context, id string) error
+	// AbandonJob records a job the queue gave up on failed.
+	AbandonJob(ctx context.Context, id string) error
+}
+
+type JobRepository interface {
+	CreateJob(ctx context.Context, job *domain.Job) error
+	UpdateJob(ctx context.Context, job *domain.Job) error
+	GetJob(ctx context.Context, id string) (*domain.Job, error)
+	ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error)
+}
+
+// JobQueue hands job IDs over to the workers that call RunJob.
+type JobQueue interface {
+	Enqueue(ctx context.Context, jobID string) error
+}
+
+type jobUseCaseImpl struct {
+	JobRepo      JobRepository
+	Queue        JobQueue
+	Transactions TransactionUseCase
+	Timeout      time.Duration
+	MaxAttempts  int
+	AccountID    string
+}
+
+func NewJobUseCase(jobRepo JobRepository, queue JobQueue, transactions TransactionUseCase, timeoutSec int, maxAttempts int, accountID string) JobUseCase {
+	return &jobUseCaseImpl{
+		JobRepo:      jobRepo,
+		Queue:        queue,
+		Transactions: transactions,
+		Timeout:      time.Duration(timeoutSec) * time.Second,
+		MaxAttempts:  maxAttempts,
+		AccountID:    accountID,
+	}
+}
+
+// SubmitProcessing records a queued job for req and enqueues it for the
+// workers. It fails with domain.ErrRunInProgress while a run for the account
+// is holding its lease.
+func (uc *jobUseCaseImpl) SubmitProcessing(ctx context.Context, req domain.ProcessRequest) (*domain.Job, error) {
+	accountID := req.AccountID
+	if accountID == "" {
+		accountID = uc.AccountID
+	}
+	inProgress, err := uc.Transactions.RunInProgress(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if inProgress {
+		return nil, domain.ErrRunInProgress
+	}
+
+	job := &domain.Job{
+		ID:        uuid.NewString(),
+		AccountID: accountID,
+		Period:    req.Period,
+		State:     domain.JobStateQueued,
+		Stages:    []domain.StageTiming{},
+		RequestID: domain.RequestIDFromContext(ctx),
+	}
+	if err := uc.JobRepo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	if err := uc.Queue.Enqueue(ctx, job.ID); err != nil {
+		finishedAt := time.Now()
+		job.State = domain.JobStateFailed
+		job.Error = err.Error()
+		job.FinishedAt = &finishedAt
+		if updateErr := uc.JobRepo.UpdateJob(ctx, job); updateErr != nil {
+			return nil, updateErr
+		}
+		return nil, domain.WrapError(domain.ErrUnavailable, "queue_unavailable", "the job could not be queued", err)
+	}
+	slog.InfoContext(ctx, "job queued", "job_id", job.ID, "account_id", job.AccountID, "period", job.Period)
+	return job, nil
+}
+
+func (uc *jobUseCaseImpl) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	job, err := uc.JobRepo.GetJob(ctx, id)
+	if err == nil && !canAccess(ctx, job.AccountID) {
+		err = domain.ErrNotFound
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.WrapError(domain.ErrNotFound, "job_not_found", "job not found", err)
+	}
+	return job, err
+}
+
+func (uc *jobUseCaseImpl) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	return uc.JobRepo.ListJobs(ctx, filter)
+}
+
+// AbandonJob records job id failed unless it has finished, so that clients
+// polling it see it end when the queue gives up on it: when it shuts down
+// before running it, or when the job keeps its workers from finishing.
+func (uc *jobUseCaseImpl) AbandonJob(ctx context.Context, id string) error {
+	job, err := uc.JobRepo.GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.State == domain.JobStateSucceeded || job.State == domain.JobStateFailed {
+		return nil
+	}
+	ctx = domain.WithRequestID(ctx, job.RequestID)
+
+	finishedAt := time.Now()
+	job.State = domain.JobStateFailed
+	job.Error = "abandoned by the job queue before it finished"
+	job.FinishedAt = &finishedAt
+	if err := uc.JobRepo.UpdateJob(ctx, job); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "job abandoned", "job_id", job.ID, "account_id", job.AccountID)
+	return nil
+}
+
+// RunJob executes the pipeline for job id and records its outcome. Jobs that
+// already succeeded are skipped so a redelivered job is not processed twice.
+// A failed run is recorded as retrying while the queue will deliver the job
+// again, and as failed once MaxAttempts runs have been made. A run that
+// found another one in progress fails without being retried. The run logs
+// with the request ID of the request that submitted the job.
+func (uc *jobUseCaseImpl) RunJob(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "RunJob", trace.WithAttributes(attribute.String("stori.job_id", id)))
+	defer func() { endSpan(span, err) }()
+
+	job, err := uc.JobRepo.GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	ctx = domain.WithRequestID(ctx, job.RequestID)
+	if job.State == domain.JobStateSucceeded {
+		slog.InfoContext(ctx, "job already succeeded, skipping", "job_id", job.ID)
+		return nil
+	}
+
+	startedAt := time.Now()
+	job.State = domain.JobStateRunning
+	job.Attempts++
+	job.StartedAt = &startedAt
+	job.FinishedAt = nil
+	job.Error = ""
+	if err := uc.JobRepo.UpdateJob(ctx, job); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "job started", "job_id", job.ID, "account_id", job.AccountID, "attempt", job.Attempts)
+
+	runCtx, cancel := context.WithTimeout(ctx, uc.Timeout)
+	defer cancel()
+	result, runErr := uc.Transactions.ProcessTransactions(runCtx, domain.ProcessRequest{AccountID: job.AccountID, Period: job.Period})
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	if result != nil {
+		job.Stages = result.Stages
+		job.RowsRead = result.RowsRead
+		job.RowsSaved = result.RowsSaved
+		job.CacheHit = result.CacheHit
+		job.StatementID = result.StatementID
+	}
+	if runErr != nil {
+		job.State = domain.JobStateFailed
+		if job.Attempts < uc.MaxAttempts && !errors.Is(runErr, domain.ErrRunInProgress) {
+			job.State = domain.JobStateRetrying
+		}
+		job.Error = runErr.Error()
+	} else {
+		job.State = domain.JobStateSucceeded
+	}
+
+	// The job context may be cancelled by now; still record the outcome.
+	if err := uc.JobRepo.UpdateJob(context.Background(), job); err != nil {
+		return err
+	}
+	attrs := []any{"job_id", job.ID, "account_id", job.AccountID, "state", job.State, "attempt", job.Attempts,
+		"rows_read", job.RowsRead, "rows_saved", job.RowsSaved, "duration_ms", finishedAt.Sub(startedAt).Milliseconds()}
+	if runErr != nil {
+		slog.WarnContext(ctx, "job failed", append(attrs, "error", runErr)...)
+	} else {
+		slog.InfoContext(ctx, "job finished", attrs...)
+	}
+	if errors.Is(runErr, domain.ErrRunInProgress) {
+		return nil
+	}
+	return runErr
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockJobRepository) CreateJob(ctx context.Context, job *domain.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) UpdateJob(ctx context.Context, job *domain.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobRepository) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockJobQueue struct {
+	mock.Mock
+}
+
+func (m *MockJobQueue) Enqueue(ctx context.Context, jobID string) error {
+	args := m.Called(ctx, jobID)
+	return args.Error(0)
+}
+
+type MockTransactionUseCase struct {
+	mock.Mock
+}
+
+func (m *MockTransactionUseCase) ProcessTransactions(ctx context.Context, req domain.ProcessRequest) (*domain.ProcessResult, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.ProcessResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionUseCase) RunInProgress(ctx context.Context, accountID string) (bool, error) {
+	args := m.Called(ctx, accountID)
+	return args.Bool(0), args.Error(1)
+}
+
+// idleTransactions returns a transaction use case with no run in progress.
+func idleTransactions() *MockTransactionUseCase {
+	mockTransactions := new(MockTransactionUseCase)
+	mockTransactions.On("RunInProgress", mock.Anything, mock.Anything).Return(false, nil)
+	return mockTransactions
+}
+
+func TestSubmitProcessing(t *testing.T) {
+	mockJobRepo := new(MockJobRepository)
+	mockQueue := new(MockJobQueue)
+	useCase := NewJobUseCase(mockJobRepo, mockQueue, idleTransactions(), 60, 1, "default")
+
+	mockJobRepo.On("CreateJob", mock.Anything, mock.MatchedBy(func(job *domain.Job) bool {
+		return job.ID != "" && job.AccountID == "default" && job.State == domain.JobStateQueued && job.RequestID == "req-1"
+	})).Return(nil)
+	mockQueue.On("Enqueue", mock.Anything, mock.AnythingOfType("string")).Return(nil)
+
+	job, err := useCase.SubmitProcessing(domain.WithRequestID(context.Background(), "req-1"), domain.ProcessRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.JobStateQueued, job.State)
+	mockQueue.AssertCalled(t, "Enqueue", mock.Anything, job.ID)
+
+	mockJobRepo.AssertExpectations(t)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestSubmitProcessing_RunInProgress(t *testing.T) {
+	mockJobRepo := new(MockJobRepository)
+	mockTransactions := new(MockTransactionUseCase)
+	useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), mockTransactions, 60, 1, "default")
+
+	mockTransactions.On("RunInProgress", mock.Anything, "acc-1").Return(true, nil)
+
+	_, err := useCase.SubmitProcessing(context.Background(), domain.ProcessRequest{AccountID: "acc-1"})
+	assert.ErrorIs(t, err, domain.ErrRunInProgress)
+	mockJobRepo.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func TestSubmitProcessing_EnqueueError(t *testing.T) {
+	mockJobRepo := new(MockJobRepository)
+	mockQueue := new(MockJobQueue)
+	useCase := NewJobUseCase(mockJobRepo, mockQueue, idleTransactions(), 60, 1, "default")
+
+	mockJobRepo.On("CreateJob", mock.Anything, mock.Anything).Return(nil)
+	mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("job queue is full"))
+	mockJobRepo.On("UpdateJob", mock.Anything, mock.MatchedBy(func(job *domain.Job) bool {
+		return job.State == domain.JobStateFailed && job.Error == "job queue is full" && job.FinishedAt != nil
+	})).Return(nil)
+
+	_, err := useCase.SubmitProcessing(context.Background(), domain.ProcessRequest{})
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.EqualError(t, err, "the job could not be queued: job queue is full")
+
+	mockJobRepo.AssertExpectations(t)
+}
+
+func TestGetJob_OtherAccount(t *testing.T) {
+	mockJobRepo := new(MockJobRepository)
+	useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), idleTransactions(), 60, 1, "default")
+
+	mockJobRepo.On("GetJob", mock.Anything, "job-1").Return(&domain.Job{ID: "job-1", AccountID: "acc-1"}, nil)
+
+	job, err := useCase.GetJob(domain.WithPrincipal(context.Background(), &domain.Principal{Accounts: []string{"acc-1"}}), "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", job.ID)
+
+	_, err = useCase.GetJob(domain.WithPrincipal(context.Background(), &domain.Principal{Accounts: []string{"acc-2"}}), "job-1")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.EqualError(t, err, "job not found: not found")
+}
+
+func TestAbandonJob(t *testing.T) {
+	mockJobRepo := new(MockJobRepository)
+	useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), idleTransactions(), 60, 1, "default")
+
+	queued := &domain.Job{ID: "job-1", AccountID: "acc-1", State: domain.JobStateQueued}
+	succeeded := &domain.Job{ID: "job-2", AccountID: "acc-1", State: domain.JobStateSucceeded}
+	mockJobRepo.On("GetJob", mock.Anything, "job-1").Return(queued, nil)
+	mockJobRepo.On("GetJob", mock.Anything, "job-2").Return(succeeded, nil)
+	mockJobRepo.On("UpdateJob", mock.Anything, mock.MatchedBy(func(job *domain.Job) bool {
+		return job.ID == "job-1" && job.State == domain.JobStateFailed && job.Error != "" && job.FinishedAt != nil
+	})).Return(nil).Once()
+
+	require.NoError(t, useCase.AbandonJob(context.Background(), "job-1"))
+	require.NoError(t, useCase.AbandonJob(context.Background(), "job-2"))
+	assert.Equal(t, domain.JobStateSucceeded, succeeded.State, "finished jobs are left as they are")
+	mockJobRepo.AssertExpectations(t)
+}
+
+func TestRunJob(t *testing.T) {
+	statementID := 12
+	result := &domain.ProcessResult{
+		AccountID:   "acc-1",
+		Stages:      []domain.StageTiming{{Name: "hash"}, {Name: "email"}},
+		RowsRead:    3,
+		RowsSaved:   3,
+		StatementID: &statementID,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockJobRepo := new(MockJobRepository)
+		mockTransactions := new(MockTransactionUseCase)
+		useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), mockTransactions, 60, 1, "default")
+
+		job := &domain.Job{ID: "job-1", AccountID: "acc-1", State: domain.JobStateQueued, RequestID: "req-1"}
+		mockJobRepo.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+		mockJobRepo.On("UpdateJob", mock.Anything, job).Return(nil).Twice()
+		// The run carries the request ID of the request that submitted it.
+		submittedBy := mock.MatchedBy(func(ctx context.Context) bool { return domain.RequestIDFromContext(ctx) == "req-1" })
+		mockTransactions.On("ProcessTransactions", submittedBy, domain.ProcessRequest{AccountID: "acc-1"}).Return(result, nil)
+
+		err := useCase.RunJob(context.Background(), "job-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.JobStateSucceeded, job.State)
+		assert.Equal(t, 1, job.Attempts)
+		assert.Equal(t, 3, job.RowsSaved)
+		assert.Equal(t, &statementID, job.StatementID)
+		assert.Len(t, job.Stages, 2)
+		assert.NotNil(t, job.StartedAt)
+		assert.NotNil(t, job.FinishedAt)
+
+		mockJobRepo.AssertExpectations(t)
+		mockTransactions.AssertExpectations(t)
+	})
+
+	t.Run("pipeline error", func(t *testing.T) {
+		mockJobRepo := new(MockJobRepository)
+		mockTransactions := new(MockTransactionUseCase)
+		useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), mockTransactions, 60, 1, "default")
+
+		job := &domain.Job{ID: "job-2", AccountID: "acc-1", State: domain.JobStateQueued}
+		mockJobRepo.On("GetJob", mock.Anything, "job-2").Return(job, nil)
+		mockJobRepo.On("UpdateJob", mock.Anything, job).Return(nil)
+		mockTransactions.On("ProcessTransactions", mock.Anything, mock.Anything).
+			Return(&domain.ProcessResult{Stages: []domain.StageTiming{{Name: "hash", Error: "open transactions.csv: no such file or directory"}}}, errors.New("open transactions.csv: no such file or directory"))
+
+		err := useCase.RunJob(context.Background(), "job-2")
+		assert.Error(t, err)
+		assert.Equal(t, domain.JobStateFailed, job.State)
+		assert.Equal(t, "open transactions.csv: no such file or directory", job.Error)
+		assert.Len(t, job.Stages, 1)
+	})
+
+	t.Run("pipeline error with attempts left", func(t *testing.T) {
+		mockJobRepo := new(MockJobRepository)
+		mockTransactions := new(MockTransactionUseCase)
+		useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), mockTransactions, 60, 3, "default")
+
+		job := &domain.Job{ID: "job-4", AccountID: "acc-1", State: domain.JobStateRetrying, Attempts: 1}
+		mockJobRepo.On("GetJob", mock.Anything, "job-4").Return(job, nil)
+		mockJobRepo.On("UpdateJob", mock.Anything, job).Return(nil)
+		mockTransactions.On("ProcessTransactions", mock.Anything, mock.Anything).Return(nil, errors.New("smtp unavailable"))
+
+		err := useCase.RunJob(context.Background(), "job-4")
+		assert.EqualError(t, err, "smtp unavailable")
+		assert.Equal(t, domain.JobStateRetrying, job.State)
+		assert.Equal(t, 2, job.Attempts)
+	})
+
+	t.Run("run already in progress", func(t *testing.T) {
+		mockJobRepo := new(MockJobRepository)
+		mockTransactions := new(MockTransactionUseCase)
+		useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), mockTransactions, 60, 3, "default")
+
+		job := &domain.Job{ID: "job-5", AccountID: "acc-1", State: domain.JobStateQueued}
+		mockJobRepo.On("GetJob", mock.Anything, "job-5").Return(job, nil)
+		mockJobRepo.On("UpdateJob", mock.Anything, job).Return(nil)
+		mockTransactions.On("ProcessTransactions", mock.Anything, mock.Anything).Return(nil, domain.ErrRunInProgress)
+
+		// Not returned to the queue, so the duplicate run is not retried.
+		assert.NoError(t, useCase.RunJob(context.Background(), "job-5"))
+		assert.Equal(t, domain.JobStateFailed, job.State)
+		assert.Equal(t, domain.ErrRunInProgress.Error(), job.Error)
+	})
+
+	t.Run("already succeeded", func(t *testing.T) {
+		mockJobRepo := new(MockJobRepository)
+		mockTransactions := new(MockTransactionUseCase)
+		useCase := NewJobUseCase(mockJobRepo, new(MockJobQueue), mockTransactions, 60, 1, "default")
+
+		mockJobRepo.On("GetJob", mock.Anything, "job-3").Return(&domain.Job{ID: "job-3", State: domain.JobStateSucceeded}, nil)
+
+		assert.NoError(t, useCase.RunJob(context.Background(), "job-3"))
+		mockTransactions.AssertNotCalled(t, "ProcessTransactions", mock.Anything, mock.Anything)
+		mockJobRepo.AssertNotCalled(t, "UpdateJob", mock.Anything, mock.Anything)
+	})
+}
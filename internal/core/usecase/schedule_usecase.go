@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// catchUpLookback is how far before the last recorded run nominal schedule
+// times are enumerated, so that a run postponed past holidays is not missed.
+const catchUpLookback = 31 * 24 * time.Hour
+
+// catchUpAfter is how late a run is submitted before it counts as caught up.
+const catchUpAfter = 10 * time.Minute
+
+type ScheduleUseCase interface {
+	// RunDue submits a processing job per account for every schedule time
+	// that fell due since the last recorded run, up to now.
+	RunDue(ctx context.Context, now time.Time) ([]domain.ScheduleRun, error)
+	ListRuns(ctx context.Context, limit int) ([]domain.ScheduleRun, error)
+}
+
+type ScheduleRunRepository interface {
+	CreateRun(ctx context.Context, run *domain.ScheduleRun) error
+	UpdateRun(ctx context.Context, run *domain.ScheduleRun) error
+	LatestRun(ctx context.Context) (*domain.ScheduleRun, error)
+	ListRuns(ctx context.Context, filter domain.ScheduleRunFilter) ([]domain.ScheduleRun, error)
+}
+
+// Schedule yields the nominal times a statement run is due, e.g. a parsed
+// cron expression.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type scheduleUseCaseImpl struct {
+	RunRepo    ScheduleRunRepository
+	Jobs       JobUseCase
+	Schedule   Schedule
+	Calendar   domain.BusinessCalendar
+	Accounts   []string
+	MaxCatchUp int
+	StartedAt  time.Time
+}
+
+func NewScheduleUseCase(runRepo ScheduleRunRepository, jobs JobUseCase, schedule Schedule, calendar domain.BusinessCalendar, accounts []string, maxCatchUp int, startedAt time.Time) ScheduleUseCase {
+	return &scheduleUseCaseImpl{
+		RunRepo:    runRepo,
+		Jobs:       jobs,
+		Schedule:   schedule,
+		Calendar:   calendar,
+		Accounts:   accounts,
+		MaxCatchUp: maxCatchUp,
+		StartedAt:  startedAt,
+	}
+}
+
+// RunDue moves schedule times falling on weekends or holidays to the next
+// business day and statements cover the month before the nominal time. Runs
+// missed while the service was down are caught up, at most MaxCatchUp of
+// them; without any run history nothing before StartedAt is run.
+func (uc *scheduleUseCaseImpl) RunDue(ctx context.Context, now time.Time) ([]domain.ScheduleRun, error) {
+	since := uc.StartedAt
+	latest, err := uc.RunRepo.LatestRun(ctx)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if latest != nil {
+		since = latest.ScheduledFor
+	}
+
+	type due struct {
+		at     time.Time
+		period string
+	}
+	var dues []due
+	for nominal := uc.Schedule.Next(since.Add(-catchUpLookback)); !nominal.After(now); nominal = uc.Schedule.Next(nominal) {
+		at := uc.Calendar.NextBusinessDay(nominal)
+		if !at.After(since) || at.After(now) {
+			continue
+		}
+		period := time.Date(nominal.Year(), nominal.Month(), 1, 0, 0, 0, 0, nominal.Location()).AddDate(0, -1, 0)
+		dues = append(dues, due{at: at, period: period.Format("2006-01")})
+	}
+	if uc.MaxCatchUp > 0 && len(dues) > uc.MaxCatchUp {
+		dues = dues[len(dues)-uc.MaxCatchUp:]
+	}
+
+	var runs []domain.ScheduleRun
+	for _, d := range dues {
+		for _, accountID := range uc.Accounts {
+			run := &domain.ScheduleRun{
+				AccountID:    accountID,
+				Period:       d.period,
+				ScheduledFor: d.at,
+				CatchUp:      now.Sub(d.at) > catchUpAfter,
+			}
+			if err := uc.RunRepo.CreateRun(ctx, run); err != nil {
+				if errors.Is(err, domain.ErrRunExists) {
+					continue
+				}
+				return runs, err
+			}
+
+			job, err := uc.Jobs.SubmitProcessing(ctx, domain.ProcessRequest{AccountID: accountID, Period: d.period})
+			if err != nil {
+				run.Error = err.Error()
+			} else {
+				run.JobID = job.ID
+			}
+			if err := uc.RunRepo.UpdateRun(ctx, run); err != nil {
+				return runs, err
+			}
+			runs = append(runs, *run)
+		}
+	}
+	return runs, nil
+}
+
+// ListRuns returns the runs of the accounts the caller may see.
+func (uc *scheduleUseCaseImpl) ListRuns(ctx context.Context, limit int) ([]domain.ScheduleRun, error) {
+	filter := domain.ScheduleRunFilter{Limit: limit}
+	if principal, ok := domain.PrincipalFromContext(ctx); ok && !principal.AllAccounts {
+		filter.Accounts = append([]string{}, principal.Accounts...)
+	}
+	return uc.RunRepo.ListRuns(ctx, filter)
+}
@@ -0,0 +1,186 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockScheduleRunRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRunRepository) CreateRun(ctx context.Context, run *domain.ScheduleRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRunRepository) UpdateRun(ctx context.Context, run *domain.ScheduleRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRunRepository) LatestRun(ctx context.Context) (*domain.ScheduleRun, error) {
+	args := m.Called(ctx)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.ScheduleRun), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockScheduleRunRepository) ListRuns(ctx context.Context, filter domain.ScheduleRunFilter) ([]domain.ScheduleRun, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.ScheduleRun), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockJobUseCase struct {
+	mock.Mock
+}
+
+func (m *MockJobUseCase) SubmitProcessing(ctx context.Context, req domain.ProcessRequest) (*domain.Job, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobUseCase) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobUseCase) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobUseCase) RunJob(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockJobUseCase) AbandonJob(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func monthlySchedule(t *testing.T) cron.Schedule {
+	schedule, err := cron.ParseStandard("0 6 1 * *")
+	require.NoError(t, err)
+	return schedule
+}
+
+func at(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRunDue_PostponesToFirstBusinessDay(t *testing.T) {
+	mockRunRepo := new(MockScheduleRunRepository)
+	mockJobs := new(MockJobUseCase)
+	// 2024-06-01 is a Saturday and 2024-06-03 a holiday here.
+	calendar := domain.NewBusinessCalendar([]string{"2024-06-03"})
+	useCase := NewScheduleUseCase(mockRunRepo, mockJobs, monthlySchedule(t), calendar, []string{"acc-1", "acc-2"}, 3, at("2024-05-20 00:00"))
+
+	mockRunRepo.On("LatestRun", mock.Anything).Return(nil, domain.ErrNotFound)
+
+	// Not yet due on the Monday holiday.
+	runs, err := useCase.RunDue(context.Background(), at("2024-06-03 12:00"))
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	mockRunRepo.On("CreateRun", mock.Anything, mock.AnythingOfType("*domain.ScheduleRun")).Return(nil)
+	mockRunRepo.On("UpdateRun", mock.Anything, mock.AnythingOfType("*domain.ScheduleRun")).Return(nil)
+	mockJobs.On("SubmitProcessing", mock.Anything, domain.ProcessRequest{AccountID: "acc-1", Period: "2024-05"}).Return(&domain.Job{ID: "job-1"}, nil)
+	mockJobs.On("SubmitProcessing", mock.Anything, domain.ProcessRequest{AccountID: "acc-2", Period: "2024-05"}).Return(nil, errors.New("too many requests"))
+
+	runs, err = useCase.RunDue(context.Background(), at("2024-06-04 06:01"))
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, at("2024-06-04 06:00"), runs[0].ScheduledFor)
+	assert.Equal(t, "2024-05", runs[0].Period)
+	assert.Equal(t, "job-1", runs[0].JobID)
+	assert.False(t, runs[0].CatchUp)
+	assert.Equal(t, "acc-2", runs[1].AccountID)
+	assert.Equal(t, "too many requests", runs[1].Error)
+
+	mockRunRepo.AssertExpectations(t)
+	mockJobs.AssertExpectations(t)
+}
+
+func TestRunDue_CatchesUpMissedRuns(t *testing.T) {
+	mockRunRepo := new(MockScheduleRunRepository)
+	mockJobs := new(MockJobUseCase)
+	useCase := NewScheduleUseCase(mockRunRepo, mockJobs, monthlySchedule(t), domain.NewBusinessCalendar(nil), []string{"acc-1"}, 2, at("2024-06-10 00:00"))
+
+	// Last run on 2024-01-01; the service was down until June.
+	mockRunRepo.On("LatestRun", mock.Anything).Return(&domain.ScheduleRun{ScheduledFor: at("2024-01-01 06:00")}, nil)
+	var created []string
+	mockRunRepo.On("CreateRun", mock.Anything, mock.AnythingOfType("*domain.ScheduleRun")).Run(func(args mock.Arguments) {
+		created = append(created, args.Get(1).(*domain.ScheduleRun).Period)
+	}).Return(nil)
+	mockRunRepo.On("UpdateRun", mock.Anything, mock.Anything).Return(nil)
+	mockJobs.On("SubmitProcessing", mock.Anything, mock.Anything).Return(&domain.Job{ID: "job"}, nil)
+
+	runs, err := useCase.RunDue(context.Background(), at("2024-06-10 00:00"))
+	require.NoError(t, err)
+
+	// Only the last two missed months are caught up.
+	assert.Equal(t, []string{"2024-04", "2024-05"}, created)
+	require.Len(t, runs, 2)
+	assert.Equal(t, at("2024-05-01 06:00"), runs[0].ScheduledFor)
+	assert.Equal(t, at("2024-06-03 06:00"), runs[1].ScheduledFor)
+	assert.True(t, runs[0].CatchUp)
+	assert.True(t, runs[1].CatchUp)
+}
+
+func TestRunDue_SkipsRunsRecordedElsewhere(t *testing.T) {
+	mockRunRepo := new(MockScheduleRunRepository)
+	mockJobs := new(MockJobUseCase)
+	useCase := NewScheduleUseCase(mockRunRepo, mockJobs, monthlySchedule(t), domain.NewBusinessCalendar(nil), []string{"acc-1"}, 3, at("2024-04-20 00:00"))
+
+	mockRunRepo.On("LatestRun", mock.Anything).Return(nil, domain.ErrNotFound)
+	mockRunRepo.On("CreateRun", mock.Anything, mock.Anything).Return(domain.ErrRunExists)
+
+	runs, err := useCase.RunDue(context.Background(), at("2024-05-01 06:00"))
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	mockJobs.AssertNotCalled(t, "SubmitProcessing", mock.Anything, mock.Anything)
+}
+
+func TestListRuns_FiltersByCallerAccounts(t *testing.T) {
+	mockRunRepo := new(MockScheduleRunRepository)
+	useCase := NewScheduleUseCase(mockRunRepo, new(MockJobUseCase), monthlySchedule(t), domain.NewBusinessCalendar(nil), []string{"acc-1", "acc-2"}, 3, at("2024-04-20 00:00"))
+
+	mockRunRepo.On("ListRuns", mock.Anything, domain.ScheduleRunFilter{Limit: 10}).Return([]domain.ScheduleRun{}, nil).Twice()
+	mockRunRepo.On("ListRuns", mock.Anything, domain.ScheduleRunFilter{Accounts: []string{"acc-2"}, Limit: 10}).Return([]domain.ScheduleRun{}, nil).Once()
+
+	_, err := useCase.ListRuns(context.Background(), 10)
+	require.NoError(t, err)
+	_, err = useCase.ListRuns(domain.WithPrincipal(context.Background(), &domain.Principal{AllAccounts: true}), 10)
+	require.NoError(t, err)
+	_, err = useCase.ListRuns(domain.WithPrincipal(context.Background(), &domain.Principal{Accounts: []string{"acc-2"}}), 10)
+	require.NoError(t, err)
+
+	mockRunRepo.AssertExpectations(t)
+}
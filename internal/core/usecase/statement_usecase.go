@@ -0,0 +1,219 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+type StatementUseCase interface {
+	PreviewStatement(ctx context.Context, req domain.PreviewRequest) (*domain.RenderedEmail, error)
+	GetStatement(ctx context.Context, id int) (*domain.Statement, error)
+	ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error)
+	ResendStatement(ctx context.Context, id int) (*domain.Statement, error)
+	// SendStatement sends the statement of the transactions stored for an
+	// account, only those of period (2006-01) if set.
+	SendStatement(ctx context.Context, accountID, period string) (*domain.Statement, error)
+	// RedriveFailed resends the failed statements matching filter that have
+	// not been resent yet.
+	RedriveFailed(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error)
+}
+
+type StatementRepository interface {
+	CreateStatement(ctx context.Context, statement *domain.Statement) error
+	UpdateStatement(ctx context.Context, statement *domain.Statement) error
+	GetStatement(ctx context.Context, id int) (*domain.Statement, error)
+	ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error)
+}
+
+type statementUseCaseImpl struct {
+	DBRepo        TransactionRepository
+	StatementRepo StatementRepository
+	Email         EmailService
+	AccountID     string
+}
+
+func NewStatementUseCase(dbRepo TransactionRepository, statementRepo StatementRepository, email EmailService, accountID string) StatementUseCase {
+	return &statementUseCaseImpl{
+		DBRepo:        dbRepo,
+		StatementRepo: statementRepo,
+		Email:         email,
+		AccountID:     accountID,
+	}
+}
+
+func (uc *statementUseCaseImpl) PreviewStatement(ctx context.Context, req domain.PreviewRequest) (*domain.RenderedEmail, error) {
+	transactions := req.Transactions
+	if transactions == nil {
+		accountID := req.AccountID
+		if accountID == "" {
+			accountID = uc.AccountID
+		}
+
+		var err error
+		transactions, err = uc.DBRepo.GetTransactionsByAccount(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if len(transactions) == 0 {
+			return nil, domain.NewError(domain.ErrSourceNotFound, "no_transactions", fmt.Sprintf("no transactions found for account %s", accountID))
+		}
+	}
+
+	summary := generateHTMLSummary(transactions)
+	return uc.Email.RenderEmail(summaryTemplatePath, summary)
+}
+
+func (uc *statementUseCaseImpl) GetStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	statement, err := uc.StatementRepo.GetStatement(ctx, id)
+	if err != nil {
+		return nil, statementNotFound(err)
+	}
+	if !canAccess(ctx, statement.AccountID) {
+		return nil, statementNotFound(domain.ErrNotFound)
+	}
+	return statement, nil
+}
+
+func (uc *statementUseCaseImpl) ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	return uc.StatementRepo.ListStatements(ctx, filter)
+}
+
+// ResendStatement sends the archived email of statement id again, unchanged,
+// and archives the new delivery as its own statement pointing back to it.
+func (uc *statementUseCaseImpl) ResendStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	original, err := uc.GetStatement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resent := &domain.Statement{
+		AccountID:    original.AccountID,
+		Recipient:    original.Recipient,
+		Subject:      original.Subject,
+		Period:       original.Period,
+		Summary:      original.Summary,
+		HTMLBody:     original.HTMLBody,
+		TextBody:     original.TextBody,
+		BodyHash:     original.BodyHash,
+		ResentFromID: &original.ID,
+	}
+	if err := deliverStatement(ctx, uc.StatementRepo, uc.Email, resent); err != nil {
+		return resent, err
+	}
+	return resent, nil
+}
+
+// SendStatement renders and sends the statement of the account's stored
+// transactions, archived like those sent by the pipeline. Without period,
+// every transaction is summarised and the statement is filed under the
+// current month.
+func (uc *statementUseCaseImpl) SendStatement(ctx context.Context, accountID, period string) (*domain.Statement, error) {
+	if accountID == "" {
+		accountID = uc.AccountID
+	}
+	transactions, err := uc.DBRepo.GetTransactionsByAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if period == "" {
+		period = time.Now().Format("2006-01")
+	} else {
+		transactions = transactionsInPeriod(transactions, period)
+	}
+	if len(transactions) == 0 {
+		return nil, domain.NewError(domain.ErrSourceNotFound, "no_transactions", fmt.Sprintf("no transactions found for account %s", accountID))
+	}
+
+	summary := generateHTMLSummary(transactions)
+	rendered, err := uc.Email.RenderEmail(summaryTemplatePath, summary)
+	if err != nil {
+		return nil, err
+	}
+	statement := newStatement(accountID, period, summary, rendered)
+	return statement, deliverStatement(ctx, uc.StatementRepo, uc.Email, statement)
+}
+
+// RedriveFailed resends, oldest first, the failed statements matching
+// filter that have no resend yet, so that running it again only retries the
+// resends that failed in turn. Failed deliveries are returned with the
+// others, with their error; it stops at the first error recording them.
+func (uc *statementUseCaseImpl) RedriveFailed(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	filter.Status = domain.StatementStatusFailed
+	filter.NotResent = true
+	failed, err := uc.StatementRepo.ListStatements(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resent := make([]domain.Statement, 0, len(failed))
+	for i := len(failed) - 1; i >= 0; i-- {
+		statement, err := uc.ResendStatement(ctx, failed[i].ID)
+		if statement != nil && statement.ID != 0 {
+			resent = append(resent, *statement)
+		}
+		if err != nil && (statement == nil || statement.Status != domain.StatementStatusFailed) {
+			return resent, err
+		}
+	}
+	return resent, nil
+}
+
+// newStatement builds the archive record for a freshly rendered statement.
+func statementNotFound(err error) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.WrapError(domain.ErrNotFound, "statement_not_found", "statement not found", err)
+	}
+	return err
+}
+
+func newStatement(accountID, period string, summary map[string]interface{}, rendered *domain.RenderedEmail) *domain.Statement {
+	return &domain.Statement{
+		AccountID: accountID,
+		Recipient: rendered.To,
+		Subject:   rendered.Subject,
+		Period:    period,
+		Summary:   summary,
+		HTMLBody:  rendered.HTML,
+		TextBody:  rendered.Text,
+		BodyHash:  hashBody(rendered.HTML),
+	}
+}
+
+// deliverStatement archives statement as pending, sends it and records the
+// outcome. A failed send is still archived, with its error.
+func deliverStatement(ctx context.Context, repo StatementRepository, email EmailService, statement *domain.Statement) error {
+	statement.Status = domain.StatementStatusPending
+	if err := repo.CreateStatement(ctx, statement); err != nil {
+		return err
+	}
+
+	messageID, sendErr := email.SendEmail(ctx, statement.Email())
+	if sendErr != nil {
+		statement.Status = domain.StatementStatusFailed
+		statement.Error = sendErr.Error()
+	} else {
+		sentAt := time.Now()
+		statement.Status = domain.StatementStatusSent
+		statement.MessageID = messageID
+		statement.SentAt = &sentAt
+	}
+
+	// The message may have left even if ctx was cancelled meanwhile, e.g. by
+	// a shutdown; still record the outcome so the statement is not left
+	// pending.
+	if err := repo.UpdateStatement(context.Background(), statement); err != nil && sendErr == nil {
+		return err
+	}
+	return sendErr
+}
+
+func hashBody(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPreviewStatement(t *testing.T) {
+	transactions := []domain.Transaction{
+		{ID: 1, AccountID: "acc-1", Date: "1/1", Amount: 100},
+		{ID: 2, AccountID: "acc-1", Date: "2/2", Amount: -50},
+	}
+
+	t.Run("from given transactions", func(t *testing.T) {
+		mockDBRepo := new(MockTransactionRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(mockDBRepo, new(MockStatementRepository), mockEmail, "default")
+
+		mockEmail.On("RenderEmail", "./internal/infrastructure/email/templates/summary_template.html", mock.MatchedBy(func(summary map[string]interface{}) bool {
+			return summary["TotalBalance"] == 50.0 && len(summary["MonthlyData"].([]map[string]interface{})) == 2
+		})).Return(testRenderedEmail, nil)
+
+		result, err := useCase.PreviewStatement(context.Background(), domain.PreviewRequest{Transactions: transactions})
+		assert.NoError(t, err)
+		assert.Equal(t, testRenderedEmail, result)
+
+		mockDBRepo.AssertNotCalled(t, "GetTransactionsByAccount", mock.Anything, mock.Anything)
+		mockEmail.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+		mockEmail.AssertExpectations(t)
+	})
+
+	t.Run("from stored account", func(t *testing.T) {
+		mockDBRepo := new(MockTransactionRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(mockDBRepo, new(MockStatementRepository), mockEmail, "default")
+
+		mockDBRepo.On("GetTransactionsByAccount", mock.Anything, "acc-1").Return(transactions, nil)
+		mockEmail.On("RenderEmail", mock.Anything, mock.Anything).Return(testRenderedEmail, nil)
+
+		result, err := useCase.PreviewStatement(context.Background(), domain.PreviewRequest{AccountID: "acc-1"})
+		assert.NoError(t, err)
+		assert.Equal(t, testRenderedEmail, result)
+
+		mockDBRepo.AssertExpectations(t)
+		mockEmail.AssertExpectations(t)
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		mockDBRepo := new(MockTransactionRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(mockDBRepo, new(MockStatementRepository), mockEmail, "default")
+
+		mockDBRepo.On("GetTransactionsByAccount", mock.Anything, "default").Return([]domain.Transaction{}, nil)
+
+		_, err := useCase.PreviewStatement(context.Background(), domain.PreviewRequest{})
+		assert.EqualError(t, err, "no transactions found for account default")
+
+		mockEmail.AssertNotCalled(t, "RenderEmail", mock.Anything, mock.Anything)
+	})
+}
+
+func TestResendStatement(t *testing.T) {
+	original := &domain.Statement{
+		ID:        7,
+		AccountID: "acc-1",
+		Recipient: "customer@example.com",
+		Subject:   "Monthly Transaction Summary",
+		Period:    "2024-03",
+		HTMLBody:  "<p>summary</p>",
+		TextBody:  "summary",
+		BodyHash:  hashBody("<p>summary</p>"),
+		Status:    domain.StatementStatusSent,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockStatementRepo := new(MockStatementRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(new(MockTransactionRepository), mockStatementRepo, mockEmail, "default")
+
+		mockStatementRepo.On("GetStatement", mock.Anything, 7).Return(original, nil)
+		mockStatementRepo.On("CreateStatement", mock.Anything, mock.MatchedBy(func(s *domain.Statement) bool {
+			return s.ID == 0 && *s.ResentFromID == 7 && s.Period == "2024-03" && s.BodyHash == original.BodyHash
+		})).Return(nil)
+		mockEmail.On("SendEmail", mock.Anything, original.Email()).Return("<resent@example.com>", nil)
+		mockStatementRepo.On("UpdateStatement", mock.Anything, mock.AnythingOfType("*domain.Statement")).Return(nil)
+
+		resent, err := useCase.ResendStatement(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatementStatusSent, resent.Status)
+		assert.Equal(t, "<resent@example.com>", resent.MessageID)
+		assert.NotNil(t, resent.SentAt)
+
+		mockStatementRepo.AssertExpectations(t)
+		mockEmail.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockStatementRepo := new(MockStatementRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(new(MockTransactionRepository), mockStatementRepo, mockEmail, "default")
+
+		mockStatementRepo.On("GetStatement", mock.Anything, 8).Return(nil, domain.ErrNotFound)
+
+		_, err := useCase.ResendStatement(context.Background(), 8)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+
+		mockEmail.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("other account", func(t *testing.T) {
+		mockStatementRepo := new(MockStatementRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(new(MockTransactionRepository), mockStatementRepo, mockEmail, "default")
+
+		mockStatementRepo.On("GetStatement", mock.Anything, 7).Return(original, nil)
+		ctx := domain.WithPrincipal(context.Background(), &domain.Principal{Accounts: []string{"acc-2"}})
+
+		_, err := useCase.GetStatement(ctx, 7)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = useCase.ResendStatement(ctx, 7)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		mockStatementRepo.AssertNotCalled(t, "CreateStatement", mock.Anything, mock.Anything)
+		mockEmail.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSendStatement(t *testing.T) {
+	transactions := []domain.Transaction{
+		{ID: 1, AccountID: "acc-1", Date: "3/1", Amount: 100},
+		{ID: 2, AccountID: "acc-1", Date: "4/2", Amount: -50},
+	}
+
+	t.Run("period", func(t *testing.T) {
+		mockDBRepo := new(MockTransactionRepository)
+		mockStatementRepo := new(MockStatementRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(mockDBRepo, mockStatementRepo, mockEmail, "default")
+
+		mockDBRepo.On("GetTransactionsByAccount", mock.Anything, "acc-1").Return(transactions, nil)
+		mockEmail.On("RenderEmail", mock.Anything, mock.MatchedBy(func(summary map[string]interface{}) bool {
+			return summary["TotalBalance"] == 100.0
+		})).Return(testRenderedEmail, nil)
+		mockStatementRepo.On("CreateStatement", mock.Anything, mock.MatchedBy(func(s *domain.Statement) bool {
+			return s.AccountID == "acc-1" && s.Period == "2024-03"
+		})).Return(nil)
+		mockEmail.On("SendEmail", mock.Anything, testRenderedEmail).Return("<sent@example.com>", nil)
+		mockStatementRepo.On("UpdateStatement", mock.Anything, mock.AnythingOfType("*domain.Statement")).Return(nil)
+
+		statement, err := useCase.SendStatement(context.Background(), "acc-1", "2024-03")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatementStatusSent, statement.Status)
+
+		mockStatementRepo.AssertExpectations(t)
+		mockEmail.AssertExpectations(t)
+	})
+
+	t.Run("no transactions in period", func(t *testing.T) {
+		mockDBRepo := new(MockTransactionRepository)
+		mockEmail := new(MockEmailService)
+		useCase := NewStatementUseCase(mockDBRepo, new(MockStatementRepository), mockEmail, "default")
+
+		mockDBRepo.On("GetTransactionsByAccount", mock.Anything, "default").Return(transactions, nil)
+
+		_, err := useCase.SendStatement(context.Background(), "", "2024-06")
+		assert.ErrorIs(t, err, domain.ErrSourceNotFound)
+
+		mockEmail.AssertNotCalled(t, "RenderEmail", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRedriveFailed(t *testing.T) {
+	failed := func(id int) *domain.Statement {
+		return &domain.Statement{ID: id, AccountID: "acc-1", Recipient: "customer@example.com", HTMLBody: "<p>summary</p>", Status: domain.StatementStatusFailed}
+	}
+	mockStatementRepo := new(MockStatementRepository)
+	mockEmail := new(MockEmailService)
+	useCase := NewStatementUseCase(new(MockTransactionRepository), mockStatementRepo, mockEmail, "default")
+
+	mockStatementRepo.On("ListStatements", mock.Anything, domain.StatementFilter{AccountID: "acc-1", Status: domain.StatementStatusFailed, NotResent: true}).
+		Return([]domain.Statement{*failed(9), *failed(4)}, nil)
+	mockStatementRepo.On("GetStatement", mock.Anything, 4).Return(failed(4), nil)
+	mockStatementRepo.On("GetStatement", mock.Anything, 9).Return(failed(9), nil)
+	nextID := 10
+	mockStatementRepo.On("CreateStatement", mock.Anything, mock.AnythingOfType("*domain.Statement")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.Statement).ID = nextID
+		nextID++
+	})
+	mockEmail.On("SendEmail", mock.Anything, mock.Anything).Return("<resent@example.com>", nil).Once()
+	mockEmail.On("SendEmail", mock.Anything, mock.Anything).Return("", errors.New("mailbox unavailable")).Once()
+	mockStatementRepo.On("UpdateStatement", mock.Anything, mock.AnythingOfType("*domain.Statement")).Return(nil)
+
+	resent, err := useCase.RedriveFailed(context.Background(), domain.StatementFilter{AccountID: "acc-1"})
+	assert.NoError(t, err, "failed deliveries are reported in the statements")
+	if assert.Len(t, resent, 2) {
+		assert.Equal(t, 4, *resent[0].ResentFromID, "oldest first")
+		assert.Equal(t, domain.StatementStatusSent, resent[0].Status)
+		assert.Equal(t, 9, *resent[1].ResentFromID)
+		assert.Equal(t, domain.StatementStatusFailed, resent[1].Status)
+		assert.Equal(t, "mailbox unavailable", resent[1].Error)
+	}
+	mockStatementRepo.AssertExpectations(t)
+}
@@ -2,25 +2,39 @@ package usecase
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"io/fs"
+	"log/slog"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/jordanlanch/stori-test/internal/core/domain"
-	"golang.org/x/time/rate"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer traces the use cases; spans are exported by the provider installed
+// at startup, if any.
+var tracer = otel.Tracer("github.com/jordanlanch/stori-test/internal/core/usecase")
+
+const summaryTemplatePath = "./internal/infrastructure/email/templates/summary_template.html"
+
 type TransactionUseCase interface {
-	ProcessTransactions(ctx context.Context) error
+	ProcessTransactions(ctx context.Context, req domain.ProcessRequest) (*domain.ProcessResult, error)
+	// RunInProgress reports whether a run currently holds the lease for the
+	// account and its current file.
+	RunInProgress(ctx context.Context, accountID string) (bool, error)
 }
 
 type TransactionRepository interface {
 	GetAllTransactions(ctx context.Context) ([]domain.Transaction, error)
-	SaveTransactions(ctx context.Context, transactions []domain.Transaction) error
+	SaveTransactions(ctx context.Context, transactions []domain.Transaction, fence *domain.Fence) error
+	GetTransactionsByAccount(ctx context.Context, accountID string) ([]domain.Transaction, error)
 	GetCSVHash() (string, error)
 }
 
@@ -29,72 +43,277 @@ type CacheRepository interface {
 	Set(ctx context.Context, key string, value []domain.Transaction) error
 }
 
+// Locker grants leases that keep a single run of the pipeline per key across
+// replicas. Acquire returns domain.ErrRunInProgress if the key is leased.
+type Locker interface {
+	Acquire(ctx context.Context, key string) (Lease, error)
+	Held(ctx context.Context, key string) (bool, error)
+}
+
+// Lease is held until released or until it can no longer be renewed, at
+// which point Lost is closed.
+type Lease interface {
+	Token() int64
+	Lost() <-chan struct{}
+	Release(ctx context.Context) error
+}
+
 type EmailService interface {
-	SendEmail(ctx context.Context, templatePath string, data interface{}) error
+	RenderEmail(templatePath string, data interface{}) (*domain.RenderedEmail, error)
+	SendEmail(ctx context.Context, email *domain.RenderedEmail) (string, error)
 }
 
 type transactionUseCaseImpl struct {
 	DBRepo        TransactionRepository
 	CacheRepo     CacheRepository
+	StatementRepo StatementRepository
 	Email         EmailService
-	RedisClient   *redis.Client
+	Locker        Locker
 	CacheMutex    sync.Mutex
-	RateLimiter   *rate.Limiter
-	Timeout       time.Duration
 	CacheDuration time.Duration
+	// CacheRequired fails runs that cannot store their transactions in the
+	// cache; otherwise the failure is logged and the run goes on without it.
+	CacheRequired bool
+	AccountID     string
 }
 
-func NewTransactionUseCase(dbRepo TransactionRepository, cacheRepo CacheRepository, email EmailService, redisClient *redis.Client, rateLimit int, timeoutSec int, cacheDuration int) TransactionUseCase {
+func NewTransactionUseCase(dbRepo TransactionRepository, cacheRepo CacheRepository, statementRepo StatementRepository, email EmailService, locker Locker, cacheDuration int, cacheRequired bool, accountID string) TransactionUseCase {
 	return &transactionUseCaseImpl{
 		DBRepo:        dbRepo,
 		CacheRepo:     cacheRepo,
+		StatementRepo: statementRepo,
 		Email:         email,
-		RedisClient:   redisClient,
-		RateLimiter:   rate.NewLimiter(rate.Every(time.Second), rateLimit), // rateLimit requests per second
-		Timeout:       time.Duration(timeoutSec) * time.Second,
+		Locker:        locker,
 		CacheDuration: time.Duration(cacheDuration) * time.Second,
+		CacheRequired: cacheRequired,
+		AccountID:     accountID,
 	}
 }
 
-func (uc *transactionUseCaseImpl) ProcessTransactions(ctx context.Context) error {
-	if !uc.RateLimiter.Allow() {
-		return fmt.Errorf("too many requests")
+// ProcessTransactions runs the read-save-cache-email pipeline, timing every
+// stage in the returned result. With a Locker the pipeline runs under a
+// lease on the account and file hash: a concurrent run fails with
+// domain.ErrRunInProgress, writes are fenced with the lease token and the run
+// is aborted if the lease is lost.
+func (uc *transactionUseCaseImpl) ProcessTransactions(ctx context.Context, req domain.ProcessRequest) (result *domain.ProcessResult, err error) {
+	result = &domain.ProcessResult{AccountID: req.AccountID, Stages: []domain.StageTiming{}}
+	if result.AccountID == "" {
+		result.AccountID = uc.AccountID
+	}
+	ctx, span := tracer.Start(ctx, "ProcessTransactions", trace.WithAttributes(
+		attribute.String("stori.account_id", result.AccountID),
+		attribute.String("stori.period", req.Period),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var hash string
+	err = runStage(ctx, result, "hash", func(ctx context.Context) error {
+		var err error
+		hash, err = uc.DBRepo.GetCSVHash()
+		return sourceError(err)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if uc.Locker == nil {
+		return result, uc.process(ctx, result, hash, req.Period, nil)
+	}
+
+	var lease Lease
+	key := lockKey(result.AccountID, hash)
+	err = runStage(ctx, result, "lock", func(ctx context.Context) error {
+		var err error
+		lease, err = uc.Locker.Acquire(ctx, key)
+		if err != nil && !errors.Is(err, domain.ErrRunInProgress) {
+			return domain.WrapError(domain.ErrUnavailable, "lock_unavailable", "cannot acquire the processing lock", err)
+		}
+		return err
+	})
+	if err != nil {
+		return result, err
 	}
+	defer lease.Release(context.Background())
 
-	ctx, cancel := context.WithTimeout(ctx, uc.Timeout)
+	leaseCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	go func() {
+		select {
+		case <-lease.Lost():
+			cancel()
+		case <-leaseCtx.Done():
+		}
+	}()
 
-	hash, err := uc.DBRepo.GetCSVHash()
+	err = uc.process(leaseCtx, result, hash, req.Period, &domain.Fence{Key: key, Token: lease.Token()})
 	if err != nil {
+		select {
+		case <-lease.Lost():
+			err = domain.ErrLeaseLost
+		default:
+		}
+	}
+	return result, err
+}
+
+// process runs the pipeline stages after hashing, writing under fence.
+func (uc *transactionUseCaseImpl) process(ctx context.Context, result *domain.ProcessResult, hash, period string, fence *domain.Fence) error {
+	var transactions []domain.Transaction
+	key := cacheKey(result.AccountID, hash)
+	_ = runStage(ctx, result, "cache_lookup", func(ctx context.Context) error {
+		cached, err := uc.CacheRepo.Get(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "reading the cache failed, going on without it", "key", key, "error", err)
+		} else if cached != nil {
+			transactions = cached
+			result.CacheHit = true
+		}
+		return nil
+	})
+
+	if !result.CacheHit {
+		err := runStage(ctx, result, "read", func(ctx context.Context) error {
+			var err error
+			transactions, err = uc.DBRepo.GetAllTransactions(ctx)
+			return sourceError(err)
+		})
+		if err != nil {
+			return err
+		}
+		for i := range transactions {
+			transactions[i].AccountID = result.AccountID
+		}
+
+		err = runStage(ctx, result, "save", func(ctx context.Context) error {
+			return uc.DBRepo.SaveTransactions(ctx, transactions, fence)
+		})
+		if err != nil {
+			return err
+		}
+		result.RowsSaved = len(transactions)
+
+		err = runStage(ctx, result, "cache_store", func(ctx context.Context) error {
+			return uc.CacheRepo.Set(ctx, key, transactions)
+		})
+		if err != nil && uc.CacheRequired {
+			return err
+		} else if err != nil {
+			slog.WarnContext(ctx, "caching transactions failed, going on without the cache", "key", key, "error", err)
+		}
+	}
+	result.RowsRead = len(transactions)
+
+	if period == "" {
+		period = time.Now().Format("2006-01")
+	} else {
+		transactions = transactionsInPeriod(transactions, period)
+	}
+
+	// Nothing can be fenced at the mail server; do not send once the lease
+	// is gone.
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	return runStage(ctx, result, "email", func(ctx context.Context) error {
+		statement, err := uc.sendStatement(ctx, result.AccountID, period, transactions)
+		if statement != nil && statement.ID != 0 {
+			result.StatementID = &statement.ID
+		}
+		return err
+	})
+}
 
-	transactions, err := uc.CacheRepo.Get(ctx, hash)
-	if err == nil && transactions != nil {
-		summary := uc.generateHTMLSummary(transactions)
-		return uc.Email.SendEmail(ctx, "./internal/infrastructure/email/templates/summary_template.html", summary)
+func (uc *transactionUseCaseImpl) sendStatement(ctx context.Context, accountID, period string, transactions []domain.Transaction) (*domain.Statement, error) {
+	summary := generateHTMLSummary(transactions)
+	rendered, err := uc.Email.RenderEmail(summaryTemplatePath, summary)
+	if err != nil {
+		return nil, err
 	}
 
-	transactions, err = uc.DBRepo.GetAllTransactions(ctx)
+	statement := newStatement(accountID, period, summary, rendered)
+	return statement, deliverStatement(ctx, uc.StatementRepo, uc.Email, statement)
+}
+
+// RunInProgress reports whether the lease for the account's current file is
+// held, i.e. a run of the pipeline is in progress on any replica.
+func (uc *transactionUseCaseImpl) RunInProgress(ctx context.Context, accountID string) (bool, error) {
+	if uc.Locker == nil {
+		return false, nil
+	}
+	if accountID == "" {
+		accountID = uc.AccountID
+	}
+	hash, err := uc.DBRepo.GetCSVHash()
 	if err != nil {
-		return err
+		return false, sourceError(err)
+	}
+	held, err := uc.Locker.Held(ctx, lockKey(accountID, hash))
+	if err != nil {
+		return false, domain.WrapError(domain.ErrUnavailable, "lock_unavailable", "cannot check for runs in progress", err)
 	}
+	return held, nil
+}
 
-	err = uc.DBRepo.SaveTransactions(ctx, transactions)
+// sourceError marks a missing transaction file as domain.ErrSourceNotFound.
+func sourceError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return domain.WrapError(domain.ErrSourceNotFound, "source_not_found", "transaction source not found", err)
+	}
+	return err
+}
+
+func lockKey(accountID, hash string) string {
+	return accountID + ":" + hash
+}
+
+// cacheKey keys the transactions cached for a file by account as well, as
+// they are stored under the account that ingested them.
+func cacheKey(accountID, hash string) string {
+	return accountID + ":" + hash
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
 	if err != nil {
-		return err
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
+	span.End()
+}
 
-	err = uc.CacheRepo.Set(ctx, hash, transactions)
+// runStage runs fn as the named pipeline stage, in a span of its own, and
+// records its timing.
+func runStage(ctx context.Context, result *domain.ProcessResult, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, "stage "+name)
+	stage := domain.StageTiming{Name: name, StartedAt: time.Now()}
+	err := fn(ctx)
+	endSpan(span, err)
+	stage.DurationMs = time.Since(stage.StartedAt).Milliseconds()
 	if err != nil {
-		return err
+		stage.Error = err.Error()
 	}
+	result.Stages = append(result.Stages, stage)
+	return err
+}
 
-	summary := uc.generateHTMLSummary(transactions)
-	return uc.Email.SendEmail(ctx, "./internal/infrastructure/email/templates/summary_template.html", summary)
+// transactionsInPeriod keeps the transactions dated in the month of period
+// (2006-01). Transaction dates are month/day without a year.
+func transactionsInPeriod(transactions []domain.Transaction, period string) []domain.Transaction {
+	month, err := time.Parse("2006-01", period)
+	if err != nil {
+		return transactions
+	}
+	var filtered []domain.Transaction
+	for _, t := range transactions {
+		parts := strings.Split(t.Date, "/")
+		if len(parts) == 2 && parts[0] == strconv.Itoa(int(month.Month())) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
 }
 
-func (uc *transactionUseCaseImpl) generateHTMLSummary(transactions []domain.Transaction) map[string]interface{} {
+func generateHTMLSummary(transactions []domain.Transaction) map[string]interface{} {
 	var totalBalance float64
 	monthlyTransactions := make(map[string][]domain.Transaction)
 
@@ -3,12 +3,18 @@ package usecase
 import (
 	"context"
 	"errors"
+	"io/fs"
 	"testing"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/jordanlanch/stori-test/internal/core/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type MockTransactionRepository struct {
@@ -17,14 +23,25 @@ type MockTransactionRepository struct {
 
 func (m *MockTransactionRepository) GetAllTransactions(ctx context.Context) ([]domain.Transaction, error) {
 	args := m.Called(ctx)
-	return args.Get(0).([]domain.Transaction), args.Error(1)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
-func (m *MockTransactionRepository) SaveTransactions(ctx context.Context, transactions []domain.Transaction) error {
-	args := m.Called(ctx, transactions)
+func (m *MockTransactionRepository) SaveTransactions(ctx context.Context, transactions []domain.Transaction, fence *domain.Fence) error {
+	args := m.Called(ctx, transactions, fence)
 	return args.Error(0)
 }
 
+func (m *MockTransactionRepository) GetTransactionsByAccount(ctx context.Context, accountID string) ([]domain.Transaction, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockTransactionRepository) GetCSVHash() (string, error) {
 	args := m.Called()
 	return args.String(0), args.Error(1)
@@ -51,21 +68,74 @@ type MockEmailService struct {
 	mock.Mock
 }
 
-func (m *MockEmailService) SendEmail(ctx context.Context, templatePath string, data interface{}) error {
-	args := m.Called(ctx, templatePath, data)
+func (m *MockEmailService) RenderEmail(templatePath string, data interface{}) (*domain.RenderedEmail, error) {
+	args := m.Called(templatePath, data)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.RenderedEmail), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockEmailService) SendEmail(ctx context.Context, email *domain.RenderedEmail) (string, error) {
+	args := m.Called(ctx, email)
+	return args.String(0), args.Error(1)
+}
+
+type MockStatementRepository struct {
+	mock.Mock
+}
+
+func (m *MockStatementRepository) CreateStatement(ctx context.Context, statement *domain.Statement) error {
+	args := m.Called(ctx, statement)
 	return args.Error(0)
 }
 
+func (m *MockStatementRepository) UpdateStatement(ctx context.Context, statement *domain.Statement) error {
+	args := m.Called(ctx, statement)
+	return args.Error(0)
+}
+
+func (m *MockStatementRepository) GetStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementRepository) ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+var testRenderedEmail = &domain.RenderedEmail{
+	To:      "customer@example.com",
+	Subject: "Monthly Transaction Summary",
+	HTML:    "<p>summary</p>",
+	Text:    "summary",
+}
+
+// expectStatementSent sets up a successful render, archive and send of a statement.
+func expectStatementSent(mockStatementRepo *MockStatementRepository, mockEmail *MockEmailService) {
+	mockEmail.On("RenderEmail", "./internal/infrastructure/email/templates/summary_template.html", mock.AnythingOfType("map[string]interface {}")).Return(testRenderedEmail, nil)
+	mockStatementRepo.On("CreateStatement", mock.Anything, mock.AnythingOfType("*domain.Statement")).Return(nil)
+	mockEmail.On("SendEmail", mock.Anything, testRenderedEmail).Return("<id@example.com>", nil)
+	mockStatementRepo.On("UpdateStatement", mock.Anything, mock.MatchedBy(func(s *domain.Statement) bool {
+		return s.Status == domain.StatementStatusSent && s.MessageID == "<id@example.com>"
+	})).Return(nil)
+}
+
 func TestProcessTransactions(t *testing.T) {
 	mockDBRepo := new(MockTransactionRepository)
 	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
 	mockEmail := new(MockEmailService)
-	redisClient := &redis.Client{}
-	rateLimit := 5
-	timeoutSec := 5
 	cacheDuration := 600
 
-	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockEmail, redisClient, rateLimit, timeoutSec, cacheDuration)
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, nil, cacheDuration, false, "default")
 
 	ctx := context.Background()
 
@@ -75,30 +145,67 @@ func TestProcessTransactions(t *testing.T) {
 	}
 
 	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
-	mockCacheRepo.On("Get", mock.Anything, "hash123").Return(nil, errors.New("cache miss"))
+	mockCacheRepo.On("Get", mock.Anything, "default:hash123").Return(nil, errors.New("cache miss"))
 	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(transactions, nil)
-	mockDBRepo.On("SaveTransactions", mock.Anything, transactions).Return(nil)
-	mockCacheRepo.On("Set", mock.Anything, "hash123", transactions).Return(nil)
-	mockEmail.On("SendEmail", mock.Anything, "./internal/infrastructure/email/templates/summary_template.html", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockDBRepo.On("SaveTransactions", mock.Anything, transactions, (*domain.Fence)(nil)).Return(nil)
+	mockCacheRepo.On("Set", mock.Anything, "default:hash123", transactions).Return(nil)
+	expectStatementSent(mockStatementRepo, mockEmail)
 
-	err := useCase.ProcessTransactions(ctx)
+	result, err := useCase.ProcessTransactions(ctx, domain.ProcessRequest{})
 	assert.NoError(t, err)
+	assert.Equal(t, "default", result.AccountID)
+	assert.Equal(t, 2, result.RowsRead)
+	assert.Equal(t, 2, result.RowsSaved)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, []string{"hash", "cache_lookup", "read", "save", "cache_store", "email"}, stageNames(result))
+	assert.Equal(t, "default", transactions[0].AccountID)
 
 	mockDBRepo.AssertExpectations(t)
 	mockCacheRepo.AssertExpectations(t)
+	mockStatementRepo.AssertExpectations(t)
 	mockEmail.AssertExpectations(t)
 }
 
-func TestProcessTransactions_RateLimitExceeded(t *testing.T) {
+func TestProcessTransactions_CacheStoreFails(t *testing.T) {
+	transactions := []domain.Transaction{{ID: 1, Date: "1/1", Amount: 100}}
+	newUseCase := func(cacheRequired bool, statementRepo *MockStatementRepository, email *MockEmailService) TransactionUseCase {
+		mockDBRepo := new(MockTransactionRepository)
+		mockCacheRepo := new(MockCacheRepository)
+		mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+		mockCacheRepo.On("Get", mock.Anything, "default:hash123").Return(nil, errors.New("connection refused"))
+		mockDBRepo.On("GetAllTransactions", mock.Anything).Return(transactions, nil)
+		mockDBRepo.On("SaveTransactions", mock.Anything, transactions, (*domain.Fence)(nil)).Return(nil)
+		mockCacheRepo.On("Set", mock.Anything, "default:hash123", transactions).Return(errors.New("connection refused"))
+		return NewTransactionUseCase(mockDBRepo, mockCacheRepo, statementRepo, email, nil, 600, cacheRequired, "default")
+	}
+
+	t.Run("degraded", func(t *testing.T) {
+		mockStatementRepo := new(MockStatementRepository)
+		mockEmail := new(MockEmailService)
+		expectStatementSent(mockStatementRepo, mockEmail)
+
+		result, err := newUseCase(false, mockStatementRepo, mockEmail).ProcessTransactions(context.Background(), domain.ProcessRequest{})
+		assert.NoError(t, err, "the run goes on without the cache")
+		assert.Equal(t, []string{"hash", "cache_lookup", "read", "save", "cache_store", "email"}, stageNames(result))
+		assert.Equal(t, "connection refused", result.Stages[4].Error)
+		mockEmail.AssertExpectations(t)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		result, err := newUseCase(true, new(MockStatementRepository), new(MockEmailService)).ProcessTransactions(context.Background(), domain.ProcessRequest{})
+		assert.EqualError(t, err, "connection refused")
+		assert.Equal(t, []string{"hash", "cache_lookup", "read", "save", "cache_store"}, stageNames(result))
+	})
+}
+
+func TestProcessTransactions_CacheHit(t *testing.T) {
 	mockDBRepo := new(MockTransactionRepository)
 	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
 	mockEmail := new(MockEmailService)
-	redisClient := &redis.Client{}
-	rateLimit := 1
-	timeoutSec := 5
 	cacheDuration := 600
 
-	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockEmail, redisClient, rateLimit, timeoutSec, cacheDuration)
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, nil, cacheDuration, false, "default")
 
 	ctx := context.Background()
 
@@ -108,78 +215,309 @@ func TestProcessTransactions_RateLimitExceeded(t *testing.T) {
 	}
 
 	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
-	mockCacheRepo.On("Get", mock.Anything, "hash123").Return(nil, errors.New("cache miss"))
-	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(transactions, nil)
-	mockDBRepo.On("SaveTransactions", mock.Anything, transactions).Return(nil)
-	mockCacheRepo.On("Set", mock.Anything, "hash123", transactions).Return(nil)
-	mockEmail.On("SendEmail", mock.Anything, "./internal/infrastructure/email/templates/summary_template.html", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockCacheRepo.On("Get", mock.Anything, "default:hash123").Return(transactions, nil)
+	expectStatementSent(mockStatementRepo, mockEmail)
 
-	// First request should succeed
-	err := useCase.ProcessTransactions(ctx)
+	result, err := useCase.ProcessTransactions(ctx, domain.ProcessRequest{})
 	assert.NoError(t, err)
-
-	// Second request should exceed rate limit
-	err = useCase.ProcessTransactions(ctx)
-	assert.Error(t, err)
-	assert.Equal(t, "too many requests", err.Error())
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, 2, result.RowsRead)
+	assert.Equal(t, 0, result.RowsSaved)
+	assert.Equal(t, []string{"hash", "cache_lookup", "email"}, stageNames(result))
 
 	mockDBRepo.AssertExpectations(t)
 	mockCacheRepo.AssertExpectations(t)
+	mockStatementRepo.AssertExpectations(t)
 	mockEmail.AssertExpectations(t)
 }
 
-func TestProcessTransactions_CacheHit(t *testing.T) {
+func TestProcessTransactions_CacheIsPerAccount(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
+	mockEmail := new(MockEmailService)
+
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, nil, 600, false, "default")
+
+	cached := []domain.Transaction{{ID: 1, AccountID: "acc-1", Date: "1/1", Amount: 100}}
+	transactions := []domain.Transaction{{ID: 1, Date: "1/1", Amount: 100}}
+
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-1:hash123").Return(cached, nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-2:hash123").Return(nil, nil)
+	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(transactions, nil)
+	mockDBRepo.On("SaveTransactions", mock.Anything, transactions, (*domain.Fence)(nil)).Return(nil)
+	mockCacheRepo.On("Set", mock.Anything, "acc-2:hash123", transactions).Return(nil)
+	mockEmail.On("RenderEmail", mock.Anything, mock.Anything).Return(testRenderedEmail, nil)
+	mockStatementRepo.On("CreateStatement", mock.Anything, mock.Anything).Return(nil)
+	mockEmail.On("SendEmail", mock.Anything, testRenderedEmail).Return("<id@example.com>", nil)
+	mockStatementRepo.On("UpdateStatement", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{AccountID: "acc-1"})
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+
+	// The same file ingested for another account is not served from the
+	// first account's cache.
+	result, err = useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{AccountID: "acc-2"})
+	require.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, 1, result.RowsSaved)
+	assert.Equal(t, "acc-2", transactions[0].AccountID)
+
+	mockDBRepo.AssertNumberOfCalls(t, "GetAllTransactions", 1)
+	mockCacheRepo.AssertExpectations(t)
+}
+
+func TestProcessTransactions_DBError(t *testing.T) {
 	mockDBRepo := new(MockTransactionRepository)
 	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
 	mockEmail := new(MockEmailService)
-	redisClient := &redis.Client{}
-	rateLimit := 5
-	timeoutSec := 5
 	cacheDuration := 600
 
-	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockEmail, redisClient, rateLimit, timeoutSec, cacheDuration)
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, nil, cacheDuration, false, "default")
 
 	ctx := context.Background()
 
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockCacheRepo.On("Get", mock.Anything, "default:hash123").Return(nil, errors.New("cache miss"))
+	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(nil, errors.New("db error"))
+
+	result, err := useCase.ProcessTransactions(ctx, domain.ProcessRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, "db error", err.Error())
+	assert.Equal(t, []string{"hash", "cache_lookup", "read"}, stageNames(result))
+	assert.Equal(t, "db error", result.Stages[2].Error)
+
+	mockDBRepo.AssertExpectations(t)
+	mockCacheRepo.AssertExpectations(t)
+	mockStatementRepo.AssertExpectations(t)
+	mockEmail.AssertExpectations(t)
+}
+
+func TestProcessTransactions_Spans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	mockDBRepo := new(MockTransactionRepository)
+	mockCacheRepo := new(MockCacheRepository)
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, new(MockStatementRepository), new(MockEmailService), nil, 600, false, "default")
+
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-1:hash123").Return(nil, nil)
+	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(nil, errors.New("db error"))
+
+	_, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{AccountID: "acc-1"})
+	assert.EqualError(t, err, "db error")
+
+	spans := exporter.GetSpans()
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	assert.Equal(t, []string{"stage hash", "stage cache_lookup", "stage read", "ProcessTransactions"}, names)
+	pipeline := spans[3]
+	for _, stage := range spans[:3] {
+		assert.Equal(t, pipeline.SpanContext.SpanID(), stage.Parent.SpanID())
+	}
+	assert.Equal(t, codes.Error, spans[2].Status.Code)
+	assert.Equal(t, codes.Error, pipeline.Status.Code)
+	assert.Contains(t, pipeline.Attributes, attribute.String("stori.account_id", "acc-1"))
+}
+
+func TestProcessTransactions_EmailFailureIsArchived(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
+	mockEmail := new(MockEmailService)
+
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, nil, 600, false, "acc-1")
+
 	transactions := []domain.Transaction{
 		{ID: 1, Date: "1/1", Amount: 100},
-		{ID: 2, Date: "1/2", Amount: -50},
 	}
 
 	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
-	mockCacheRepo.On("Get", mock.Anything, "hash123").Return(transactions, nil)
-	mockEmail.On("SendEmail", mock.Anything, "./internal/infrastructure/email/templates/summary_template.html", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-1:hash123").Return(transactions, nil)
+	mockEmail.On("RenderEmail", mock.Anything, mock.Anything).Return(testRenderedEmail, nil)
+	mockStatementRepo.On("CreateStatement", mock.Anything, mock.MatchedBy(func(s *domain.Statement) bool {
+		return s.AccountID == "acc-1" &&
+			s.Recipient == "customer@example.com" &&
+			s.Status == domain.StatementStatusPending &&
+			s.BodyHash == hashBody("<p>summary</p>")
+	})).Return(nil)
+	mockEmail.On("SendEmail", mock.Anything, testRenderedEmail).Return("", errors.New("smtp unavailable"))
+	mockStatementRepo.On("UpdateStatement", mock.Anything, mock.MatchedBy(func(s *domain.Statement) bool {
+		return s.Status == domain.StatementStatusFailed && s.Error == "smtp unavailable" && s.SentAt == nil
+	})).Return(nil)
+
+	result, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{})
+	assert.EqualError(t, err, "smtp unavailable")
+	assert.Equal(t, "smtp unavailable", result.Stages[len(result.Stages)-1].Error)
+
+	mockStatementRepo.AssertExpectations(t)
+	mockEmail.AssertExpectations(t)
+}
+
+func stageNames(result *domain.ProcessResult) []string {
+	names := make([]string, len(result.Stages))
+	for i, stage := range result.Stages {
+		names[i] = stage.Name
+	}
+	return names
+}
+
+func TestProcessTransactions_Period(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
+	mockEmail := new(MockEmailService)
+
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, nil, 600, false, "acc-1")
 
-	err := useCase.ProcessTransactions(ctx)
+	transactions := []domain.Transaction{
+		{ID: 1, Date: "4/30", Amount: 100},
+		{ID: 2, Date: "5/2", Amount: -50},
+		{ID: 3, Date: "5/20", Amount: 20},
+	}
+
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-1:hash123").Return(transactions, nil)
+	mockEmail.On("RenderEmail", mock.Anything, mock.MatchedBy(func(summary map[string]interface{}) bool {
+		return summary["TotalBalance"] == -30.0
+	})).Return(testRenderedEmail, nil)
+	mockStatementRepo.On("CreateStatement", mock.Anything, mock.MatchedBy(func(s *domain.Statement) bool {
+		return s.Period == "2024-05"
+	})).Return(nil)
+	mockEmail.On("SendEmail", mock.Anything, testRenderedEmail).Return("<id@example.com>", nil)
+	mockStatementRepo.On("UpdateStatement", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{Period: "2024-05"})
 	assert.NoError(t, err)
+	assert.Equal(t, 3, result.RowsRead)
 
-	mockDBRepo.AssertExpectations(t)
-	mockCacheRepo.AssertExpectations(t)
+	mockStatementRepo.AssertExpectations(t)
 	mockEmail.AssertExpectations(t)
 }
 
-func TestProcessTransactions_DBError(t *testing.T) {
+type MockLocker struct {
+	mock.Mock
+}
+
+func (m *MockLocker) Acquire(ctx context.Context, key string) (Lease, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) != nil {
+		return args.Get(0).(Lease), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLocker) Held(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+type testLease struct {
+	token    int64
+	lost     chan struct{}
+	released bool
+}
+
+func (l *testLease) Token() int64                  { return l.token }
+func (l *testLease) Lost() <-chan struct{}         { return l.lost }
+func (l *testLease) Release(context.Context) error { l.released = true; return nil }
+
+func TestProcessTransactions_Locked(t *testing.T) {
 	mockDBRepo := new(MockTransactionRepository)
 	mockCacheRepo := new(MockCacheRepository)
+	mockStatementRepo := new(MockStatementRepository)
 	mockEmail := new(MockEmailService)
-	redisClient := &redis.Client{}
-	rateLimit := 5
-	timeoutSec := 5
-	cacheDuration := 600
+	mockLocker := new(MockLocker)
 
-	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockEmail, redisClient, rateLimit, timeoutSec, cacheDuration)
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, mockStatementRepo, mockEmail, mockLocker, 600, false, "acc-1")
 
-	ctx := context.Background()
+	transactions := []domain.Transaction{{ID: 1, Date: "1/1", Amount: 100}}
+	lease := &testLease{token: 7, lost: make(chan struct{})}
 
 	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
-	mockCacheRepo.On("Get", mock.Anything, "hash123").Return(nil, errors.New("cache miss"))
-	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(nil, errors.New("db error"))
+	mockLocker.On("Acquire", mock.Anything, "acc-1:hash123").Return(lease, nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-1:hash123").Return(nil, errors.New("cache miss"))
+	mockDBRepo.On("GetAllTransactions", mock.Anything).Return(transactions, nil)
+	mockDBRepo.On("SaveTransactions", mock.Anything, transactions, &domain.Fence{Key: "acc-1:hash123", Token: 7}).Return(nil)
+	mockCacheRepo.On("Set", mock.Anything, "acc-1:hash123", transactions).Return(nil)
+	expectStatementSent(mockStatementRepo, mockEmail)
 
-	err := useCase.ProcessTransactions(ctx)
-	assert.Error(t, err)
-	assert.Equal(t, "db error", err.Error())
+	result, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hash", "lock", "cache_lookup", "read", "save", "cache_store", "email"}, stageNames(result))
+	assert.True(t, lease.released)
 
 	mockDBRepo.AssertExpectations(t)
-	mockCacheRepo.AssertExpectations(t)
-	mockEmail.AssertExpectations(t)
+	mockLocker.AssertExpectations(t)
+}
+
+func TestProcessTransactions_RunInProgress(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	mockLocker := new(MockLocker)
+
+	useCase := NewTransactionUseCase(mockDBRepo, new(MockCacheRepository), new(MockStatementRepository), new(MockEmailService), mockLocker, 600, false, "acc-1")
+
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockLocker.On("Acquire", mock.Anything, "acc-1:hash123").Return(nil, domain.ErrRunInProgress)
+
+	result, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{})
+	assert.ErrorIs(t, err, domain.ErrRunInProgress)
+	assert.Equal(t, []string{"hash", "lock"}, stageNames(result))
+	mockDBRepo.AssertNotCalled(t, "GetAllTransactions", mock.Anything)
+}
+
+func TestProcessTransactions_LeaseLost(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	mockCacheRepo := new(MockCacheRepository)
+	mockEmail := new(MockEmailService)
+	mockLocker := new(MockLocker)
+
+	useCase := NewTransactionUseCase(mockDBRepo, mockCacheRepo, new(MockStatementRepository), mockEmail, mockLocker, 600, false, "acc-1")
+
+	lease := &testLease{token: 7, lost: make(chan struct{})}
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockLocker.On("Acquire", mock.Anything, "acc-1:hash123").Return(lease, nil)
+	mockCacheRepo.On("Get", mock.Anything, "acc-1:hash123").Return(nil, errors.New("cache miss"))
+	// The lease expires while the file is being read.
+	mockDBRepo.On("GetAllTransactions", mock.Anything).Run(func(args mock.Arguments) {
+		close(lease.lost)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil, context.Canceled)
+
+	_, err := useCase.ProcessTransactions(context.Background(), domain.ProcessRequest{})
+	assert.ErrorIs(t, err, domain.ErrLeaseLost)
+	mockDBRepo.AssertNotCalled(t, "SaveTransactions", mock.Anything, mock.Anything, mock.Anything)
+	mockEmail.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+}
+
+func TestRunInProgress(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	mockLocker := new(MockLocker)
+	useCase := NewTransactionUseCase(mockDBRepo, new(MockCacheRepository), new(MockStatementRepository), new(MockEmailService), mockLocker, 600, false, "default")
+
+	mockDBRepo.On("GetCSVHash").Return("hash123", nil)
+	mockLocker.On("Held", mock.Anything, "default:hash123").Return(true, nil)
+
+	inProgress, err := useCase.RunInProgress(context.Background(), "")
+	assert.NoError(t, err)
+	assert.True(t, inProgress)
+}
+
+func TestRunInProgress_SourceNotFound(t *testing.T) {
+	mockDBRepo := new(MockTransactionRepository)
+	useCase := NewTransactionUseCase(mockDBRepo, new(MockCacheRepository), new(MockStatementRepository), new(MockEmailService), new(MockLocker), 600, false, "default")
+
+	mockDBRepo.On("GetCSVHash").Return("", &fs.PathError{Op: "open", Path: "/srv/transactions.csv", Err: fs.ErrNotExist})
+
+	_, err := useCase.RunInProgress(context.Background(), "")
+	assert.ErrorIs(t, err, domain.ErrSourceNotFound)
+	var domainErr *domain.Error
+	assert.ErrorAs(t, err, &domainErr)
+	assert.Equal(t, "transaction source not found", domainErr.Message)
 }
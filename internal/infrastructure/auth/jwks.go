@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefreshInterval bounds how often an unknown key ID triggers a refresh,
+// so tokens with made-up key IDs cannot hammer the JWKS endpoint.
+const minRefreshInterval = 30 * time.Second
+
+// JWKSConfig locates a JSON Web Key Set: a URL, e.g. an OIDC provider's
+// jwks_uri, or a local File. Keys are reloaded every RefreshInterval and
+// when a token is signed with an unknown key.
+type JWKSConfig struct {
+	URL             string
+	File            string
+	RefreshInterval time.Duration
+	HTTPClient      *http.Client
+}
+
+// errUnsupportedKey marks keys of a type or curve JWKS cannot verify with.
+var errUnsupportedKey = errors.New("unsupported key")
+
+// JWKS holds the signature verification keys of a JSON Web Key Set by key
+// ID. RSA, EC (P-256, P-384, P-521) and Ed25519 keys are supported; other
+// keys in the set are ignored. Refreshes run outside the lock guarding the
+// keys, one at a time.
+type JWKS struct {
+	config  JWKSConfig
+	refresh singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	refreshedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWKS loads the key set, failing if it cannot be read.
+func NewJWKS(ctx context.Context, config JWKSConfig) (*JWKS, error) {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Hour
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	j := &JWKS{config: config}
+	if err := j.load(ctx); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Key returns the key with ID kid. A failed refresh keeps the keys loaded
+// before it.
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	age := time.Since(j.refreshedAt)
+	j.mu.RUnlock()
+
+	if age > j.config.RefreshInterval || (!ok && age > minRefreshInterval) {
+		// Callers share the refresh in flight, which outlives those that
+		// give up waiting for it.
+		done := j.refresh.DoChan("", func() (interface{}, error) {
+			return nil, j.load(context.WithoutCancel(ctx))
+		})
+		select {
+		case result := <-done:
+			if result.Err == nil {
+				j.mu.RLock()
+				key, ok = j.keys[kid]
+				j.mu.RUnlock()
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	return key, nil
+}
+
+// load reads the key set and replaces the keys with it.
+func (j *JWKS) load(ctx context.Context) error {
+	j.mu.Lock()
+	j.refreshedAt = time.Now()
+	j.mu.Unlock()
+
+	data, err := j.read(ctx)
+	if err != nil {
+		return fmt.Errorf("read JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if errors.Is(err, errUnsupportedKey) {
+			slog.DebugContext(ctx, "ignoring JWKS key", "kid", jwk.Kid, "reason", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("parse JWKS key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if j.config.File != "" {
+		return os.ReadFile(j.config.File)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", j.config.URL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: type %q", errUnsupportedKey, k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer %q", value)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// KeySource looks up token verification keys by key ID.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWTConfig sets what a token must carry. Issuer and Audience are checked
+// when set. AccountClaim names the claim listing the caller's accounts, as a
+// string or an array of strings. Scopes come from the "scope" (space
+// separated) or "scp" claim, keeping the ones this service knows;
+// DefaultScopes are granted when there are none.
+type JWTConfig struct {
+	Issuer        string
+	Audience      string
+	AccountClaim  string
+	DefaultScopes []string
+	Leeway        time.Duration
+}
+
+// JWTVerifier validates bearer tokens signed with asymmetric keys.
+type JWTVerifier struct {
+	keys   KeySource
+	config JWTConfig
+}
+
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+func NewJWTVerifier(keys KeySource, config JWTConfig) *JWTVerifier {
+	if config.AccountClaim == "" {
+		config.AccountClaim = "accounts"
+	}
+	return &JWTVerifier{keys: keys, config: config}
+}
+
+// Verify checks the token's signature, expiry, issuer and audience and
+// returns its subject with the accounts and scopes it grants.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*domain.Principal, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.config.Leeway),
+	}
+	if v.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.config.Issuer))
+	}
+	if v.config.Audience != "" {
+		options = append(options, jwt.WithAudience(v.config.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	}, options...)
+	if err != nil {
+		return nil, invalidToken(err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, invalidToken(fmt.Errorf("token has no subject"))
+	}
+	accounts, err := stringList(claims[v.config.AccountClaim])
+	if err != nil {
+		return nil, invalidToken(fmt.Errorf("claim %s: %w", v.config.AccountClaim, err))
+	}
+	return &domain.Principal{Subject: subject, Scopes: v.scopes(claims), Accounts: accounts}, nil
+}
+
+func (v *JWTVerifier) scopes(claims jwt.MapClaims) []string {
+	var granted []string
+	if scope, ok := claims["scope"].(string); ok {
+		granted = strings.Fields(scope)
+	} else {
+		granted, _ = stringList(claims["scp"])
+	}
+
+	var scopes []string
+	for _, scope := range granted {
+		for _, known := range domain.Scopes {
+			if scope == known {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		return v.config.DefaultScopes
+	}
+	return scopes
+}
+
+// stringList reads a claim holding a string or an array of strings.
+func stringList(claim interface{}) ([]string, error) {
+	switch value := claim.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected strings, got %T", item)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a string or an array of strings, got %T", claim)
+	}
+}
+
+func invalidToken(err error) error {
+	return domain.WrapError(domain.ErrUnauthorized, "invalid_token", "invalid or expired bearer token", err)
+}
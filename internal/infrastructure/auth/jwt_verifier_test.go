@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadPrivateKey(t *testing.T, path string) crypto.Signer {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	return key.(crypto.Signer)
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid, keyPath string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(loadPrivateKey(t, keyPath))
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":      "https://id.example.com",
+		"aud":      "stori-api",
+		"sub":      "user-1",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+		"accounts": []string{"acc-1", "acc-2"},
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	jwks, err := NewJWKS(context.Background(), JWKSConfig{File: "testdata/jwks.json"})
+	require.NoError(t, err)
+	verifier := NewJWTVerifier(jwks, JWTConfig{Issuer: "https://id.example.com", Audience: "stori-api", DefaultScopes: []string{domain.ScopeRead}})
+	ctx := context.Background()
+
+	t.Run("signing keys", func(t *testing.T) {
+		for _, tt := range []struct {
+			method jwt.SigningMethod
+			kid    string
+			key    string
+		}{
+			{jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem"},
+			{jwt.SigningMethodES256, "ec-1", "testdata/ec.pem"},
+			{jwt.SigningMethodEdDSA, "ed-1", "testdata/ed25519.pem"},
+		} {
+			principal, err := verifier.Verify(ctx, signToken(t, tt.method, tt.kid, tt.key, validClaims()))
+			require.NoError(t, err, tt.kid)
+			assert.Equal(t, &domain.Principal{Subject: "user-1", Scopes: []string{domain.ScopeRead}, Accounts: []string{"acc-1", "acc-2"}}, principal)
+		}
+	})
+
+	t.Run("claims", func(t *testing.T) {
+		claims := validClaims()
+		claims["accounts"] = "acc-3"
+		claims["scope"] = "openid profile ingest read"
+		principal, err := verifier.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"acc-3"}, principal.Accounts)
+		assert.Equal(t, []string{domain.ScopeIngest, domain.ScopeRead}, principal.Scopes)
+
+		delete(claims, "scope")
+		claims["scp"] = []string{"admin"}
+		principal, err = verifier.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims))
+		require.NoError(t, err)
+		assert.Equal(t, []string{domain.ScopeAdmin}, principal.Scopes)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		tokens := map[string]string{}
+		claims := validClaims()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		tokens["expired"] = signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims)
+		claims = validClaims()
+		delete(claims, "exp")
+		tokens["no expiry"] = signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims)
+		claims = validClaims()
+		claims["iss"] = "https://evil.example.com"
+		tokens["wrong issuer"] = signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims)
+		claims = validClaims()
+		claims["aud"] = "other-api"
+		tokens["wrong audience"] = signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims)
+		claims = validClaims()
+		delete(claims, "sub")
+		tokens["no subject"] = signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims)
+		claims = validClaims()
+		claims["accounts"] = 7
+		tokens["bad accounts"] = signToken(t, jwt.SigningMethodRS256, "rsa-1", "testdata/rsa.pem", claims)
+		tokens["key mismatch"] = signToken(t, jwt.SigningMethodRS256, "ec-1", "testdata/rsa.pem", validClaims())
+		tokens["unknown key"] = signToken(t, jwt.SigningMethodRS256, "rsa-2", "testdata/rsa.pem", validClaims())
+		hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+		hmac.Header["kid"] = "rsa-1"
+		tokens["symmetric"], _ = hmac.SignedString([]byte("secret"))
+		tokens["garbage"] = "not.a.token"
+
+		for name, token := range tokens {
+			_, err := verifier.Verify(ctx, token)
+			assert.ErrorIs(t, err, domain.ErrUnauthorized, name)
+		}
+	})
+}
+
+func TestJWKS_URL(t *testing.T) {
+	jwks, err := os.ReadFile("testdata/jwks.json")
+	require.NoError(t, err)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	keys, err := NewJWKS(context.Background(), JWKSConfig{URL: server.URL})
+	require.NoError(t, err)
+
+	_, err = keys.Key(context.Background(), "ed-1")
+	assert.NoError(t, err)
+	_, err = keys.Key(context.Background(), "unknown")
+	assert.Error(t, err)
+	// Unknown key IDs refresh the set at most every minRefreshInterval.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	keys.refreshedAt = time.Now().Add(-time.Minute)
+	_, err = keys.Key(context.Background(), "unknown")
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	_, err = NewJWKS(context.Background(), JWKSConfig{URL: server.URL + "/missing\x00"})
+	assert.Error(t, err)
+}
+
+func TestJWKS_IgnoresUnsupportedKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys": [
+			{"kty": "oct", "kid": "hmac-1", "k": "c2VjcmV0"},
+			{"kty": "EC", "kid": "k1-1", "crv": "secp256k1", "x": "AQ", "y": "AQ"},
+			{"kty": "OKP", "kid": "x-1", "crv": "X25519", "x": "AQ"},
+			{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+		]}`))
+	}))
+	defer server.Close()
+
+	keys, err := NewJWKS(context.Background(), JWKSConfig{URL: server.URL})
+	require.NoError(t, err)
+	_, err = keys.Key(context.Background(), "ed-1")
+	assert.NoError(t, err)
+	_, err = keys.Key(context.Background(), "hmac-1")
+	assert.Error(t, err)
+
+	// Malformed keys of a supported type still fail the set.
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys": [{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": "AQ"}]}`))
+	}))
+	defer bad.Close()
+	_, err = NewJWKS(context.Background(), JWKSConfig{URL: bad.URL})
+	assert.Error(t, err)
+}
+
+func TestJWKS_RefreshOutsideLock(t *testing.T) {
+	jwks, err := os.ReadFile("testdata/jwks.json")
+	require.NoError(t, err)
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	keys, err := NewJWKS(context.Background(), JWKSConfig{URL: server.URL})
+	require.NoError(t, err)
+	keys.refreshedAt = time.Now().Add(-time.Minute)
+
+	// Unknown key IDs wait for a single refresh...
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := keys.Key(context.Background(), "unknown")
+			results <- err
+		}()
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 2 }, time.Second, 5*time.Millisecond)
+
+	// ...while known ones are served from the keys loaded before it.
+	_, err = keys.Key(context.Background(), "ed-1")
+	assert.NoError(t, err)
+
+	// Callers can stop waiting for it.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	keys.mu.Lock()
+	keys.refreshedAt = time.Now().Add(-time.Minute)
+	keys.mu.Unlock()
+	_, err = keys.Key(ctx, "unknown")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.Error(t, <-results)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,172 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey writes a PEM encoded key to a temporary file and returns its path.
+func writeKey(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dkim.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// localLookup serves the DKIM TXT record of stori.test for the verifier
+// instead of querying DNS.
+func localLookup(t *testing.T, record string) func(string) ([]string, error) {
+	return func(name string) ([]string, error) {
+		assert.Equal(t, "statements._domainkey.stori.test", name)
+		return []string{record}, nil
+	}
+}
+
+func signedTestMessage(t *testing.T, signer *DKIMSigner) []byte {
+	t.Helper()
+	rendered := &domain.RenderedEmail{
+		To:      "customer@example.com",
+		Subject: DefaultSubject,
+		HTML:    "<p>Total balance: 39.74</p>",
+		Text:    "Total balance: 39.74",
+	}
+	msg, _, err := buildEmailMessage("statements@stori.test", rendered.To, rendered, "", time.Now())
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(msg)
+	require.NoError(t, err)
+	return signed
+}
+
+func verify(t *testing.T, msg []byte, record string) *dkim.Verification {
+	t.Helper()
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(msg), &dkim.VerifyOptions{LookupTXT: localLookup(t, record)})
+	require.NoError(t, err)
+	require.Len(t, verifications, 1)
+	return verifications[0]
+}
+
+func TestDKIMSigner_RSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	record := "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(publicDER)
+
+	for name, path := range map[string]string{
+		"pkcs1": writeKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		"pkcs8": writeKey(t, "PRIVATE KEY", mustPKCS8(t, key)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			signer, err := NewDKIMSigner(DKIMConfig{Domain: "stori.test", Selector: "statements", PrivateKeyPath: path})
+			require.NoError(t, err)
+
+			signed := signedTestMessage(t, signer)
+			assert.Contains(t, string(signed), "a=rsa-sha256")
+			assert.Contains(t, string(signed), "c=relaxed/relaxed")
+
+			verification := verify(t, signed, record)
+			assert.NoError(t, verification.Err)
+			assert.Equal(t, "stori.test", verification.Domain)
+		})
+	}
+}
+
+func TestDKIMSigner_Ed25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	record := "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(publicKey)
+
+	path := writeKey(t, "PRIVATE KEY", mustPKCS8(t, privateKey))
+	signer, err := NewDKIMSigner(DKIMConfig{Domain: "stori.test", Selector: "statements", PrivateKeyPath: path})
+	require.NoError(t, err)
+
+	signed := signedTestMessage(t, signer)
+	assert.Contains(t, string(signed), "a=ed25519-sha256")
+
+	verification := verify(t, signed, record)
+	assert.NoError(t, verification.Err)
+}
+
+func TestDKIMSigner_TamperedBody(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	record := "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(publicKey)
+
+	path := writeKey(t, "PRIVATE KEY", mustPKCS8(t, privateKey))
+	signer, err := NewDKIMSigner(DKIMConfig{Domain: "stori.test", Selector: "statements", PrivateKeyPath: path})
+	require.NoError(t, err)
+
+	signed := signedTestMessage(t, signer)
+	tampered := []byte(strings.Replace(string(signed), "39.74", "99.74", 1))
+
+	verification := verify(t, tampered, record)
+	assert.Error(t, verification.Err)
+}
+
+func TestNewDKIMSigner_Errors(t *testing.T) {
+	_, err := NewDKIMSigner(DKIMConfig{PrivateKeyPath: "unused"})
+	assert.EqualError(t, err, "dkim: domain and selector are required")
+
+	_, err = NewDKIMSigner(DKIMConfig{Domain: "stori.test", Selector: "statements", PrivateKeyPath: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "dkim: reading private key")
+
+	path := writeKey(t, "CERTIFICATE", []byte("not a key"))
+	_, err = NewDKIMSigner(DKIMConfig{Domain: "stori.test", Selector: "statements", PrivateKeyPath: path})
+	assert.EqualError(t, err, `dkim: unsupported PEM block "CERTIFICATE"`)
+}
+
+func TestSendEmail_DKIMSigned(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	record := "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(publicKey)
+
+	signer, err := NewDKIMSigner(DKIMConfig{Domain: "stori.test", Selector: "statements", PrivateKeyPath: writeKey(t, "PRIVATE KEY", mustPKCS8(t, privateKey))})
+	require.NoError(t, err)
+
+	service := NewEmailService(Config{
+		From:     "statements@stori.test",
+		To:       "customer@example.com",
+		Password: "secret",
+		SMTPHost: "smtp.stori.test",
+		SMTPPort: 587,
+		DKIM:     signer,
+	})
+	var sent []byte
+	service.sendMailFn = func(_ string, _ smtp.Auth, _ string, _ []string, msg []byte) error {
+		sent = msg
+		return nil
+	}
+
+	rendered, err := service.RenderEmail(testTemplatePath, testSummary())
+	require.NoError(t, err)
+	_, err = service.SendEmail(context.Background(), rendered)
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(sent, []byte("DKIM-Signature:")))
+	assert.NoError(t, verify(t, sent, record).Err)
+}
+
+func mustPKCS8(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return der
+}
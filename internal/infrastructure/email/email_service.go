@@ -3,73 +3,348 @@ package email
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"html/template"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const DefaultSubject = "Monthly Transaction Summary"
+
+var tracer = otel.Tracer("github.com/jordanlanch/stori-test/internal/infrastructure/email")
+
+// Config holds the SMTP settings and delivery options of the EmailService.
+type Config struct {
+	From     string
+	To       string
+	Password string
+	SMTPHost string
+	SMTPPort int
+	Subject  string
+	// Fake renders and archives messages without handing them to SMTP.
+	Fake bool
+	// ArchiveDir, when set, receives a copy of every rendered statement.
+	ArchiveDir string
+	// DKIM, when set, signs every outgoing message.
+	DKIM *DKIMSigner
+}
+
 type EmailService struct {
 	mu         sync.Mutex
+	config     Config
 	sendMailFn func(string, smtp.Auth, string, []string, []byte) error
+
+	templatesMu sync.RWMutex
+	templates   map[string]*templates
 }
 
-func NewEmailService() *EmailService {
+// templates are the parsed HTML template and plain text sibling of a path.
+type templates struct {
+	html *template.Template
+	text *texttemplate.Template
+}
+
+func NewEmailService(config Config) *EmailService {
+	if config.Subject == "" {
+		config.Subject = DefaultSubject
+	}
 	return &EmailService{
+		config:     config,
 		sendMailFn: smtp.SendMail,
+		templates:  map[string]*templates{},
 	}
 }
 
-func (s *EmailService) SendEmail(ctx context.Context, templatePath string, data interface{}) error {
+// SetRecipient replaces the default recipient of statements.
+func (s *EmailService) SetRecipient(to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.To = to
+}
+
+// SetPassword replaces the SMTP password, e.g. once it has been rotated.
+func (s *EmailService) SetPassword(password string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.config.Password = password
+}
 
-	from := os.Getenv("EMAIL_FROM")
-	to := os.Getenv("EMAIL_TO")
-	password := os.Getenv("EMAIL_PASSWORD")
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
+// RenderEmail renders the HTML template at templatePath and its plain text
+// sibling (same name with a .txt extension) for the configured recipient
+// without sending anything. Templates are parsed on first use and kept
+// until ReloadTemplates.
+func (s *EmailService) RenderEmail(templatePath string, data interface{}) (*domain.RenderedEmail, error) {
+	tmpl, err := s.template(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	var html bytes.Buffer
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+	var text bytes.Buffer
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
 
-	if from == "" || to == "" || password == "" || smtpHost == "" || smtpPort == "" {
-		return errors.New("missing required environment variables for email configuration")
+	s.mu.Lock()
+	to, subject := s.config.To, s.config.Subject
+	s.mu.Unlock()
+	if subject == "" {
+		subject = DefaultSubject
 	}
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	msg, err := buildEmailMessage(templatePath, data)
+	return &domain.RenderedEmail{
+		To:      to,
+		Subject: subject,
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+func (s *EmailService) template(path string) (*templates, error) {
+	s.templatesMu.RLock()
+	tmpl, ok := s.templates[path]
+	s.templatesMu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := parseTemplates(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	s.templatesMu.Lock()
+	defer s.templatesMu.Unlock()
+	s.templates[path] = tmpl
+	return tmpl, nil
+}
+
+// CheckTemplates parses again the templates in use, without replacing them,
+// and returns the first error found.
+func (s *EmailService) CheckTemplates() error {
+	_, err := s.parseAll()
+	return err
+}
 
-	// Guardar el mensaje como un archivo HTML
-	err = os.WriteFile("./internal/infrastructure/email/output_email/email_output.html", msg, 0644)
+// ReloadTemplates parses again the templates in use and replaces them, or
+// keeps them all if any fails to parse.
+func (s *EmailService) ReloadTemplates() error {
+	parsed, err := s.parseAll()
 	if err != nil {
 		return err
 	}
+	s.templatesMu.Lock()
+	defer s.templatesMu.Unlock()
+	s.templates = parsed
+	return nil
+}
 
-	if s.sendMailFn == nil {
-		return errors.New("sendMailFn is not initialized")
+func (s *EmailService) parseAll() (map[string]*templates, error) {
+	s.templatesMu.RLock()
+	paths := make([]string, 0, len(s.templates))
+	for path := range s.templates {
+		paths = append(paths, path)
 	}
+	s.templatesMu.RUnlock()
 
-	err = s.sendMailFn(smtpHost+":"+smtpPort, auth, from, []string{to}, msg)
+	parsed := make(map[string]*templates, len(paths))
+	for _, path := range paths {
+		tmpl, err := parseTemplates(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed[path] = tmpl
+	}
+	return parsed, nil
+}
+
+func parseTemplates(path string) (*templates, error) {
+	html, err := template.ParseFiles(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	text, err := texttemplate.ParseFiles(textTemplatePath(path))
+	if err != nil {
+		return nil, err
+	}
+	return &templates{html: html, text: text}, nil
+}
 
-	return nil
+// SendEmail delivers a rendered email and returns its Message-ID. The
+// recipient defaults to the configured one when email.To is empty. The
+// request ID of ctx, if any, is sent in the X-Request-Id header.
+func (s *EmailService) SendEmail(ctx context.Context, email *domain.RenderedEmail) (string, error) {
+	_, span := tracer.Start(ctx, "email send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.ServerAddress(s.config.SMTPHost),
+		semconv.ServerPort(s.config.SMTPPort),
+		attribute.Bool("stori.email.fake", s.config.Fake),
+	))
+	defer span.End()
+
+	metrics.EmailSendAttempts.Inc()
+	messageID, to, err := s.send(email, domain.RequestIDFromContext(ctx))
+	if err != nil {
+		metrics.EmailSendFailures.Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "email send failed", "to", to, "error", err)
+		return "", err
+	}
+	slog.InfoContext(ctx, "email sent", "to", to, "message_id", messageID, "fake", s.config.Fake)
+	return messageID, nil
 }
 
-func buildEmailMessage(templatePath string, data interface{}) ([]byte, error) {
-	tmpl, err := template.ParseFiles(templatePath)
+// send delivers email and returns its Message-ID and recipient.
+func (s *EmailService) send(email *domain.RenderedEmail, requestID string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.config
+	to := email.To
+	if to == "" {
+		to = cfg.To
+	}
+	if cfg.From == "" || to == "" || cfg.SMTPHost == "" || cfg.SMTPPort == 0 || (cfg.Password == "" && !cfg.Fake) {
+		return "", to, errors.New("missing required configuration for email delivery")
+	}
+
+	msg, messageID, err := buildEmailMessage(cfg.From, to, email, requestID, time.Now())
 	if err != nil {
-		return nil, err
+		return "", to, err
 	}
+	if cfg.DKIM != nil {
+		if msg, err = cfg.DKIM.Sign(msg); err != nil {
+			return "", to, err
+		}
+	}
+
+	if err := s.archive(email); err != nil {
+		return "", to, err
+	}
+
+	if cfg.Fake {
+		return messageID, to, nil
+	}
+
+	if s.sendMailFn == nil {
+		return "", to, errors.New("sendMailFn is not initialized")
+	}
+
+	auth := smtp.PlainAuth("", cfg.From, cfg.Password, cfg.SMTPHost)
+	addr := cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort)
+	if err := s.sendMailFn(addr, auth, cfg.From, []string{to}, msg); err != nil {
+		return "", to, err
+	}
+	return messageID, to, nil
+}
 
+// archive writes the rendered HTML to the configured archive directory, if any.
+func (s *EmailService) archive(rendered *domain.RenderedEmail) error {
+	if s.config.ArchiveDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.config.ArchiveDir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("statement-%s.html", time.Now().UTC().Format("20060102T150405.000000000"))
+	return os.WriteFile(filepath.Join(s.config.ArchiveDir, name), []byte(rendered.HTML), 0o644)
+}
+
+func textTemplatePath(htmlPath string) string {
+	return strings.TrimSuffix(htmlPath, filepath.Ext(htmlPath)) + ".txt"
+}
+
+// buildEmailMessage assembles a multipart/alternative RFC 5322 message with
+// quoted-printable text and HTML parts, tagged with requestID when not empty.
+func buildEmailMessage(from, to string, rendered *domain.RenderedEmail, requestID string, now time.Time) ([]byte, string, error) {
 	var body bytes.Buffer
-	err = tmpl.Execute(&body, data)
+	mw := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", rendered.Text},
+		{"text/html; charset=UTF-8", rendered.HTML},
+	}
+	for _, p := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", p.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(p.content)); err != nil {
+			return nil, "", err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	messageID, err := newMessageID(from)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("UTF-8", rendered.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	if requestID != "" {
+		headers = append(headers, [2]string{"X-Request-Id", requestID})
 	}
+	for _, h := range headers {
+		msg.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), messageID, nil
+}
 
-	return body.Bytes(), nil
+func newMessageID(from string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	domainPart := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domainPart = from[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(buf), domainPart), nil
 }
@@ -0,0 +1,138 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTemplatePath = "templates/summary_template.html"
+
+func testSummary() map[string]interface{} {
+	return map[string]interface{}{
+		"TotalBalance": 39.74,
+		"MonthlyData": []map[string]interface{}{
+			{"Month": "July", "Transactions": 2, "AverageDebit": -10.3, "AverageCredit": 60.5},
+			{"Month": "August", "Transactions": 2, "AverageDebit": -20.46, "AverageCredit": 10.0},
+		},
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	service := NewEmailService(Config{})
+
+	rendered, err := service.RenderEmail(testTemplatePath, testSummary())
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultSubject, rendered.Subject)
+	assert.Contains(t, rendered.HTML, "Total balance: 39.74")
+	assert.Contains(t, rendered.HTML, "<td>August</td>")
+	assert.Contains(t, rendered.Text, "July: 2 transactions, average debit -10.30, average credit 60.50")
+}
+
+func TestReloadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	htmlPath := filepath.Join(dir, "statement.html")
+	require.NoError(t, os.WriteFile(htmlPath, []byte("<p>v1 {{.TotalBalance}}</p>"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "statement.txt"), []byte("v1 {{.TotalBalance}}"), 0o600))
+	service := NewEmailService(Config{To: "jane@example.com"})
+
+	rendered, err := service.RenderEmail(htmlPath, testSummary())
+	require.NoError(t, err)
+	assert.Equal(t, "<p>v1 39.74</p>", rendered.HTML)
+
+	// Broken templates are reported and the ones in use kept.
+	require.NoError(t, os.WriteFile(htmlPath, []byte("<p>{{.TotalBalance</p>"), 0o600))
+	assert.Error(t, service.CheckTemplates())
+	assert.Error(t, service.ReloadTemplates())
+	rendered, err = service.RenderEmail(htmlPath, testSummary())
+	require.NoError(t, err)
+	assert.Equal(t, "<p>v1 39.74</p>", rendered.HTML)
+
+	require.NoError(t, os.WriteFile(htmlPath, []byte("<p>v2 {{.TotalBalance}}</p>"), 0o600))
+	require.NoError(t, service.CheckTemplates())
+	require.NoError(t, service.ReloadTemplates())
+	service.SetRecipient("john@example.com")
+	rendered, err = service.RenderEmail(htmlPath, testSummary())
+	require.NoError(t, err)
+	assert.Equal(t, "<p>v2 39.74</p>", rendered.HTML)
+	assert.Equal(t, "john@example.com", rendered.To)
+}
+
+func TestSendEmail(t *testing.T) {
+	archiveDir := t.TempDir()
+	service := NewEmailService(Config{
+		From:       "statements@stori.test",
+		To:         "customer@example.com",
+		Password:   "secret",
+		SMTPHost:   "smtp.stori.test",
+		SMTPPort:   587,
+		ArchiveDir: archiveDir,
+	})
+
+	var sentAddr string
+	var sentMsg []byte
+	service.sendMailFn = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		sentAddr = addr
+		sentMsg = msg
+		assert.Equal(t, "statements@stori.test", from)
+		assert.Equal(t, []string{"customer@example.com"}, to)
+		return nil
+	}
+
+	rendered, err := service.RenderEmail(testTemplatePath, testSummary())
+	require.NoError(t, err)
+	assert.Equal(t, "customer@example.com", rendered.To)
+
+	messageID, err := service.SendEmail(domain.WithRequestID(context.Background(), "req-1"), rendered)
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.stori.test:587", sentAddr)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(sentMsg))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSubject, msg.Header.Get("Subject"))
+	assert.Contains(t, msg.Header.Get("Content-Type"), "multipart/alternative")
+	assert.Equal(t, messageID, msg.Header.Get("Message-ID"))
+	assert.Contains(t, messageID, "@stori.test>")
+	assert.Equal(t, "req-1", msg.Header.Get("X-Request-Id"))
+
+	files, err := filepath.Glob(filepath.Join(archiveDir, "statement-*.html"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestSendEmail_Fake(t *testing.T) {
+	service := NewEmailService(Config{
+		From:     "statements@stori.test",
+		To:       "customer@example.com",
+		SMTPHost: "smtp.stori.test",
+		SMTPPort: 587,
+		Fake:     true,
+	})
+	service.sendMailFn = func(string, smtp.Auth, string, []string, []byte) error {
+		t.Fatal("fake email service must not reach SMTP")
+		return nil
+	}
+
+	rendered, err := service.RenderEmail(testTemplatePath, testSummary())
+	require.NoError(t, err)
+
+	messageID, err := service.SendEmail(context.Background(), rendered)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, messageID)
+}
+
+func TestSendEmail_MissingConfig(t *testing.T) {
+	service := NewEmailService(Config{})
+
+	_, err := service.SendEmail(context.Background(), &domain.RenderedEmail{Subject: DefaultSubject})
+	assert.EqualError(t, err, "missing required configuration for email delivery")
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"gorm.io/gorm"
+)
+
+// Database pings the database.
+func Database(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// Redis pings the Redis server.
+func Redis(client redis.UniversalClient) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// Migrations checks that the database schema is up to date, reporting its
+// version and the migrations pending, as returned by status.
+func Migrations(status func(ctx context.Context) (*domain.MigrationStatus, error)) func(ctx context.Context) (map[string]interface{}, error) {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		migrations, err := status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]interface{}{"version": migrations.Version, "pending": migrations.Pending}
+		if len(migrations.Pending) > 0 {
+			pending := make([]string, len(migrations.Pending))
+			for i, version := range migrations.Pending {
+				pending[i] = strconv.FormatInt(version, 10)
+			}
+			return details, fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return details, nil
+	}
+}
+
+// File checks that path is a regular file that can be opened for reading.
+func File(path string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		file, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+		return nil
+	}
+}
+
+// SMTP connects to the mail server and waits for its greeting, without
+// authenticating or sending anything.
+func SMTP(host string, port int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		} else {
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+		}
+
+		text := textproto.NewConn(conn)
+		if _, _, err := text.ReadResponse(220); err != nil {
+			return err
+		}
+		text.PrintfLine("QUIT")
+		return nil
+	}
+}
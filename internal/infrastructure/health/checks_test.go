@@ -0,0 +1,89 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:health?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	assert.NoError(t, Database(db)(context.Background()))
+}
+
+func TestMigrations(t *testing.T) {
+	status := &domain.MigrationStatus{Version: 20240101000000, Pending: []int64{20240102000000, 20240103000000}}
+	check := Migrations(func(ctx context.Context) (*domain.MigrationStatus, error) { return status, nil })
+
+	details, err := check(context.Background())
+	assert.EqualError(t, err, "pending migrations: 20240102000000, 20240103000000")
+	assert.Equal(t, map[string]interface{}{"version": int64(20240101000000), "pending": status.Pending}, details)
+
+	status = &domain.MigrationStatus{Version: 20240103000000, Pending: []int64{}}
+	details, err = check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20240103000000), details["version"])
+
+	failing := Migrations(func(ctx context.Context) (*domain.MigrationStatus, error) {
+		return nil, errors.New("no such table: goose_db_version")
+	})
+	_, err = failing(context.Background())
+	assert.Error(t, err)
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	assert.NoError(t, Redis(client)(context.Background()))
+
+	server.Close()
+	assert.Error(t, Redis(client)(context.Background()))
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transactions.csv")
+	require.NoError(t, os.WriteFile(path, []byte("Id,Date,Transaction\n"), 0o644))
+
+	assert.NoError(t, File(path)(context.Background()))
+	assert.Error(t, File(filepath.Join(dir, "missing.csv"))(context.Background()))
+	assert.Error(t, File(dir)(context.Background()))
+}
+
+func TestSMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+			bufio.NewReader(conn).ReadString('\n')
+			conn.Close()
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	assert.NoError(t, SMTP("127.0.0.1", addr.Port)(context.Background()))
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	assert.Error(t, SMTP("127.0.0.1", port)(context.Background()))
+}
@@ -0,0 +1,94 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// MemoryStore keeps idempotency records in the process, for single replica
+// deployments without Redis. Like RedisStore, a request holds its key for at
+// most lockTTL while it is handled; completed responses are kept for ttl.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryRecord
+	lockTTL   time.Duration
+	ttl       time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryRecord struct {
+	record  domain.IdempotencyRecord
+	expires time.Time
+}
+
+func NewMemoryStore(lockTTL, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{records: make(map[string]memoryRecord), lockTTL: lockTTL, ttl: ttl, now: time.Now}
+}
+
+// Begin claims key for a request with fingerprint, returning the new
+// in-progress record and true, or the existing record and false if the key
+// was already used.
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string) (*domain.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	if existing, ok := s.records[key]; ok && existing.expires.After(now) {
+		stored := existing.record
+		return &stored, false, nil
+	}
+	record := domain.IdempotencyRecord{Fingerprint: fingerprint, Token: uuid.NewString()}
+	s.records[key] = memoryRecord{record: record, expires: now.Add(s.lockTTL)}
+	return &record, true, nil
+}
+
+// Complete stores the response of the request holding record's key.
+func (s *MemoryStore) Complete(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	record.Completed = true
+	s.replace(key, record, func(now time.Time) {
+		s.records[key] = memoryRecord{record: *record, expires: now.Add(s.ttl)}
+	})
+	return nil
+}
+
+// Release frees key without storing a response, so the request can be
+// retried with it.
+func (s *MemoryStore) Release(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	s.replace(key, record, func(time.Time) {
+		delete(s.records, key)
+	})
+	return nil
+}
+
+// replace runs update only if key still belongs to the request holding
+// record, which is not the case once its lock expired and another request
+// took over the key.
+func (s *MemoryStore) replace(key string, record *domain.IdempotencyRecord, update func(now time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	current, ok := s.records[key]
+	if !ok || !current.expires.After(now) || current.record.Token != record.Token {
+		return
+	}
+	update(now)
+}
+
+// sweep forgets, at most once a minute, the expired records.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, record := range s.records {
+		if !record.expires.After(now) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(time.Minute, time.Hour)
+	now := time.Date(2024, 7, 1, 6, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	record, started, err := store.Begin(ctx, "user-1:key-1", "fp-1")
+	require.NoError(t, err)
+	assert.True(t, started)
+	assert.False(t, record.Completed)
+
+	inProgress, started, err := store.Begin(ctx, "user-1:key-1", "fp-1")
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.False(t, inProgress.Completed)
+	assert.Equal(t, "fp-1", inProgress.Fingerprint)
+
+	record.Status = 202
+	record.Header = map[string]string{"Location": "/jobs/job-1"}
+	record.Body = []byte(`{"job_id":"job-1"}`)
+	require.NoError(t, store.Complete(ctx, "user-1:key-1", record))
+
+	now = now.Add(30 * time.Minute)
+	completed, started, err := store.Begin(ctx, "user-1:key-1", "fp-2")
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.True(t, completed.Completed)
+	assert.Equal(t, "fp-1", completed.Fingerprint)
+	assert.Equal(t, 202, completed.Status)
+	assert.Equal(t, "/jobs/job-1", completed.Header["Location"])
+	assert.Equal(t, `{"job_id":"job-1"}`, string(completed.Body))
+
+	// Completed responses are kept for the TTL only.
+	now = now.Add(time.Hour)
+	_, started, err = store.Begin(ctx, "user-1:key-1", "fp-2")
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	// A released key can be claimed again.
+	record, _, err = store.Begin(ctx, "user-1:key-2", "fp-1")
+	require.NoError(t, err)
+	require.NoError(t, store.Release(ctx, "user-1:key-2", record))
+	_, started, err = store.Begin(ctx, "user-1:key-2", "fp-1")
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	// Once the lock expired and another request took over the key, the
+	// first request can no longer settle it.
+	stale, _, err := store.Begin(ctx, "user-1:key-3", "fp-1")
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	current, started, err := store.Begin(ctx, "user-1:key-3", "fp-1")
+	require.NoError(t, err)
+	assert.True(t, started)
+	require.NoError(t, store.Complete(ctx, "user-1:key-3", stale))
+	require.NoError(t, store.Release(ctx, "user-1:key-3", stale))
+	existing, started, err := store.Begin(ctx, "user-1:key-3", "fp-1")
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Equal(t, current.Token, existing.Token)
+	assert.False(t, existing.Completed)
+}
@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+const keyPrefix = "stori:idempotency:"
+
+// replaceScript overwrites or deletes (with an empty value) a record only if
+// it still belongs to the request holding the token, which is not the case
+// once its lock expired and another request took over the key.
+var replaceScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current or cjson.decode(current).token ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '' then
+	redis.call('DEL', KEYS[1])
+else
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+end
+return 1
+`)
+
+// RedisStore keeps idempotency records in Redis. A request holds its key for
+// at most lockTTL while it is handled; completed responses are kept for ttl.
+type RedisStore struct {
+	client  *redis.Client
+	lockTTL time.Duration
+	ttl     time.Duration
+}
+
+func NewRedisStore(client *redis.Client, lockTTL, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, lockTTL: lockTTL, ttl: ttl}
+}
+
+// Begin claims key for a request with fingerprint, returning the new
+// in-progress record and true, or the existing record and false if the key
+// was already used.
+func (s *RedisStore) Begin(ctx context.Context, key, fingerprint string) (*domain.IdempotencyRecord, bool, error) {
+	record := &domain.IdempotencyRecord{Fingerprint: fingerprint, Token: uuid.NewString()}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for {
+		claimed, err := s.client.SetNX(ctx, keyPrefix+key, value, s.lockTTL).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if claimed {
+			return record, true, nil
+		}
+
+		existing, err := s.client.Get(ctx, keyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// Expired or released since SETNX; try to claim it again.
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var stored domain.IdempotencyRecord
+		if err := json.Unmarshal(existing, &stored); err != nil {
+			return nil, false, err
+		}
+		return &stored, false, nil
+	}
+}
+
+// Complete stores the response of the request holding record's key.
+func (s *RedisStore) Complete(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	record.Completed = true
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return replaceScript.Run(ctx, s.client, []string{keyPrefix + key}, record.Token, value, s.ttl.Milliseconds()).Err()
+}
+
+// Release frees key without storing a response, so the request can be
+// retried with it.
+func (s *RedisStore) Release(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	return replaceScript.Run(ctx, s.client, []string{keyPrefix + key}, record.Token, "", 0).Err()
+}
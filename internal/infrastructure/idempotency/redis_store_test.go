@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedisStore(client, time.Minute, time.Hour)
+	ctx := context.Background()
+
+	record, started, err := store.Begin(ctx, "user-1:key-1", "fp-1")
+	require.NoError(t, err)
+	assert.True(t, started)
+	assert.False(t, record.Completed)
+
+	inProgress, started, err := store.Begin(ctx, "user-1:key-1", "fp-1")
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.False(t, inProgress.Completed)
+	assert.Equal(t, "fp-1", inProgress.Fingerprint)
+
+	record.Status = 202
+	record.Header = map[string]string{"Location": "/jobs/job-1"}
+	record.Body = []byte(`{"job_id":"job-1"}`)
+	require.NoError(t, store.Complete(ctx, "user-1:key-1", record))
+	assert.InDelta(t, time.Hour.Seconds(), server.TTL("stori:idempotency:user-1:key-1").Seconds(), 1)
+
+	completed, started, err := store.Begin(ctx, "user-1:key-1", "fp-2")
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.True(t, completed.Completed)
+	assert.Equal(t, "fp-1", completed.Fingerprint)
+	assert.Equal(t, 202, completed.Status)
+	assert.Equal(t, "/jobs/job-1", completed.Header["Location"])
+	assert.Equal(t, `{"job_id":"job-1"}`, string(completed.Body))
+
+	// A released key can be claimed again.
+	record, _, err = store.Begin(ctx, "user-1:key-2", "fp-1")
+	require.NoError(t, err)
+	require.NoError(t, store.Release(ctx, "user-1:key-2", record))
+	_, started, err = store.Begin(ctx, "user-1:key-2", "fp-1")
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	// Once the lock expired and another request took over the key, the
+	// first request can no longer settle it.
+	stale, _, err := store.Begin(ctx, "user-1:key-3", "fp-1")
+	require.NoError(t, err)
+	server.FastForward(2 * time.Minute)
+	current, started, err := store.Begin(ctx, "user-1:key-3", "fp-1")
+	require.NoError(t, err)
+	assert.True(t, started)
+	require.NoError(t, store.Complete(ctx, "user-1:key-3", stale))
+	require.NoError(t, store.Release(ctx, "user-1:key-3", stale))
+	existing, started, err := store.Begin(ctx, "user-1:key-3", "fp-1")
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Equal(t, current.Token, existing.Token)
+	assert.False(t, existing.Completed)
+}
@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+)
+
+// MemoryLocker grants leases held in the process, for single replica
+// deployments without Redis. Leases are never lost, as they live as long as
+// the process holding them. Fencing tokens follow the clock, so that they
+// keep increasing across restarts.
+type MemoryLocker struct {
+	mu        sync.Mutex
+	held      map[string]bool
+	lastToken int64
+	now       func() time.Time
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{held: make(map[string]bool), now: time.Now}
+}
+
+// Acquire takes the lease on key, or returns domain.ErrRunInProgress if it
+// is held already.
+func (l *MemoryLocker) Acquire(ctx context.Context, key string) (usecase.Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[key] {
+		return nil, domain.ErrRunInProgress
+	}
+	l.held[key] = true
+	l.lastToken = max(l.lastToken+1, l.now().UnixNano())
+	return &memoryLease{locker: l, key: key, token: l.lastToken}, nil
+}
+
+func (l *MemoryLocker) Held(ctx context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held[key], nil
+}
+
+type memoryLease struct {
+	locker *MemoryLocker
+	key    string
+	token  int64
+	once   sync.Once
+}
+
+func (l *memoryLease) Token() int64 {
+	return l.token
+}
+
+// Lost returns a channel that is never closed.
+func (l *memoryLease) Lost() <-chan struct{} {
+	return nil
+}
+
+func (l *memoryLease) Release(ctx context.Context) error {
+	l.once.Do(func() {
+		l.locker.mu.Lock()
+		defer l.locker.mu.Unlock()
+		delete(l.locker.held, l.key)
+	})
+	return nil
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLocker_AcquireAndRelease(t *testing.T) {
+	locker := NewMemoryLocker()
+	ctx := context.Background()
+
+	lease, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+
+	held, err := locker.Held(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	_, err = locker.Acquire(ctx, "acc-1:hash")
+	assert.ErrorIs(t, err, domain.ErrRunInProgress)
+
+	other, err := locker.Acquire(ctx, "acc-2:hash")
+	require.NoError(t, err)
+	require.NoError(t, other.Release(ctx))
+
+	require.NoError(t, lease.Release(ctx))
+	require.NoError(t, lease.Release(ctx))
+	held, err = locker.Held(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	select {
+	case <-lease.Lost():
+		t.Fatal("a memory lease is never lost")
+	default:
+	}
+}
+
+func TestMemoryLocker_TokensIncrease(t *testing.T) {
+	locker := NewMemoryLocker()
+	stopped := time.Date(2024, 7, 1, 6, 0, 0, 0, time.UTC)
+	locker.now = func() time.Time { return stopped }
+	ctx := context.Background()
+
+	first, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	require.NoError(t, first.Release(ctx))
+	second, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.Greater(t, second.Token(), first.Token(), "tokens increase with a stopped clock")
+
+	// A restarted process grants tokens above those granted before.
+	restarted := NewMemoryLocker()
+	third, err := restarted.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.Greater(t, third.Token(), second.Token())
+}
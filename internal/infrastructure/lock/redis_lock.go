@@ -0,0 +1,158 @@
+package lock
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+)
+
+const (
+	keyPrefix = "stori:lock:process:"
+	// fenceKey holds the last fencing token granted, on any key.
+	fenceKey = "stori:lock:fence"
+)
+
+// renewScript extends the lease only if it is still held by the caller.
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// fenceScript grants the next fencing token: one more than the last, and no
+// less than the clock in microseconds, so that tokens keep increasing if
+// the counter is lost.
+var fenceScript = redis.NewScript(`
+local token = math.max((tonumber(redis.call('GET', KEYS[1])) or 0) + 1, tonumber(ARGV[1]))
+redis.call('SET', KEYS[1], string.format('%.0f', token))
+return token
+`)
+
+// releaseScript deletes the lease only if it is still held by the caller.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker grants leases stored as Redis keys with a TTL. A lease is
+// renewed in the background at a third of its TTL until released. Fencing
+// tokens come from a single counter shared by all keys, so that they grow
+// with every lease on a key without a counter left behind per key.
+type RedisLocker struct {
+	client *redis.Client
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewRedisLocker(client *redis.Client, ttl time.Duration) *RedisLocker {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &RedisLocker{client: client, ttl: ttl, now: time.Now}
+}
+
+// Acquire takes the lease on key, or returns domain.ErrRunInProgress if it
+// is held by someone else.
+func (l *RedisLocker) Acquire(ctx context.Context, key string) (usecase.Lease, error) {
+	value := uuid.NewString()
+	ok, err := l.client.SetNX(ctx, keyPrefix+key, value, l.ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrRunInProgress
+	}
+
+	token, err := fenceScript.Run(ctx, l.client, []string{fenceKey}, l.now().UnixMicro()).Int64()
+	if err != nil {
+		releaseScript.Run(context.Background(), l.client, []string{keyPrefix + key}, value)
+		return nil, err
+	}
+
+	renewCtx, stopRenewing := context.WithCancel(context.Background())
+	lease := &redisLease{
+		locker:       l,
+		key:          keyPrefix + key,
+		value:        value,
+		token:        token,
+		lost:         make(chan struct{}),
+		stopRenewing: stopRenewing,
+	}
+	lease.wg.Add(1)
+	go lease.renew(renewCtx)
+	return lease, nil
+}
+
+func (l *RedisLocker) Held(ctx context.Context, key string) (bool, error) {
+	n, err := l.client.Exists(ctx, keyPrefix+key).Result()
+	return n > 0, err
+}
+
+type redisLease struct {
+	locker       *RedisLocker
+	key          string
+	value        string
+	token        int64
+	lost         chan struct{}
+	stopRenewing context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+func (l *redisLease) Token() int64 {
+	return l.token
+}
+
+func (l *redisLease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *redisLease) Release(ctx context.Context) error {
+	l.stopRenewing()
+	l.wg.Wait()
+	return releaseScript.Run(ctx, l.locker.client, []string{l.key}, l.value).Err()
+}
+
+// renew extends the lease until ctx is cancelled. When the lease turns out
+// to be gone, or cannot be renewed before it would expire, Lost is closed.
+func (l *redisLease) renew(ctx context.Context) {
+	defer l.wg.Done()
+	ttl := l.locker.ttl
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewed, err := renewScript.Run(ctx, l.locker.client, []string{l.key}, l.value, ttl.Milliseconds()).Int()
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err == nil && renewed == 1:
+			renewedAt = time.Now()
+			continue
+		case err == nil:
+			slog.WarnContext(ctx, "lock lease was taken over", "key", l.key)
+		case time.Since(renewedAt) < ttl:
+			slog.WarnContext(ctx, "lock lease renewal failed", "key", l.key, "error", err)
+			continue
+		default:
+			slog.ErrorContext(ctx, "lock lease expired, renewal failing", "key", l.key, "error", err)
+		}
+		close(l.lost)
+		return
+	}
+}
@@ -0,0 +1,122 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocker(t *testing.T, ttl time.Duration) (*RedisLocker, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLocker(client, ttl), server
+}
+
+func TestRedisLocker_AcquireAndRelease(t *testing.T) {
+	locker, _ := newTestLocker(t, time.Second)
+	ctx := context.Background()
+
+	lease, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.Positive(t, lease.Token())
+
+	held, err := locker.Held(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	_, err = locker.Acquire(ctx, "acc-1:hash")
+	assert.ErrorIs(t, err, domain.ErrRunInProgress)
+
+	other, err := locker.Acquire(ctx, "acc-2:hash")
+	require.NoError(t, err)
+	assert.Greater(t, other.Token(), lease.Token())
+	require.NoError(t, other.Release(ctx))
+
+	require.NoError(t, lease.Release(ctx))
+	held, err = locker.Held(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	// Every new lease on the key gets a higher fencing token.
+	next, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	assert.Greater(t, next.Token(), other.Token())
+	require.NoError(t, next.Release(ctx))
+}
+
+func TestRedisLocker_FencingTokens(t *testing.T) {
+	locker, server := newTestLocker(t, time.Second)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	locker.now = func() time.Time { return now }
+
+	var tokens []int64
+	for _, key := range []string{"acc-1:a", "acc-1:b", "acc-2:a"} {
+		lease, err := locker.Acquire(ctx, key)
+		require.NoError(t, err)
+		tokens = append(tokens, lease.Token())
+		require.NoError(t, lease.Release(ctx))
+	}
+	assert.Equal(t, []int64{now.UnixMicro(), now.UnixMicro() + 1, now.UnixMicro() + 2}, tokens)
+
+	// One counter serves every key; none is left behind per lease.
+	assert.Equal(t, []string{fenceKey}, server.Keys())
+
+	// Tokens keep growing when the counter is lost.
+	server.Del(fenceKey)
+	now = now.Add(time.Second)
+	lease, err := locker.Acquire(ctx, "acc-1:a")
+	require.NoError(t, err)
+	assert.Equal(t, now.UnixMicro(), lease.Token())
+	require.NoError(t, lease.Release(ctx))
+}
+
+func TestRedisLocker_Renews(t *testing.T) {
+	locker, server := newTestLocker(t, 90*time.Millisecond)
+	ctx := context.Background()
+
+	lease, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+	defer lease.Release(ctx)
+
+	// Let the lease's own TTL run down; renewals must restore it.
+	server.FastForward(60 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return server.TTL(keyPrefix+"acc-1:hash") > 60*time.Millisecond
+	}, time.Second, 5*time.Millisecond)
+
+	select {
+	case <-lease.Lost():
+		t.Fatal("renewed lease reported lost")
+	default:
+	}
+}
+
+func TestRedisLocker_ReleaseDoesNotDeleteOthersLease(t *testing.T) {
+	locker, server := newTestLocker(t, 90*time.Millisecond)
+	ctx := context.Background()
+
+	lease, err := locker.Acquire(ctx, "acc-1:hash")
+	require.NoError(t, err)
+
+	// The lease expired and another replica took it over.
+	require.NoError(t, server.Set(keyPrefix+"acc-1:hash", "someone-else"))
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lease was not reported lost")
+	}
+
+	require.NoError(t, lease.Release(ctx))
+	value, err := server.Get(keyPrefix + "acc-1:hash")
+	require.NoError(t, err)
+	assert.Equal(t, "someone-else", value)
+}
@@ -0,0 +1,156 @@
+// Package logging configures the structured slog logger of the service. Every
+// record logged with a context carries the request ID and trace of that
+// context, and personal data can be redacted before it is written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats records can be written in.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces values of PII attributes when redaction is enabled.
+const Redacted = "[REDACTED]"
+
+// Config selects the level, format and redaction of the logger.
+type Config struct {
+	// Level is debug, info, warn or error.
+	Level string
+	// Format is json or text.
+	Format string
+	// RedactPII masks email addresses and monetary amounts.
+	RedactPII bool
+}
+
+// level is the level of the logger installed by Setup.
+var level = new(slog.LevelVar)
+
+// Setup installs the logger described by cfg as the slog default, writing to
+// stdout. Its level can be changed with SetLevel.
+func Setup(cfg Config) error {
+	logger, err := newLogger(os.Stdout, cfg, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// SetLevel changes the level of the logger installed by Setup.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	level.Set(l)
+	return nil
+}
+
+// New returns a logger writing records described by cfg to w.
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	return newLogger(w, cfg, new(slog.LevelVar))
+}
+
+func newLogger(w io.Writer, cfg Config, levelVar *slog.LevelVar) (*slog.Logger, error) {
+	if cfg.Level != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+		}
+		levelVar.Set(l)
+	}
+
+	opts := &slog.HandlerOptions{Level: levelVar}
+	if cfg.RedactPII {
+		opts.ReplaceAttr = redact
+	}
+
+	var handler slog.Handler
+	switch cfg.Format {
+	case FormatJSON, "":
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler adds the request ID and trace of the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := domain.RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// piiKeys are attributes whose values are always redacted. SQL statements
+// are among them as GORM interpolates the amounts they insert.
+var piiKeys = map[string]bool{
+	"sql":            true,
+	"email":          true,
+	"to":             true,
+	"from":           true,
+	"recipient":      true,
+	"amount":         true,
+	"balance":        true,
+	"total_balance":  true,
+	"average_debit":  true,
+	"average_credit": true,
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9\-]+\.)+[A-Za-z]{2,}`)
+
+// redact masks PII attributes entirely and the local part of email addresses
+// found in any other string, message and error included.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if piiKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, MaskEmails(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, MaskEmails(err.Error()))
+		}
+	}
+	return a
+}
+
+// MaskEmails replaces the local part of every email address in s, keeping the
+// domain for troubleshooting: jane@example.com becomes ***@example.com.
+func MaskEmails(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, func(address string) string {
+		return "***" + address[strings.LastIndex(address, "@"):]
+	})
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Config{Level: "verbose"})
+	assert.ErrorContains(t, err, `invalid log level "verbose"`)
+
+	_, err = New(&bytes.Buffer{}, Config{Format: "xml"})
+	assert.EqualError(t, err, `invalid log format "xml"`)
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "warn"})
+	require.NoError(t, err)
+
+	logger.Info("skipped")
+	assert.Empty(t, buf.String())
+	logger.Warn("kept")
+	assert.Equal(t, "kept", decode(t, &buf)["msg"])
+}
+
+func TestNew_ContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{})
+	require.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = domain.WithRequestID(ctx, "req-1")
+
+	logger.With("component", "test").InfoContext(ctx, "job finished", "job_id", "job-1")
+	record := decode(t, &buf)
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", record["span_id"])
+	assert.Equal(t, "test", record["component"])
+	assert.Equal(t, "job-1", record["job_id"])
+}
+
+func TestNew_RedactPII(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{RedactPII: true})
+	require.NoError(t, err)
+
+	logger.Error("delivery to jane.doe@example.com failed",
+		"to", "jane.doe@example.com",
+		"total_balance", 39.74,
+		"error", errors.New("550 mailbox jane.doe@example.com unavailable"),
+		"rows", 4,
+	)
+	record := decode(t, &buf)
+	assert.Equal(t, "delivery to ***@example.com failed", record["msg"])
+	assert.Equal(t, Redacted, record["to"])
+	assert.Equal(t, Redacted, record["total_balance"])
+	assert.Equal(t, "550 mailbox ***@example.com unavailable", record["error"])
+	assert.Equal(t, float64(4), record["rows"])
+}
+
+func TestNew_NoRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Format: FormatText})
+	require.NoError(t, err)
+
+	logger.Info("sent", slog.String("to", "jane@example.com"))
+	assert.Contains(t, buf.String(), "to=jane@example.com")
+}
+
+func TestSetLevel(t *testing.T) {
+	t.Cleanup(func() { level.Set(slog.LevelInfo) })
+
+	require.NoError(t, SetLevel("debug"))
+	assert.Equal(t, slog.LevelDebug, level.Level())
+	assert.ErrorContains(t, SetLevel("loud"), `invalid log level "loud"`)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+}
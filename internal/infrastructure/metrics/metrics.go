@@ -0,0 +1,167 @@
+// Package metrics holds the Prometheus collectors of the service. They are
+// registered on Registry, served by Handler, and updated by the components
+// they observe.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "stori"
+
+// scrapeTimeout bounds the queries made to collect a metric.
+const scrapeTimeout = 2 * time.Second
+
+// Registry holds every collector of the service, plus the Go runtime and
+// process ones.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	RateLimitRejections = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests answered 429 by the rate limiter, by route policy.",
+	}, []string{"route"})
+
+	CSVRows = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "csv_rows_total",
+		Help:      "Transaction CSV rows read, by result (parsed or rejected).",
+	}, []string{"result"})
+
+	DBInsertDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_insert_duration_seconds",
+		Help:      "Time taken to insert a batch of transactions.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	CacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Transaction cache lookups, by result (hit, miss or error).",
+	}, []string{"result"})
+
+	EmailSendAttempts = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_send_attempts_total",
+		Help:      "Statement emails the service tried to send.",
+	})
+
+	EmailSendFailures = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_send_failures_total",
+		Help:      "Statement emails that could not be sent.",
+	})
+
+	JobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Time taken to run processing jobs, by result (succeeded or failed).",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		jobQueue,
+	)
+}
+
+// Handler serves the collectors of Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveSince observes the time elapsed since start, e.g. deferred at the
+// start of the operation it measures.
+func ObserveSince(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
+
+// Job wraps a job queue handler to observe how long jobs take.
+func Job(handler func(ctx context.Context, jobID string) error) func(ctx context.Context, jobID string) error {
+	return func(ctx context.Context, jobID string) error {
+		start := time.Now()
+		err := handler(ctx, jobID)
+		result := "succeeded"
+		if err != nil {
+			result = "failed"
+		}
+		JobDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// jobQueueCollector reports the number of jobs per state on every scrape.
+type jobQueueCollector struct {
+	mu     sync.Mutex
+	count  func(ctx context.Context) (map[string]int64, error)
+	states []string
+	desc   *prometheus.Desc
+}
+
+var jobQueue = &jobQueueCollector{
+	desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "job_queue_depth"),
+		"Jobs waiting for or being run by a worker, by state.", []string{"state"}, nil),
+}
+
+// ObserveJobQueue reports the number of jobs in each of states as
+// stori_job_queue_depth, counted by count on every scrape. It replaces the
+// previous count, if any.
+func ObserveJobQueue(count func(ctx context.Context) (map[string]int64, error), states ...string) {
+	jobQueue.mu.Lock()
+	defer jobQueue.mu.Unlock()
+	jobQueue.count, jobQueue.states = count, states
+}
+
+func (c *jobQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *jobQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	count, states := c.count, c.states
+	c.mu.Unlock()
+	if count == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	counts, err := count(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "counting jobs for metrics failed", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, state := range states {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[state]), state)
+	}
+}
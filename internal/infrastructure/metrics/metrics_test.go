@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob(t *testing.T) {
+	handler := Job(func(ctx context.Context, jobID string) error {
+		if jobID == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	assert.NoError(t, handler(context.Background(), "good"))
+	assert.EqualError(t, handler(context.Background(), "bad"), "boom")
+	assert.Equal(t, 2, testutil.CollectAndCount(JobDuration), "one series per result")
+}
+
+func TestObserveJobQueue(t *testing.T) {
+	ObserveJobQueue(func(ctx context.Context) (map[string]int64, error) {
+		return map[string]int64{"queued": 3, "running": 1, "succeeded": 40}, nil
+	}, "queued", "retrying", "running")
+	t.Cleanup(func() { ObserveJobQueue(nil) })
+
+	expected := `
+# HELP stori_job_queue_depth Jobs waiting for or being run by a worker, by state.
+# TYPE stori_job_queue_depth gauge
+stori_job_queue_depth{state="queued"} 3
+stori_job_queue_depth{state="retrying"} 0
+stori_job_queue_depth{state="running"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(jobQueue, strings.NewReader(expected)))
+}
+
+func TestHandler(t *testing.T) {
+	EmailSendAttempts.Inc()
+	CacheRequests.WithLabelValues("hit").Inc()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	for _, name := range []string{"stori_email_send_attempts_total", `stori_cache_requests_total{result="hit"}`, "go_goroutines", "process_"} {
+		assert.Contains(t, w.Body.String(), name)
+	}
+}
@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log/slog"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migrator applies the goose migrations of the database schema.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// NewMigrator returns a migrator of db, in dialect, applying the migrations
+// in fsys. With Postgres, migrations are applied under a session advisory
+// lock, so replicas starting together apply them once: the others wait for
+// the lock, then find nothing left to apply.
+func NewMigrator(db *sql.DB, dialect goose.Dialect, fsys fs.FS) (*Migrator, error) {
+	var opts []goose.ProviderOption
+	if dialect == goose.DialectPostgres {
+		// Wait up to five minutes for the lock, checking every 5 seconds.
+		locker, err := lock.NewPostgresSessionLocker(lock.WithLockTimeout(5, 60))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, goose.WithSessionLocker(locker))
+	}
+	provider, err := goose.NewProvider(dialect, db, fsys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies every pending migration and returns their versions.
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	results, err := m.provider.Up(ctx)
+	versions := make([]int64, 0, len(results))
+	for _, result := range results {
+		if result.Error == nil {
+			versions = append(versions, result.Source.Version)
+			slog.InfoContext(ctx, "migration applied", "version", result.Source.Version, "duration_ms", result.Duration.Milliseconds())
+		}
+	}
+	return versions, err
+}
+
+// Down rolls back the latest migration applied and returns its version.
+func (m *Migrator) Down(ctx context.Context) (int64, error) {
+	result, err := m.provider.Down(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return 0, errors.New("no migration to roll back")
+	}
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "migration rolled back", "version", result.Source.Version)
+	return result.Source.Version, nil
+}
+
+// Reset rolls back every migration applied, newest first, and returns their
+// versions.
+func (m *Migrator) Reset(ctx context.Context) ([]int64, error) {
+	results, err := m.provider.DownTo(ctx, 0)
+	versions := make([]int64, 0, len(results))
+	for _, result := range results {
+		if result.Error == nil {
+			versions = append(versions, result.Source.Version)
+			slog.InfoContext(ctx, "migration rolled back", "version", result.Source.Version)
+		}
+	}
+	return versions, err
+}
+
+// Status returns the version of the schema and the migrations pending.
+func (m *Migrator) Status(ctx context.Context) (*domain.MigrationStatus, error) {
+	migrations, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &domain.MigrationStatus{Pending: []int64{}}
+	for _, migration := range migrations {
+		if migration.State == goose.StatePending {
+			status.Pending = append(status.Pending, migration.Source.Version)
+		}
+	}
+	if status.Version, err = m.provider.GetDBVersion(ctx); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
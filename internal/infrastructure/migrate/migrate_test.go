@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrator(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:migrate?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	migrations := fstest.MapFS{
+		"20240101000000_create_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n-- +goose Down\nDROP TABLE a;\n")},
+		"20240102000000_create_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id INTEGER);\n-- +goose Down\nDROP TABLE b;\n")},
+	}
+	migrator, err := NewMigrator(sqlDB, goose.DialectSQLite3, migrations)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), status.Version)
+	assert.Equal(t, []int64{20240101000000, 20240102000000}, status.Pending)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{20240101000000, 20240102000000}, applied)
+	assert.True(t, db.Migrator().HasTable("b"))
+
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20240102000000), status.Version)
+	assert.Empty(t, status.Pending)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "nothing left to apply")
+
+	version, err := migrator.Down(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20240102000000), version)
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{20240102000000}, status.Pending)
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	rolledBack, err := migrator.Reset(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{20240102000000, 20240101000000}, rolledBack)
+	assert.False(t, db.Migrator().HasTable("a"))
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), status.Version)
+	assert.Len(t, status.Pending, 2)
+}
@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ErrQueueFull is returned by Enqueue when no more jobs can be buffered.
+var ErrQueueFull = errors.New("job queue is full")
+
+// Handler processes one job; it is called by the queue workers.
+type Handler func(ctx context.Context, jobID string) error
+
+// Queue is a job queue with a pool of workers consuming it.
+type Queue interface {
+	Enqueue(ctx context.Context, jobID string) error
+	// Start launches workers goroutines running handler until ctx is
+	// cancelled or the queue is shut down.
+	Start(ctx context.Context, workers int, handler Handler) error
+	// Wait blocks until the workers have stopped.
+	Wait()
+	// Shutdown stops taking new jobs and waits for the running ones to
+	// finish. When ctx is done first, their contexts are cancelled and
+	// Shutdown returns ctx.Err() once they have returned.
+	Shutdown(ctx context.Context) error
+	// OnAbandon sets the handler called for the jobs the queue gives up on
+	// without a handler run recording their outcome.
+	OnAbandon(handler Handler)
+}
+
+// MemoryQueue is a process-local job queue backed by a buffered channel.
+// Jobs still buffered at shutdown are handed to the OnAbandon handler; those
+// buffered when the process crashes are lost.
+type MemoryQueue struct {
+	jobs       chan memoryJob
+	wg         sync.WaitGroup
+	stop       context.CancelFunc
+	cancelJobs context.CancelFunc
+	abandon    Handler
+}
+
+// memoryJob is a buffered job with the trace context it was enqueued in.
+type memoryJob struct {
+	id    string
+	trace propagation.MapCarrier
+}
+
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{jobs: make(chan memoryJob, size)}
+}
+
+// OnAbandon sets the handler Shutdown calls for every job still buffered,
+// which no worker will run, e.g. to record it failed.
+func (q *MemoryQueue) OnAbandon(handler Handler) {
+	q.abandon = handler
+}
+
+func (q *MemoryQueue) Enqueue(ctx context.Context, jobID string) error {
+	select {
+	case q.jobs <- memoryJob{id: jobID, trace: traceCarrier(ctx)}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Start launches workers goroutines that run handler for every enqueued job
+// until ctx is cancelled or the queue is shut down.
+func (q *MemoryQueue) Start(ctx context.Context, workers int, handler Handler) error {
+	stopCtx, jobCtx := q.contexts(ctx)
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			// No job starts once the queue is stopped, even if one is
+			// buffered.
+			for stopCtx.Err() == nil {
+				select {
+				case <-stopCtx.Done():
+					return
+				case job := <-q.jobs:
+					ctx := withTrace(jobCtx, job.trace)
+					if err := handler(ctx, job.id); err != nil {
+						slog.ErrorContext(ctx, "job failed", "job_id", job.id, "error", err)
+					}
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// Wait blocks until all workers have stopped.
+func (q *MemoryQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Shutdown stops the workers once their current job is done, cancels the
+// jobs still running when ctx is done, then abandons the buffered jobs.
+func (q *MemoryQueue) Shutdown(ctx context.Context) error {
+	if q.stop == nil {
+		return nil
+	}
+	q.stop()
+	err := drain(ctx, &q.wg, q.cancelJobs)
+	q.abandonBuffered()
+	return err
+}
+
+// abandonBuffered empties the buffer, calling the OnAbandon handler for
+// every job in it. The handler runs even if the shutdown deadline passed.
+func (q *MemoryQueue) abandonBuffered() {
+	for {
+		select {
+		case job := <-q.jobs:
+			if q.abandon == nil {
+				continue
+			}
+			ctx := withTrace(context.Background(), job.trace)
+			if err := q.abandon(ctx, job.id); err != nil {
+				slog.ErrorContext(ctx, "abandoning job failed", "job_id", job.id, "error", err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (q *MemoryQueue) contexts(ctx context.Context) (stopCtx, jobCtx context.Context) {
+	stopCtx, q.stop = context.WithCancel(ctx)
+	jobCtx, q.cancelJobs = context.WithCancel(ctx)
+	return stopCtx, jobCtx
+}
+
+// traceCarrier captures the trace context of ctx, so that the spans of a job
+// join the trace of the request that enqueued it.
+func traceCarrier(ctx context.Context) propagation.MapCarrier {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// withTrace returns ctx carrying the trace context captured in carrier.
+func withTrace(ctx context.Context, carrier propagation.MapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// drain waits for the workers in wg to stop until ctx is done, then cancels
+// the jobs they are running and waits for them to return.
+func drain(ctx context.Context, wg *sync.WaitGroup, cancelJobs context.CancelFunc) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	defer cancelJobs()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
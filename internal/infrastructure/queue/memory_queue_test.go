@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	q := NewMemoryQueue(10)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	var processed []string
+	done := make(chan struct{}, 3)
+	q.Start(ctx, 2, func(_ context.Context, jobID string) error {
+		mu.Lock()
+		processed = append(processed, jobID)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, q.Enqueue(context.Background(), id))
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("jobs were not processed")
+		}
+	}
+
+	cancel()
+	q.Wait()
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, processed)
+}
+
+func TestMemoryQueue_Full(t *testing.T) {
+	q := NewMemoryQueue(1)
+
+	assert.NoError(t, q.Enqueue(context.Background(), "a"))
+	assert.ErrorIs(t, q.Enqueue(context.Background(), "b"), ErrQueueFull)
+}
+
+func TestMemoryQueue_Shutdown(t *testing.T) {
+	t.Run("waits for running jobs", func(t *testing.T) {
+		q := NewMemoryQueue(10)
+		started := make(chan struct{})
+		var finished []string
+		require.NoError(t, q.Start(context.Background(), 1, func(ctx context.Context, jobID string) error {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			finished = append(finished, jobID)
+			return ctx.Err()
+		}))
+		require.NoError(t, q.Enqueue(context.Background(), "a"))
+		<-started
+
+		assert.NoError(t, q.Shutdown(context.Background()))
+		assert.Equal(t, []string{"a"}, finished)
+		assert.NoError(t, q.Enqueue(context.Background(), "b"))
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, []string{"a"}, finished, "no job runs after shutdown")
+	})
+
+	t.Run("abandons buffered jobs", func(t *testing.T) {
+		q := NewMemoryQueue(10)
+		var abandoned []string
+		q.OnAbandon(func(ctx context.Context, jobID string) error {
+			abandoned = append(abandoned, jobID)
+			return nil
+		})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		require.NoError(t, q.Start(context.Background(), 1, func(ctx context.Context, jobID string) error {
+			close(started)
+			<-release
+			return nil
+		}))
+		for _, id := range []string{"a", "b", "c"} {
+			require.NoError(t, q.Enqueue(context.Background(), id))
+		}
+		<-started
+
+		shutdown := make(chan error)
+		go func() { shutdown <- q.Shutdown(context.Background()) }()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		assert.NoError(t, <-shutdown)
+		assert.Equal(t, []string{"b", "c"}, abandoned, "the running job finishes, the others are abandoned")
+	})
+
+	t.Run("cancels jobs after the deadline", func(t *testing.T) {
+		q := NewMemoryQueue(10)
+		started := make(chan struct{})
+		result := make(chan error, 1)
+		require.NoError(t, q.Start(context.Background(), 1, func(ctx context.Context, jobID string) error {
+			close(started)
+			<-ctx.Done()
+			result <- ctx.Err()
+			return ctx.Err()
+		}))
+		require.NoError(t, q.Enqueue(context.Background(), "a"))
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+		assert.ErrorIs(t, <-result, context.Canceled)
+	})
+}
+
+func TestMemoryQueue_PropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+
+	q := NewMemoryQueue(1)
+	received := make(chan trace.SpanContext, 1)
+	require.NoError(t, q.Start(context.Background(), 1, func(ctx context.Context, jobID string) error {
+		received <- trace.SpanContextFromContext(ctx)
+		return nil
+	}))
+	defer q.Shutdown(context.Background())
+	require.NoError(t, q.Enqueue(ctx, "a"))
+
+	select {
+	case spanContext := <-received:
+		assert.Equal(t, traceID, spanContext.TraceID())
+		assert.Equal(t, spanID, spanContext.SpanID())
+		assert.True(t, spanContext.IsRemote())
+	case <-time.After(time.Second):
+		t.Fatal("job was not processed")
+	}
+}
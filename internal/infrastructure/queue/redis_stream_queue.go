@@ -0,0 +1,436 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// errWorkerStuck is recorded for jobs whose worker stopped heartbeating
+// until they exhausted their attempts.
+var errWorkerStuck = errors.New("the worker running the job stopped responding")
+
+// promoteRetriesScript moves the retries whose backoff has elapsed from the
+// delayed sorted set back onto the stream, atomically.
+var promoteRetriesScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(due) do
+	redis.call('ZREM', KEYS[1], member)
+	local sep = string.find(member, '|', 1, true)
+	redis.call('XADD', KEYS[2], '*', 'job_id', string.sub(member, 1, sep - 1), 'attempt', string.sub(member, sep + 1))
+end
+return #due
+`)
+
+// RedisStreamConfig configures a RedisStreamQueue. Zero values fall back to
+// the defaults applied by NewRedisStreamQueue.
+type RedisStreamConfig struct {
+	Stream           string
+	Group            string
+	Consumer         string
+	DeadLetterStream string
+	// VisibilityTimeout is how long a delivered job may go without a
+	// heartbeat from its worker before another consumer reclaims it.
+	VisibilityTimeout time.Duration
+	// MaxAttempts bounds deliveries of a failing job before it is moved to
+	// the dead-letter stream.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles on every
+	// further attempt up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// MaxLen approximately caps the length of the job stream.
+	MaxLen int64
+	// PollInterval bounds how long workers block waiting for new jobs and how
+	// often due retries are promoted.
+	PollInterval time.Duration
+}
+
+// RedisStreamQueue is a durable job queue on a Redis stream consumed through
+// a consumer group. Jobs are acknowledged once handled; failed jobs are
+// retried with exponential backoff through a delayed sorted set and end up on
+// a dead-letter stream after MaxAttempts; jobs left pending by a crashed
+// worker are reclaimed after VisibilityTimeout, each reclaim counting as a
+// failed attempt.
+type RedisStreamQueue struct {
+	client  *redis.Client
+	config  RedisStreamConfig
+	abandon Handler
+
+	claimed    chan redis.XMessage
+	wg         sync.WaitGroup
+	stop       context.CancelFunc
+	cancelJobs context.CancelFunc
+}
+
+func NewRedisStreamQueue(client *redis.Client, config RedisStreamConfig) *RedisStreamQueue {
+	if config.Stream == "" {
+		config.Stream = "stori:jobs"
+	}
+	if config.Group == "" {
+		config.Group = "stori-workers"
+	}
+	if config.Consumer == "" {
+		config.Consumer = "consumer"
+	}
+	if config.DeadLetterStream == "" {
+		config.DeadLetterStream = config.Stream + ":dead"
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = 30 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if config.MaxRetryBackoff < config.RetryBackoff {
+		config.MaxRetryBackoff = config.RetryBackoff * 60
+	}
+	if config.MaxLen <= 0 {
+		config.MaxLen = 10000
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 2 * time.Second
+	}
+	return &RedisStreamQueue{
+		client:  client,
+		config:  config,
+		claimed: make(chan redis.XMessage),
+	}
+}
+
+// OnAbandon sets the handler called for every job dead-lettered on reclaim,
+// which no handler run will record, e.g. to record it failed.
+func (q *RedisStreamQueue) OnAbandon(handler Handler) {
+	q.abandon = handler
+}
+
+func (q *RedisStreamQueue) delayedKey() string {
+	return q.config.Stream + ":delayed"
+}
+
+// Enqueue adds jobID to the stream along with the trace context of ctx, so
+// that its spans join the trace of the request that enqueued it. Retries
+// start traces of their own.
+func (q *RedisStreamQueue) Enqueue(ctx context.Context, jobID string) error {
+	values := map[string]interface{}{"job_id": jobID, "attempt": 1}
+	for key, value := range traceCarrier(ctx) {
+		values[key] = value
+	}
+	return q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.config.Stream,
+		MaxLen: q.config.MaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+// Start creates the consumer group if needed and launches workers goroutines
+// running handler, plus the reclaim and retry loops, until ctx is cancelled
+// or the queue is shut down.
+func (q *RedisStreamQueue) Start(ctx context.Context, workers int, handler Handler) error {
+	err := q.client.XGroupCreateMkStream(ctx, q.config.Stream, q.config.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("creating consumer group: %w", err)
+	}
+
+	stopCtx, jobCtx := q.contexts(ctx)
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.work(stopCtx, jobCtx, handler)
+		}()
+	}
+
+	q.wg.Add(2)
+	go func() {
+		defer q.wg.Done()
+		q.every(stopCtx, q.config.VisibilityTimeout/2, q.reclaim)
+	}()
+	go func() {
+		defer q.wg.Done()
+		q.every(stopCtx, q.config.PollInterval, q.promoteRetries)
+	}()
+	return nil
+}
+
+// Wait blocks until the workers and background loops have stopped.
+func (q *RedisStreamQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Shutdown stops reading the stream and lets the workers finish their
+// current job. Jobs cancelled when ctx is done are retried like failed ones;
+// jobs read but not started stay pending until another consumer reclaims
+// them.
+func (q *RedisStreamQueue) Shutdown(ctx context.Context) error {
+	if q.stop == nil {
+		return nil
+	}
+	q.stop()
+	return drain(ctx, &q.wg, q.cancelJobs)
+}
+
+func (q *RedisStreamQueue) contexts(ctx context.Context) (stopCtx, jobCtx context.Context) {
+	stopCtx, q.stop = context.WithCancel(ctx)
+	jobCtx, q.cancelJobs = context.WithCancel(ctx)
+	return stopCtx, jobCtx
+}
+
+// work reads and handles jobs until ctx is done, running them with jobCtx so
+// that a stopping worker finishes its current job.
+func (q *RedisStreamQueue) work(ctx, jobCtx context.Context, handler Handler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q.claimed:
+			q.handle(jobCtx, msg, handler)
+			continue
+		default:
+		}
+
+		streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    q.config.Group,
+			Consumer: q.config.Consumer,
+			Streams:  []string{q.config.Stream, ">"},
+			Count:    1,
+			Block:    q.config.PollInterval,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			slog.ErrorContext(ctx, "reading job stream failed", "stream", q.config.Stream, "error", err)
+			q.sleep(ctx, q.config.PollInterval)
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				q.handle(jobCtx, msg, handler)
+			}
+		}
+	}
+}
+
+// handle runs handler for msg while keeping it claimed, then acknowledges it,
+// schedules a retry or dead-letters it.
+func (q *RedisStreamQueue) handle(ctx context.Context, msg redis.XMessage, handler Handler) {
+	jobID, attempt := messageJob(msg)
+
+	carrier := propagation.MapCarrier{}
+	for key, value := range msg.Values {
+		if value, ok := value.(string); ok && key != "job_id" && key != "attempt" {
+			carrier[key] = value
+		}
+	}
+	ctx = withTrace(ctx, carrier)
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	go q.heartbeat(heartbeatCtx, msg.ID)
+	err := handler(ctx, jobID)
+	stopHeartbeat()
+
+	// Record the outcome even if the worker is shutting down.
+	bg := context.Background()
+	if err == nil {
+		if ackErr := q.client.XAck(bg, q.config.Stream, q.config.Group, msg.ID).Err(); ackErr != nil {
+			slog.ErrorContext(ctx, "acknowledging job failed", "job_id", jobID, "error", ackErr)
+		}
+		return
+	}
+
+	_, txErr := q.client.TxPipelined(bg, func(pipe redis.Pipeliner) error {
+		if attempt >= q.config.MaxAttempts {
+			slog.ErrorContext(ctx, "job failed, dead-lettering", "job_id", jobID, "attempt", attempt, "error", err)
+			pipe.XAdd(bg, q.deadLetter(jobID, attempt, err))
+		} else {
+			retryAt := time.Now().Add(q.backoff(attempt))
+			slog.WarnContext(ctx, "job failed, retrying", "job_id", jobID, "attempt", attempt, "retry_at", retryAt.Format(time.RFC3339), "error", err)
+			pipe.ZAdd(bg, q.delayedKey(), &redis.Z{
+				Score:  float64(retryAt.UnixMilli()),
+				Member: fmt.Sprintf("%s|%d", jobID, attempt+1),
+			})
+		}
+		pipe.XAck(bg, q.config.Stream, q.config.Group, msg.ID)
+		return nil
+	})
+	if txErr != nil {
+		slog.ErrorContext(ctx, "rescheduling job failed", "job_id", jobID, "error", txErr)
+	}
+}
+
+// messageJob returns the job ID and attempt number carried by msg.
+func messageJob(msg redis.XMessage) (string, int) {
+	jobID, _ := msg.Values["job_id"].(string)
+	attempt, _ := strconv.Atoi(fmt.Sprint(msg.Values["attempt"]))
+	return jobID, max(attempt, 1)
+}
+
+// deadLetter returns the entry recording on the dead-letter stream that
+// jobID failed its last attempt with err.
+func (q *RedisStreamQueue) deadLetter(jobID string, attempt int, err error) *redis.XAddArgs {
+	return &redis.XAddArgs{
+		Stream: q.config.DeadLetterStream,
+		Values: map[string]interface{}{
+			"job_id":    jobID,
+			"attempt":   attempt,
+			"error":     err.Error(),
+			"failed_at": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// heartbeat re-claims msg for this consumer periodically so that a job that
+// is still running is not reclaimed by another consumer. JUSTID claims leave
+// the delivery count of msg as it is.
+func (q *RedisStreamQueue) heartbeat(ctx context.Context, id string) {
+	ticker := time.NewTicker(q.config.VisibilityTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+				Stream:   q.config.Stream,
+				Group:    q.config.Group,
+				Consumer: q.config.Consumer,
+				Messages: []string{id},
+			}).Err()
+			if err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "extending job visibility failed", "message_id", id, "error", err)
+			}
+		}
+	}
+}
+
+// reclaim takes over jobs that have been pending longer than the visibility
+// timeout, i.e. whose worker died, and hands them to this consumer's workers.
+// Every delivery but the first was a reclaim, so a job delivered n times is
+// on attempt attempt+n-1; once that exceeds MaxAttempts, i.e. the job keeps
+// killing or hanging its workers, it is dead-lettered instead.
+func (q *RedisStreamQueue) reclaim(ctx context.Context) error {
+	// XAUTOCLAIM would do this in one call, but go-redis v8 cannot parse its
+	// Redis 7 reply, so idle entries are found with XPENDING and XCLAIMed.
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: q.config.Stream,
+		Group:  q.config.Group,
+		Start:  "-",
+		End:    "+",
+		Count:  100,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	var stuck []string
+	deliveries := make(map[string]int)
+	for _, entry := range pending {
+		if entry.Idle >= q.config.VisibilityTimeout {
+			stuck = append(stuck, entry.ID)
+			deliveries[entry.ID] = int(entry.RetryCount)
+		}
+	}
+	if len(stuck) == 0 {
+		return nil
+	}
+
+	messages, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   q.config.Stream,
+		Group:    q.config.Group,
+		Consumer: q.config.Consumer,
+		MinIdle:  q.config.VisibilityTimeout,
+		Messages: stuck,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		jobID, attempt := messageJob(msg)
+		attempt += deliveries[msg.ID]
+		if attempt > q.config.MaxAttempts {
+			q.abandonStuck(ctx, msg, jobID, attempt-1)
+			continue
+		}
+		msg.Values["attempt"] = attempt
+		slog.WarnContext(ctx, "reclaimed stuck job", "job_id", jobID, "message_id", msg.ID, "attempt", attempt)
+		select {
+		case q.claimed <- msg:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// abandonStuck dead-letters msg, whose last attempt left it stuck, and
+// hands its job to the OnAbandon handler.
+func (q *RedisStreamQueue) abandonStuck(ctx context.Context, msg redis.XMessage, jobID string, attempt int) {
+	slog.ErrorContext(ctx, "stuck job exhausted its attempts, dead-lettering", "job_id", jobID, "message_id", msg.ID, "attempt", attempt)
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, q.deadLetter(jobID, attempt, errWorkerStuck))
+		pipe.XAck(ctx, q.config.Stream, q.config.Group, msg.ID)
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "dead-lettering stuck job failed", "job_id", jobID, "error", err)
+		return
+	}
+	if q.abandon != nil {
+		if err := q.abandon(ctx, jobID); err != nil {
+			slog.ErrorContext(ctx, "abandoning job failed", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+func (q *RedisStreamQueue) promoteRetries(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return promoteRetriesScript.Run(ctx, q.client, []string{q.delayedKey(), q.config.Stream}, now, 100).Err()
+}
+
+func (q *RedisStreamQueue) backoff(attempt int) time.Duration {
+	delay := q.config.RetryBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= q.config.MaxRetryBackoff {
+			return q.config.MaxRetryBackoff
+		}
+	}
+	return delay
+}
+
+func (q *RedisStreamQueue) every(ctx context.Context, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "job queue maintenance failed", "error", err)
+			}
+		}
+	}
+}
+
+func (q *RedisStreamQueue) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,272 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestStreamQueue(t *testing.T, config RedisStreamConfig) (*RedisStreamQueue, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	config.Consumer = "test-consumer"
+	config.PollInterval = 20 * time.Millisecond
+	config.RetryBackoff = 10 * time.Millisecond
+	if config.VisibilityTimeout == 0 {
+		config.VisibilityTimeout = time.Second
+	}
+	return NewRedisStreamQueue(client, config), client
+}
+
+// startQueue runs handler on q until the test ends.
+func startQueue(t *testing.T, q *RedisStreamQueue, handler Handler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, q.Start(ctx, 2, handler))
+	t.Cleanup(func() {
+		cancel()
+		q.Wait()
+	})
+}
+
+func TestRedisStreamQueue_ProcessesAndAcknowledges(t *testing.T) {
+	q, client := newTestStreamQueue(t, RedisStreamConfig{})
+
+	done := make(chan string, 3)
+	startQueue(t, q, func(_ context.Context, jobID string) error {
+		done <- jobID
+		return nil
+	})
+
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Enqueue(context.Background(), id))
+	}
+	var processed []string
+	for i := 0; i < 3; i++ {
+		select {
+		case id := <-done:
+			processed = append(processed, id)
+		case <-time.After(2 * time.Second):
+			t.Fatal("jobs were not processed")
+		}
+	}
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, processed)
+
+	assert.Eventually(t, func() bool {
+		pending, err := client.XPending(context.Background(), "stori:jobs", "stori-workers").Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRedisStreamQueue_RetriesWithBackoff(t *testing.T) {
+	q, _ := newTestStreamQueue(t, RedisStreamConfig{MaxAttempts: 3})
+
+	var mu sync.Mutex
+	var calls []time.Time
+	done := make(chan struct{})
+	startQueue(t, q, func(_ context.Context, jobID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, time.Now())
+		if len(calls) < 2 {
+			return errors.New("smtp unavailable")
+		}
+		close(done)
+		return nil
+	})
+
+	require.NoError(t, q.Enqueue(context.Background(), "job-1"))
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not retried")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, calls, 2)
+	assert.GreaterOrEqual(t, calls[1].Sub(calls[0]), 10*time.Millisecond)
+}
+
+func TestRedisStreamQueue_DeadLettersAfterMaxAttempts(t *testing.T) {
+	q, client := newTestStreamQueue(t, RedisStreamConfig{MaxAttempts: 2})
+
+	startQueue(t, q, func(_ context.Context, jobID string) error {
+		return errors.New("smtp unavailable")
+	})
+
+	require.NoError(t, q.Enqueue(context.Background(), "job-1"))
+
+	var dead []redis.XMessage
+	assert.Eventually(t, func() bool {
+		var err error
+		dead, err = client.XRange(context.Background(), "stori:jobs:dead", "-", "+").Result()
+		return err == nil && len(dead) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "job-1", dead[0].Values["job_id"])
+	assert.Equal(t, "2", dead[0].Values["attempt"])
+	assert.Equal(t, "smtp unavailable", dead[0].Values["error"])
+}
+
+func TestRedisStreamQueue_ReclaimsStuckJobs(t *testing.T) {
+	q, client := newTestStreamQueue(t, RedisStreamConfig{VisibilityTimeout: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	// A consumer that read the job and then died without acknowledging it.
+	require.NoError(t, client.XGroupCreateMkStream(ctx, "stori:jobs", "stori-workers", "0").Err())
+	require.NoError(t, q.Enqueue(ctx, "job-1"))
+	_, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "stori-workers",
+		Consumer: "crashed-consumer",
+		Streams:  []string{"stori:jobs", ">"},
+		Count:    1,
+	}).Result()
+	require.NoError(t, err)
+
+	done := make(chan string, 1)
+	startQueue(t, q, func(_ context.Context, jobID string) error {
+		done <- jobID
+		return nil
+	})
+
+	select {
+	case id := <-done:
+		assert.Equal(t, "job-1", id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stuck job was not reclaimed")
+	}
+}
+
+func TestRedisStreamQueue_ReclaimCountsAsAttempt(t *testing.T) {
+	q, client := newTestStreamQueue(t, RedisStreamConfig{VisibilityTimeout: 100 * time.Millisecond, MaxAttempts: 2})
+	ctx := context.Background()
+	abandoned := make(chan string, 1)
+	q.OnAbandon(func(_ context.Context, jobID string) error {
+		abandoned <- jobID
+		return nil
+	})
+
+	// The job hung two workers in turn: the first read it, the second
+	// reclaimed it.
+	require.NoError(t, client.XGroupCreateMkStream(ctx, "stori:jobs", "stori-workers", "0").Err())
+	require.NoError(t, q.Enqueue(ctx, "job-1"))
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "stori-workers",
+		Consumer: "crashed-consumer",
+		Streams:  []string{"stori:jobs", ">"},
+		Count:    1,
+	}).Result()
+	require.NoError(t, err)
+	require.NoError(t, client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   "stori:jobs",
+		Group:    "stori-workers",
+		Consumer: "hung-consumer",
+		Messages: []string{streams[0].Messages[0].ID},
+	}).Err())
+
+	startQueue(t, q, func(_ context.Context, jobID string) error {
+		t.Errorf("job %s ran past its attempts", jobID)
+		return nil
+	})
+
+	select {
+	case id := <-abandoned:
+		assert.Equal(t, "job-1", id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stuck job was not abandoned")
+	}
+	dead, err := client.XRange(ctx, "stori:jobs:dead", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "job-1", dead[0].Values["job_id"])
+	assert.Equal(t, "2", dead[0].Values["attempt"])
+	pending, err := client.XPending(ctx, "stori:jobs", "stori-workers").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestRedisStreamQueue_Backoff(t *testing.T) {
+	q := NewRedisStreamQueue(nil, RedisStreamConfig{RetryBackoff: time.Second, MaxRetryBackoff: 5 * time.Second})
+
+	assert.Equal(t, time.Second, q.backoff(1))
+	assert.Equal(t, 2*time.Second, q.backoff(2))
+	assert.Equal(t, 4*time.Second, q.backoff(3))
+	assert.Equal(t, 5*time.Second, q.backoff(4))
+}
+
+func TestRedisStreamQueue_ShutdownDrainsRunningJobs(t *testing.T) {
+	q, client := newTestStreamQueue(t, RedisStreamConfig{})
+
+	started := make(chan struct{})
+	require.NoError(t, q.Start(context.Background(), 2, func(ctx context.Context, jobID string) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Err()
+	}))
+	require.NoError(t, q.Enqueue(context.Background(), "job-1"))
+	<-started
+
+	require.NoError(t, q.Shutdown(context.Background()))
+	pending, err := client.XPending(context.Background(), "stori:jobs", "stori-workers").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "the drained job is acknowledged")
+}
+
+func TestRedisStreamQueue_ShutdownRetriesCancelledJobs(t *testing.T) {
+	q, client := newTestStreamQueue(t, RedisStreamConfig{MaxAttempts: 3})
+
+	started := make(chan struct{})
+	require.NoError(t, q.Start(context.Background(), 1, func(ctx context.Context, jobID string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	require.NoError(t, q.Enqueue(context.Background(), "job-1"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+
+	retries, err := client.ZRange(context.Background(), "stori:jobs:delayed", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1|2"}, retries)
+}
+
+func TestRedisStreamQueue_PropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	q, _ := newTestStreamQueue(t, RedisStreamConfig{})
+
+	received := make(chan trace.SpanContext, 1)
+	startQueue(t, q, func(ctx context.Context, jobID string) error {
+		received <- trace.SpanContextFromContext(ctx)
+		return nil
+	})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+	require.NoError(t, q.Enqueue(ctx, "job-1"))
+
+	select {
+	case spanContext := <-received:
+		assert.Equal(t, traceID, spanContext.TraceID())
+		assert.Equal(t, spanID, spanContext.SpanID())
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not processed")
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// MemoryLimiter rate limits keys with the GCRA state of RedisLimiter kept in
+// the process, for single replica deployments without Redis.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{tats: make(map[string]time.Time), now: time.Now}
+}
+
+// Allow records a request for key and reports whether it is within limit.
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	emission := limit.Period / time.Duration(limit.Rate)
+	tat := l.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(emission)
+	diff := now.Sub(newTat.Add(-emission * time.Duration(limit.Burst)))
+	if diff < 0 {
+		return domain.RateLimitResult{RetryAfter: -diff, ResetAfter: tat.Sub(now)}, nil
+	}
+
+	l.tats[key] = newTat
+	return domain.RateLimitResult{
+		Allowed:    true,
+		Remaining:  int(diff / emission),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// sweep forgets, at most once a minute, the keys whose limit is fully
+// replenished.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, tat := range l.tats {
+		if !tat.After(now) {
+			delete(l.tats, key)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	now := time.Date(2024, 7, 1, 6, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+	limit := domain.RateLimit{Rate: 1, Period: time.Hour, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "ip:1.2.3.4", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(ctx, "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, time.Hour, result.RetryAfter)
+	assert.Equal(t, 3*time.Hour, result.ResetAfter)
+
+	// Other keys have their own budget.
+	result, err = limiter.Allow(ctx, "ip:5.6.7.8", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// An hour later, one request is allowed again.
+	now = now.Add(time.Hour)
+	result, err = limiter.Allow(ctx, "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// Replenished keys are forgotten.
+	now = now.Add(3 * time.Hour)
+	_, err = limiter.Allow(ctx, "ip:9.9.9.9", limit)
+	require.NoError(t, err)
+	assert.Len(t, limiter.tats, 1)
+}
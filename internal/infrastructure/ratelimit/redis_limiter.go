@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+const keyPrefix = "stori:ratelimit:"
+
+// gcraScript implements the generic cell rate algorithm. The key stores the
+// theoretical arrival time (TAT) of the next request in seconds; a request is
+// allowed if it does not arrive earlier than TAT minus the burst tolerance.
+// Time comes from the Redis server so replicas need not have synced clocks.
+var gcraScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local emission = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission
+local diff = now - (new_tat - emission * burst)
+if diff < 0 then
+	return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call('SET', KEYS[1], tostring(new_tat), 'PX', math.ceil(reset_after * 1000))
+return {1, math.floor(diff / emission), '0', tostring(reset_after)}
+`)
+
+// RedisLimiter rate limits keys with GCRA state stored in Redis, so that the
+// limit holds across all replicas.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+// Allow records a request for key and reports whether it is within limit.
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	emission := limit.Period.Seconds() / float64(limit.Rate)
+	values, err := gcraScript.Run(ctx, l.client, []string{keyPrefix + key}, limit.Burst, emission).Slice()
+	if err != nil {
+		return domain.RateLimitResult{}, err
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	return domain.RateLimitResult{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		RetryAfter: parseSeconds(values[2]),
+		ResetAfter: parseSeconds(values[3]),
+	}, nil
+}
+
+func parseSeconds(value interface{}) time.Duration {
+	s, _ := value.(string)
+	seconds, _ := strconv.ParseFloat(s, 64)
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLimiter(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	limiter := NewRedisLimiter(client)
+	ctx := context.Background()
+	limit := domain.RateLimit{Rate: 1, Period: time.Hour, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "ip:1.2.3.4", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(ctx, "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.InDelta(t, time.Hour.Seconds(), result.RetryAfter.Seconds(), 5)
+	assert.InDelta(t, 3*time.Hour.Seconds(), result.ResetAfter.Seconds(), 5)
+
+	// Other keys have their own budget.
+	result, err = limiter.Allow(ctx, "ip:5.6.7.8", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestRedisLimiter_Replenishes(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	limiter := NewRedisLimiter(client)
+	ctx := context.Background()
+	limit := domain.RateLimit{Rate: 10, Period: time.Second, Burst: 1}
+
+	result, err := limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	time.Sleep(result.RetryAfter + 10*time.Millisecond)
+	result, err = limiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
@@ -3,11 +3,14 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
 )
 
 type CacheTransactionRepository struct {
@@ -23,23 +26,36 @@ func NewCacheTransactionRepository(client *redis.Client, cacheDurationSec int) *
 	}
 }
 
+// SetTTL changes how long transactions stored from now on are cached.
+func (r *CacheTransactionRepository) SetTTL(cacheDurationSec int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cacheDuration = time.Duration(cacheDurationSec) * time.Second
+}
+
 func (r *CacheTransactionRepository) Get(ctx context.Context, key string) ([]domain.Transaction, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	result, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		metrics.CacheRequests.WithLabelValues("miss").Inc()
+		slog.DebugContext(ctx, "cache miss", "key", key)
 		return nil, nil
 	} else if err != nil {
+		metrics.CacheRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
 
 	var transactions []domain.Transaction
 	err = json.Unmarshal([]byte(result), &transactions)
 	if err != nil {
-		return nil, err
+		metrics.CacheRequests.WithLabelValues("error").Inc()
+		return nil, fmt.Errorf("decoding cached transactions: %w", err)
 	}
 
+	metrics.CacheRequests.WithLabelValues("hit").Inc()
+	slog.DebugContext(ctx, "cache hit", "key", key, "rows", len(transactions))
 	return transactions, nil
 }
 
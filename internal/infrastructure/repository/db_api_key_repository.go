@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"gorm.io/gorm"
+)
+
+type DBAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewDBAPIKeyRepository(db *gorm.DB) *DBAPIKeyRepository {
+	return &DBAPIKeyRepository{db: db}
+}
+
+func (r *DBAPIKeyRepository) CreateKey(ctx context.Context, key *domain.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *DBAPIKeyRepository) UpdateKey(ctx context.Context, key *domain.APIKey) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+func (r *DBAPIKeyRepository) GetKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *DBAPIKeyRepository) GetKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	return r.first(ctx, "hash = ?", hash)
+}
+
+// ListKeys returns every key, newest first.
+func (r *DBAPIKeyRepository) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *DBAPIKeyRepository) first(ctx context.Context, query string, args ...interface{}) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.WithContext(ctx).First(&key, append([]interface{}{query}, args...)...).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDBAPIKeyRepository(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:api_keys?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.APIKey{}))
+
+	repo := NewDBAPIKeyRepository(db)
+	ctx := context.Background()
+
+	key := &domain.APIKey{ID: "key-1", Name: "reports", Prefix: "stori_0123abcd", Hash: "hash-1", Scopes: []string{domain.ScopeRead}}
+	require.NoError(t, repo.CreateKey(ctx, key))
+	require.NoError(t, repo.CreateKey(ctx, &domain.APIKey{ID: "key-2", Name: "ingest", Hash: "hash-2", Scopes: []string{domain.ScopeIngest}}))
+	assert.Error(t, repo.CreateKey(ctx, &domain.APIKey{ID: "key-3", Hash: "hash-1"}))
+
+	stored, err := repo.GetKeyByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", stored.ID)
+	assert.Equal(t, []string{domain.ScopeRead}, stored.Scopes)
+
+	_, err = repo.GetKeyByHash(ctx, "hash-9")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	revokedAt := time.Now()
+	stored.RevokedAt = &revokedAt
+	require.NoError(t, repo.UpdateKey(ctx, stored))
+	stored, err = repo.GetKey(ctx, "key-1")
+	require.NoError(t, err)
+	assert.NotNil(t, stored.RevokedAt)
+
+	_, err = repo.GetKey(ctx, "key-9")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	keys, err := repo.ListKeys(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
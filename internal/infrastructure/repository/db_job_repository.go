@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"gorm.io/gorm"
+)
+
+const defaultJobListLimit = 50
+
+type DBJobRepository struct {
+	db *gorm.DB
+}
+
+func NewDBJobRepository(db *gorm.DB) *DBJobRepository {
+	return &DBJobRepository{db: db}
+}
+
+func (r *DBJobRepository) CreateJob(ctx context.Context, job *domain.Job) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *DBJobRepository) UpdateJob(ctx context.Context, job *domain.Job) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+// CountByState returns the number of jobs in each state.
+func (r *DBJobRepository) CountByState(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		State string
+		Count int64
+	}
+	err := r.db.WithContext(ctx).Model(&domain.Job{}).Select("state, count(*) AS count").Group("state").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *DBJobRepository) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	var job domain.Job
+	err := r.db.WithContext(ctx).First(&job, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the jobs matching filter, newest first.
+func (r *DBJobRepository) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	query := r.db.WithContext(ctx)
+	if filter.AccountID != "" {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultJobListLimit
+	}
+
+	jobs := []domain.Job{}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDBJobRepository(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:jobs?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Job{}))
+
+	repo := NewDBJobRepository(db)
+	ctx := context.Background()
+
+	for i, state := range []string{domain.JobStateQueued, domain.JobStateQueued, domain.JobStateRunning, domain.JobStateSucceeded} {
+		require.NoError(t, repo.CreateJob(ctx, &domain.Job{ID: string(rune('a' + i)), State: state, Stages: []domain.StageTiming{}}))
+	}
+
+	job, err := repo.GetJob(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobStateRunning, job.State)
+	_, err = repo.GetJob(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	counts, err := repo.CountByState(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{domain.JobStateQueued: 2, domain.JobStateRunning: 1, domain.JobStateSucceeded: 1}, counts)
+
+	jobs, err := repo.ListJobs(ctx, domain.JobFilter{State: domain.JobStateQueued})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	jobs, err = repo.ListJobs(ctx, domain.JobFilter{Limit: 3})
+	require.NoError(t, err)
+	assert.Len(t, jobs, 3)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DBScheduleRunRepository struct {
+	db *gorm.DB
+}
+
+func NewDBScheduleRunRepository(db *gorm.DB) *DBScheduleRunRepository {
+	return &DBScheduleRunRepository{db: db}
+}
+
+// CreateRun inserts run, or returns domain.ErrRunExists if its account
+// already has a run scheduled for the same time.
+func (r *DBScheduleRunRepository) CreateRun(ctx context.Context, run *domain.ScheduleRun) error {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "account_id"}, {Name: "scheduled_for"}}, DoNothing: true}).
+		Create(run)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrRunExists
+	}
+	return nil
+}
+
+func (r *DBScheduleRunRepository) UpdateRun(ctx context.Context, run *domain.ScheduleRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+func (r *DBScheduleRunRepository) LatestRun(ctx context.Context) (*domain.ScheduleRun, error) {
+	var run domain.ScheduleRun
+	err := r.db.WithContext(ctx).Order("scheduled_for DESC").First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns returns the most recent runs matching filter first, at most
+// filter.Limit of them (50 by default).
+func (r *DBScheduleRunRepository) ListRuns(ctx context.Context, filter domain.ScheduleRunFilter) ([]domain.ScheduleRun, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	runs := []domain.ScheduleRun{}
+	if filter.Accounts != nil && len(filter.Accounts) == 0 {
+		return runs, nil
+	}
+	query := r.db.WithContext(ctx)
+	if filter.Accounts != nil {
+		query = query.Where("account_id IN ?", filter.Accounts)
+	}
+	err := query.Order("scheduled_for DESC, id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
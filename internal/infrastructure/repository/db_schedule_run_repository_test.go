@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDBScheduleRunRepository(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:schedule_runs?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.ScheduleRun{}))
+
+	repo := NewDBScheduleRunRepository(db)
+	ctx := context.Background()
+
+	_, err = repo.LatestRun(ctx)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	may := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 4, 6, 0, 0, 0, time.UTC)
+	mayRun := &domain.ScheduleRun{AccountID: "acc-1", Period: "2024-04", ScheduledFor: may}
+	require.NoError(t, repo.CreateRun(ctx, mayRun))
+	require.NoError(t, repo.CreateRun(ctx, &domain.ScheduleRun{AccountID: "acc-1", Period: "2024-05", ScheduledFor: june}))
+	require.NoError(t, repo.CreateRun(ctx, &domain.ScheduleRun{AccountID: "acc-2", Period: "2024-05", ScheduledFor: june}))
+
+	assert.ErrorIs(t, repo.CreateRun(ctx, &domain.ScheduleRun{AccountID: "acc-1", Period: "2024-04", ScheduledFor: may}), domain.ErrRunExists)
+
+	mayRun.JobID = "job-1"
+	require.NoError(t, repo.UpdateRun(ctx, mayRun))
+
+	latest, err := repo.LatestRun(ctx)
+	require.NoError(t, err)
+	assert.True(t, latest.ScheduledFor.Equal(june))
+
+	runs, err := repo.ListRuns(ctx, domain.ScheduleRunFilter{})
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, "job-1", runs[2].JobID)
+
+	runs, err = repo.ListRuns(ctx, domain.ScheduleRunFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	runs, err = repo.ListRuns(ctx, domain.ScheduleRunFilter{Accounts: []string{"acc-2"}})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "acc-2", runs[0].AccountID)
+
+	runs, err = repo.ListRuns(ctx, domain.ScheduleRunFilter{Accounts: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"gorm.io/gorm"
+)
+
+const defaultStatementListLimit = 50
+
+type DBStatementRepository struct {
+	db *gorm.DB
+}
+
+func NewDBStatementRepository(db *gorm.DB) *DBStatementRepository {
+	return &DBStatementRepository{db: db}
+}
+
+func (r *DBStatementRepository) CreateStatement(ctx context.Context, statement *domain.Statement) error {
+	return r.db.WithContext(ctx).Create(statement).Error
+}
+
+func (r *DBStatementRepository) UpdateStatement(ctx context.Context, statement *domain.Statement) error {
+	return r.db.WithContext(ctx).Save(statement).Error
+}
+
+func (r *DBStatementRepository) GetStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	var statement domain.Statement
+	err := r.db.WithContext(ctx).First(&statement, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// ListStatements returns the statements matching filter, newest first. Bodies
+// are left out; fetch a single statement to get them.
+func (r *DBStatementRepository) ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	query := r.db.WithContext(ctx).Omit("html_body", "text_body")
+	if filter.AccountID != "" {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.Recipient != "" {
+		query = query.Where("recipient = ?", filter.Recipient)
+	}
+	if filter.Period != "" {
+		query = query.Where("period = ?", filter.Period)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.NotResent {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM statements AS resent WHERE resent.resent_from_id = statements.id)")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultStatementListLimit
+	}
+
+	statements := []domain.Statement{}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(filter.Offset).Find(&statements).Error
+	return statements, err
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDBStatementRepository(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:statements?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Statement{}))
+
+	repo := NewDBStatementRepository(db)
+	ctx := context.Background()
+
+	march := &domain.Statement{
+		AccountID: "acc-1",
+		Recipient: "customer@example.com",
+		Subject:   "Monthly Transaction Summary",
+		Period:    "2024-03",
+		Summary:   map[string]interface{}{"TotalBalance": 39.74},
+		HTMLBody:  "<p>march</p>",
+		TextBody:  "march",
+		BodyHash:  "hash-march",
+		Status:    domain.StatementStatusPending,
+	}
+	april := &domain.Statement{
+		AccountID: "acc-1",
+		Recipient: "customer@example.com",
+		Subject:   "Monthly Transaction Summary",
+		Period:    "2024-04",
+		Summary:   map[string]interface{}{"TotalBalance": 10.0},
+		HTMLBody:  "<p>april</p>",
+		TextBody:  "april",
+		BodyHash:  "hash-april",
+		Status:    domain.StatementStatusPending,
+	}
+	require.NoError(t, repo.CreateStatement(ctx, march))
+	require.NoError(t, repo.CreateStatement(ctx, april))
+
+	sentAt := time.Now()
+	march.Status = domain.StatementStatusSent
+	march.MessageID = "<march@example.com>"
+	march.SentAt = &sentAt
+	require.NoError(t, repo.UpdateStatement(ctx, march))
+
+	t.Run("get", func(t *testing.T) {
+		statement, err := repo.GetStatement(ctx, march.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "<p>march</p>", statement.HTMLBody)
+		assert.Equal(t, domain.StatementStatusSent, statement.Status)
+		assert.Equal(t, "<march@example.com>", statement.MessageID)
+		assert.Equal(t, 39.74, statement.Summary["TotalBalance"])
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		_, err := repo.GetStatement(ctx, 999)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("list by period", func(t *testing.T) {
+		statements, err := repo.ListStatements(ctx, domain.StatementFilter{AccountID: "acc-1", Period: "2024-03"})
+		require.NoError(t, err)
+		require.Len(t, statements, 1)
+		assert.Equal(t, march.ID, statements[0].ID)
+		assert.Empty(t, statements[0].HTMLBody)
+	})
+
+	t.Run("list newest first", func(t *testing.T) {
+		statements, err := repo.ListStatements(ctx, domain.StatementFilter{Recipient: "customer@example.com"})
+		require.NoError(t, err)
+		require.Len(t, statements, 2)
+		assert.Equal(t, april.ID, statements[0].ID)
+	})
+
+	t.Run("list by status", func(t *testing.T) {
+		statements, err := repo.ListStatements(ctx, domain.StatementFilter{Status: domain.StatementStatusPending})
+		require.NoError(t, err)
+		require.Len(t, statements, 1)
+		assert.Equal(t, april.ID, statements[0].ID)
+	})
+
+	t.Run("list not resent", func(t *testing.T) {
+		resent := &domain.Statement{AccountID: "acc-1", Period: "2024-04", Status: domain.StatementStatusFailed, ResentFromID: &april.ID}
+		require.NoError(t, repo.CreateStatement(ctx, resent))
+
+		statements, err := repo.ListStatements(ctx, domain.StatementFilter{AccountID: "acc-1", NotResent: true})
+		require.NoError(t, err)
+		require.Len(t, statements, 2)
+		assert.Equal(t, resent.ID, statements[0].ID)
+		assert.Equal(t, march.ID, statements[1].ID)
+	})
+}
@@ -5,11 +5,16 @@ import (
 	"crypto/sha256"
 	"encoding/csv"
 	"encoding/hex"
+	"errors"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
 	csvreader "github.com/jordanlanch/stori-test/internal/interface/csvreader"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DBTransactionRepository struct {
@@ -25,16 +30,56 @@ func (r *DBTransactionRepository) GetAllTransactions(ctx context.Context) ([]dom
 	return r.csvReader.ReadTransactions()
 }
 
-func (r *DBTransactionRepository) SaveTransactions(ctx context.Context, transactions []domain.Transaction) error {
+// SaveTransactions inserts transactions. With a fence, the insert only
+// happens if no write was made under a newer token for the same key;
+// otherwise domain.ErrStaleLease is returned.
+func (r *DBTransactionRepository) SaveTransactions(ctx context.Context, transactions []domain.Transaction, fence *domain.Fence) (err error) {
+	start := time.Now()
+	defer metrics.ObserveSince(metrics.DBInsertDuration, start)
+	defer func() {
+		switch {
+		case errors.Is(err, domain.ErrStaleLease):
+			slog.WarnContext(ctx, "transactions not saved, lease is stale", "rows", len(transactions), "fence_key", fence.Key, "fence_token", fence.Token)
+		case err != nil:
+			slog.ErrorContext(ctx, "saving transactions failed", "rows", len(transactions), "error", err)
+		default:
+			slog.DebugContext(ctx, "transactions saved", "rows", len(transactions), "duration_ms", time.Since(start).Milliseconds())
+		}
+	}()
+
 	// Create a slice without IDs for insertion
 	transactionsWithoutIDs := make([]domain.Transaction, len(transactions))
 	for i, t := range transactions {
 		transactionsWithoutIDs[i] = domain.Transaction{
-			Date:   t.Date,
-			Amount: t.Amount,
+			AccountID: t.AccountID,
+			Date:      t.Date,
+			Amount:    t.Amount,
 		}
 	}
-	return r.db.WithContext(ctx).Create(&transactionsWithoutIDs).Error
+	if fence == nil {
+		return r.db.WithContext(ctx).Create(&transactionsWithoutIDs).Error
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"token"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "lock_fences.token <= excluded.token"}}},
+		}).Create(fence)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrStaleLease
+		}
+		return tx.Create(&transactionsWithoutIDs).Error
+	})
+}
+
+func (r *DBTransactionRepository) GetTransactionsByAccount(ctx context.Context, accountID string) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := r.db.WithContext(ctx).Where("account_id = ?", accountID).Order("id").Find(&transactions).Error
+	return transactions, err
 }
 
 func (r *DBTransactionRepository) GetCSVHash() (string, error) {
@@ -67,7 +67,7 @@ func TestSaveTransactions(t *testing.T) {
 		{Date: "1/2", Amount: -50},
 	}
 
-	err = repo.SaveTransactions(context.Background(), transactions)
+	err = repo.SaveTransactions(context.Background(), transactions, nil)
 	assert.NoError(t, err)
 
 	var savedTransactions []domain.Transaction
@@ -80,6 +80,29 @@ func TestSaveTransactions(t *testing.T) {
 	assert.Equal(t, transactions[1].Amount, savedTransactions[1].Amount)
 }
 
+func TestSaveTransactions_Fenced(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:fenced?mode=memory&cache=shared"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&domain.Transaction{}, &domain.Fence{}))
+
+	repo := &DBTransactionRepository{db: db}
+	ctx := context.Background()
+	transactions := []domain.Transaction{{AccountID: "acc-1", Date: "1/1", Amount: 100}}
+
+	assert.NoError(t, repo.SaveTransactions(ctx, transactions, &domain.Fence{Key: "acc-1:hash", Token: 2}))
+	assert.NoError(t, repo.SaveTransactions(ctx, transactions, &domain.Fence{Key: "acc-1:hash", Token: 3}))
+	assert.ErrorIs(t, repo.SaveTransactions(ctx, transactions, &domain.Fence{Key: "acc-1:hash", Token: 2}), domain.ErrStaleLease)
+	assert.NoError(t, repo.SaveTransactions(ctx, transactions, &domain.Fence{Key: "acc-2:hash", Token: 1}))
+
+	var count int64
+	assert.NoError(t, db.Model(&domain.Transaction{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+
+	var fence domain.Fence
+	assert.NoError(t, db.First(&fence, "key = ?", "acc-1:hash").Error)
+	assert.Equal(t, int64(3), fence.Token)
+}
+
 func createTempCSVFile(content string) (string, error) {
 	file, err := os.CreateTemp("", "testcsv")
 	if err != nil {
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+)
+
+// MemoryCacheRepository caches transactions in the process, evicting the
+// least recently used entry beyond maxEntries and expiring entries after the
+// cache duration. Each replica has its own cache, lost on restart.
+type MemoryCacheRepository struct {
+	mu            sync.Mutex
+	maxEntries    int
+	cacheDuration time.Duration
+	entries       map[string]*list.Element
+	// recent orders the entries from the most to the least recently used.
+	recent *list.List
+	now    func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key          string
+	transactions []domain.Transaction
+	expiresAt    time.Time
+}
+
+func NewMemoryCacheRepository(maxEntries, cacheDurationSec int) *MemoryCacheRepository {
+	return &MemoryCacheRepository{
+		maxEntries:    maxEntries,
+		cacheDuration: time.Duration(cacheDurationSec) * time.Second,
+		entries:       make(map[string]*list.Element),
+		recent:        list.New(),
+		now:           time.Now,
+	}
+}
+
+// SetTTL changes how long transactions stored from now on are cached.
+func (r *MemoryCacheRepository) SetTTL(cacheDurationSec int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cacheDuration = time.Duration(cacheDurationSec) * time.Second
+}
+
+func (r *MemoryCacheRepository) Get(ctx context.Context, key string) ([]domain.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[key]
+	if ok && !r.now().Before(element.Value.(*memoryCacheEntry).expiresAt) {
+		r.remove(element)
+		ok = false
+	}
+	if !ok {
+		metrics.CacheRequests.WithLabelValues("miss").Inc()
+		slog.DebugContext(ctx, "cache miss", "key", key)
+		return nil, nil
+	}
+
+	r.recent.MoveToFront(element)
+	transactions := append([]domain.Transaction(nil), element.Value.(*memoryCacheEntry).transactions...)
+	metrics.CacheRequests.WithLabelValues("hit").Inc()
+	slog.DebugContext(ctx, "cache hit", "key", key, "rows", len(transactions))
+	return transactions, nil
+}
+
+func (r *MemoryCacheRepository) Set(ctx context.Context, key string, transactions []domain.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &memoryCacheEntry{
+		key:          key,
+		transactions: append([]domain.Transaction(nil), transactions...),
+		expiresAt:    r.now().Add(r.cacheDuration),
+	}
+	if element, ok := r.entries[key]; ok {
+		element.Value = entry
+		r.recent.MoveToFront(element)
+		return nil
+	}
+	r.entries[key] = r.recent.PushFront(entry)
+	for r.recent.Len() > r.maxEntries {
+		r.remove(r.recent.Back())
+	}
+	return nil
+}
+
+// Len returns the number of entries cached, including expired ones not
+// evicted yet.
+func (r *MemoryCacheRepository) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recent.Len()
+}
+
+func (r *MemoryCacheRepository) remove(element *list.Element) {
+	r.recent.Remove(element)
+	delete(r.entries, element.Value.(*memoryCacheEntry).key)
+}
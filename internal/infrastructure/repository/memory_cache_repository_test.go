@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCacheRepository(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewMemoryCacheRepository(2, 60)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cached, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Nil(t, cached, "miss")
+
+	transactions := []domain.Transaction{{AccountID: "acc-1", Date: "7/15", Amount: 60.5}}
+	require.NoError(t, cache.Set(ctx, "a", transactions))
+	transactions[0].Amount = 0
+	cached, err = cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Transaction{{AccountID: "acc-1", Date: "7/15", Amount: 60.5}}, cached, "the cache keeps its own copy")
+
+	// a is now the most recently used: storing c evicts b.
+	require.NoError(t, cache.Set(ctx, "b", transactions))
+	_, _ = cache.Get(ctx, "a")
+	require.NoError(t, cache.Set(ctx, "c", transactions))
+	assert.Equal(t, 2, cache.Len())
+	cached, _ = cache.Get(ctx, "b")
+	assert.Nil(t, cached, "least recently used entry evicted")
+	cached, _ = cache.Get(ctx, "a")
+	assert.NotNil(t, cached)
+
+	now = now.Add(time.Minute)
+	cached, _ = cache.Get(ctx, "a")
+	assert.Nil(t, cached, "expired")
+	assert.Equal(t, 1, cache.Len(), "expired entry evicted on lookup")
+
+	cache.SetTTL(3600)
+	require.NoError(t, cache.Set(ctx, "a", transactions))
+	now = now.Add(30 * time.Minute)
+	cached, _ = cache.Get(ctx, "a")
+	assert.NotNil(t, cached, "stored with the new TTL")
+}
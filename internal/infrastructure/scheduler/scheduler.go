@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+)
+
+// Scheduler periodically asks the schedule use case to submit the statement
+// runs that fell due. The first check happens on Start, which catches up runs
+// missed while the service was down.
+type Scheduler struct {
+	useCase  usecase.ScheduleUseCase
+	interval time.Duration
+	now      func() time.Time
+	wg       sync.WaitGroup
+}
+
+func NewScheduler(useCase usecase.ScheduleUseCase, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Scheduler{useCase: useCase, interval: interval, now: time.Now}
+}
+
+// Start checks for due runs now and then every interval until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the scheduler has stopped.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) check(ctx context.Context) {
+	runs, err := s.useCase.RunDue(ctx, s.now())
+	for _, run := range runs {
+		if run.Error != "" {
+			slog.ErrorContext(ctx, "scheduled statement run failed", "account_id", run.AccountID, "period", run.Period, "error", run.Error)
+			continue
+		}
+		slog.InfoContext(ctx, "scheduled statement run submitted", "account_id", run.AccountID, "period", run.Period, "job_id", run.JobID)
+	}
+	if err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "scheduler check failed", "error", err)
+	}
+}
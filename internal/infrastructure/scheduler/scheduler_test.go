@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// recordingJobs submits jobs by recording their requests.
+type recordingJobs struct {
+	mu        sync.Mutex
+	submitted []domain.ProcessRequest
+}
+
+func (j *recordingJobs) SubmitProcessing(ctx context.Context, req domain.ProcessRequest) (*domain.Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.submitted = append(j.submitted, req)
+	return &domain.Job{ID: fmt.Sprintf("job-%d", len(j.submitted)), State: domain.JobStateQueued}, nil
+}
+
+func (j *recordingJobs) Submitted() []domain.ProcessRequest {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]domain.ProcessRequest{}, j.submitted...)
+}
+
+func (j *recordingJobs) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	return nil, domain.ErrNotFound
+}
+
+func (j *recordingJobs) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	return nil, nil
+}
+
+func (j *recordingJobs) RunJob(ctx context.Context, id string) error     { return nil }
+func (j *recordingJobs) AbandonJob(ctx context.Context, id string) error { return nil }
+
+func TestScheduler(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:scheduler-%d?mode=memory&cache=shared", time.Now().UnixNano())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.ScheduleRun{}))
+	runRepo := repository.NewDBScheduleRunRepository(db)
+
+	schedule, err := cron.ParseStandard("0 6 1 * *")
+	require.NoError(t, err)
+	jobs := &recordingJobs{}
+	clock := &fakeClock{now: time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)}
+	useCase := usecase.NewScheduleUseCase(runRepo, jobs, schedule, domain.NewBusinessCalendar(nil), []string{"acc-1"}, 3, clock.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewScheduler(useCase, 5*time.Millisecond)
+	s.now = clock.Now
+	s.Start(ctx)
+	defer func() {
+		cancel()
+		s.Wait()
+	}()
+
+	// 2024-06-01 is a Saturday: the run waits for Monday.
+	clock.Set(time.Date(2024, 6, 1, 6, 1, 0, 0, time.UTC))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, jobs.Submitted())
+	_, err = runRepo.LatestRun(ctx)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	clock.Set(time.Date(2024, 6, 3, 6, 1, 0, 0, time.UTC))
+	require.Eventually(t, func() bool { return len(jobs.Submitted()) > 0 }, time.Second, 5*time.Millisecond)
+
+	// Later checks find the run recorded and do not submit it again.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []domain.ProcessRequest{{AccountID: "acc-1", Period: "2024-05"}}, jobs.Submitted())
+	runs, err := runRepo.ListRuns(ctx, domain.ScheduleRunFilter{})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "acc-1", runs[0].AccountID)
+	assert.Equal(t, "2024-05", runs[0].Period)
+	assert.True(t, runs[0].ScheduledFor.Equal(time.Date(2024, 6, 3, 6, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "job-1", runs[0].JobID)
+	assert.False(t, runs[0].CatchUp)
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var gormTracer = otel.Tracer("github.com/jordanlanch/stori-test/internal/infrastructure/tracing/gorm")
+
+// gormSpanKey stores the span of a statement in its GORM instance.
+const gormSpanKey = "tracing:span"
+
+// GormPlugin traces every statement run through GORM, with its SQL (bound
+// variables left out), as a child of the span in the statement's context.
+type GormPlugin struct{}
+
+var _ gorm.Plugin = GormPlugin{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	register := func(operation string, before, after func(name string, fn func(*gorm.DB)) error) error {
+		if err := before("tracing:before_"+operation, startGormSpan(operation)); err != nil {
+			return err
+		}
+		return after("tracing:after_"+operation, endGormSpan)
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		register("create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register),
+		register("query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register),
+		register("update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register),
+		register("delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register),
+		register("row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register),
+		register("raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type gormCallback = func(*gorm.DB)
+
+// dbSystems maps GORM dialect names to the db.system of the semantic
+// conventions.
+var dbSystems = map[string]string{"postgres": "postgresql"}
+
+func startGormSpan(operation string) gormCallback {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			return
+		}
+		_, span := gormTracer.Start(ctx, "gorm "+operation+" "+db.Statement.Table, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			semconv.DBSystemKey.String(dbSystem(db.Dialector.Name())),
+			semconv.DBOperation(operation),
+			semconv.DBSQLTable(db.Statement.Table),
+		))
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func endGormSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(semconv.DBStatement(db.Statement.SQL.String()))
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func dbSystem(dialect string) string {
+	if system, ok := dbSystems[dialect]; ok {
+		return system
+	}
+	return dialect
+}
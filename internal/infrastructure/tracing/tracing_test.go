@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// exporter records the spans of every test in the package; the global
+// provider can only be installed once.
+var exporter = tracetest.NewInMemoryExporter()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	return names
+}
+
+func TestSetup(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "zipkin"})
+	assert.EqualError(t, err, `unknown trace exporter "zipkin"`)
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestSetup_StdoutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "stori-test", Exporter: ExporterStdout, File: path, SampleRatio: 1})
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "ProcessTransactions")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	out, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"Name":"ProcessTransactions"`)
+	assert.Contains(t, string(out), `"Value":"stori-test"`)
+}
+
+func TestRedisHook(t *testing.T) {
+	exporter.Reset()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	client.AddHook(RedisHook{})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, client.Set(ctx, "key", "value", 0).Err())
+	assert.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, "counter")
+		pipe.Expire(ctx, "counter", 0)
+		return nil
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, []string{"redis set", "redis get", "redis pipeline", "parent"}, spanNames(spans))
+	for _, span := range spans[:3] {
+		assert.Equal(t, spans[3].SpanContext.SpanID(), span.Parent.SpanID())
+		assert.Equal(t, codes.Unset, span.Status.Code, "redis.Nil is not an error")
+	}
+	assert.Contains(t, spans[2].Attributes, attribute.String("db.operation", "incr expire"))
+}
+
+func TestGormPlugin(t *testing.T) {
+	exporter.Reset()
+	db, err := gorm.Open(sqlite.Open("file:tracing?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Job{}))
+	require.NoError(t, db.Use(GormPlugin{}))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, db.WithContext(ctx).Create(&domain.Job{ID: "job-1", State: domain.JobStateQueued}).Error)
+	var job domain.Job
+	assert.ErrorIs(t, db.WithContext(ctx).First(&job, "id = ?", "missing").Error, gorm.ErrRecordNotFound)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, []string{"gorm create jobs", "gorm query jobs", "parent"}, spanNames(spans))
+	assert.Contains(t, spans[0].Attributes, attribute.String("db.system", "sqlite"))
+	assert.Equal(t, codes.Unset, spans[1].Status.Code, "not found is not an error")
+	for _, attr := range spans[1].Attributes {
+		if attr.Key == "db.statement" {
+			assert.Contains(t, attr.Value.AsString(), "SELECT * FROM `jobs` WHERE id = ?")
+			assert.NotContains(t, attr.Value.AsString(), "missing")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+type AccountController struct {
+	UseCase usecase.AccountUseCase
+}
+
+// ListTransactions lists the transactions stored for the account, filtered
+// by the period (YYYY-MM) query parameter.
+func (ctrl *AccountController) ListTransactions(c *gin.Context) {
+	period, err := parsePeriodQuery(c)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	transactions, err := ctrl.UseCase.ListTransactions(c.Request.Context(), c.Param("account_id"), period)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+}
+
+// GetSummary returns the statement summary of the account's transactions,
+// filtered by the period (YYYY-MM) query parameter.
+func (ctrl *AccountController) GetSummary(c *gin.Context) {
+	period, err := parsePeriodQuery(c)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	summary, err := ctrl.UseCase.GetSummary(c.Request.Context(), c.Param("account_id"), period)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
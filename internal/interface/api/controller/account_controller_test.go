@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAccountUseCase struct {
+	mock.Mock
+}
+
+func (m *MockAccountUseCase) ListTransactions(ctx context.Context, accountID, period string) ([]domain.Transaction, error) {
+	args := m.Called(ctx, accountID, period)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAccountUseCase) GetSummary(ctx context.Context, accountID, period string) (map[string]interface{}, error) {
+	args := m.Called(ctx, accountID, period)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string]interface{}), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestAccountController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockUseCase := new(MockAccountUseCase)
+	mockUseCase.On("ListTransactions", mock.Anything, "acc-1", "2024-03").Return([]domain.Transaction{{ID: 1, AccountID: "acc-1", Date: "3/4", Amount: 60.5}}, nil)
+	mockUseCase.On("GetSummary", mock.Anything, "acc-1", "").Return(map[string]interface{}{"TotalBalance": 60.5}, nil)
+
+	controller := &AccountController{UseCase: mockUseCase}
+	router := gin.New()
+	router.GET("/accounts/:account_id/transactions", controller.ListTransactions)
+	router.GET("/accounts/:account_id/summary", controller.GetSummary)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/accounts/acc-1/transactions?period=2024-03", http.StatusOK, `{"transactions":[{"id":1,"account_id":"acc-1","date":"3/4","amount":60.5}]}`},
+		{"/accounts/acc-1/summary", http.StatusOK, `{"TotalBalance":60.5}`},
+		{"/accounts/acc-1/summary?period=March", http.StatusBadRequest, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"period must be formatted as YYYY-MM","instance":"/accounts/acc-1/summary","code":"invalid_period"}`},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		assert.JSONEq(t, tt.body, w.Body.String(), tt.path)
+	}
+}
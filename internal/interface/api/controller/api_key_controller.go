@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+type APIKeyController struct {
+	UseCase usecase.APIKeyUseCase
+}
+
+// CreateKey issues a key for the JSON request body and answers 201 with it;
+// the key itself is never shown again.
+func (ctrl *APIKeyController) CreateKey(c *gin.Context) {
+	var req domain.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.InvalidInput(c, "invalid_body", "body must be a JSON object with name, scopes and ttl_sec")
+		return
+	}
+
+	issued, err := ctrl.UseCase.CreateKey(c.Request.Context(), req)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, issued)
+}
+
+// ListKeys lists every key, without their secrets.
+func (ctrl *APIKeyController) ListKeys(c *gin.Context) {
+	keys, err := ctrl.UseCase.ListKeys(c.Request.Context())
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RotateKey issues a replacement for a key and answers 201 with it.
+func (ctrl *APIKeyController) RotateKey(c *gin.Context) {
+	issued, err := ctrl.UseCase.RotateKey(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, issued)
+}
+
+// RevokeKey revokes a key immediately and answers 204.
+func (ctrl *APIKeyController) RevokeKey(c *gin.Context) {
+	if err := ctrl.UseCase.RevokeKey(c.Request.Context(), c.Param("id")); err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAPIKeyUseCase struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyUseCase) CreateKey(ctx context.Context, req domain.APIKeyRequest) (*domain.IssuedAPIKey, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.IssuedAPIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyUseCase) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyUseCase) RotateKey(ctx context.Context, id string) (*domain.IssuedAPIKey, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.IssuedAPIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyUseCase) RevokeKey(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyUseCase) Authenticate(ctx context.Context, key string) (*domain.APIKey, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyUseCase) EnsureKey(ctx context.Context, name, key string, scopes []string) error {
+	args := m.Called(ctx, name, key, scopes)
+	return args.Error(0)
+}
+
+func newAPIKeyRouter(useCase *MockAPIKeyUseCase) *gin.Engine {
+	controller := &APIKeyController{UseCase: useCase}
+	router := gin.New()
+	router.POST("/admin/api-keys", controller.CreateKey)
+	router.POST("/admin/api-keys/:id/rotate", controller.RotateKey)
+	router.DELETE("/admin/api-keys/:id", controller.RevokeKey)
+	return router
+}
+
+func TestAPIKeyController_CreateKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("created", func(t *testing.T) {
+		mockUseCase := new(MockAPIKeyUseCase)
+		mockUseCase.On("CreateKey", mock.Anything, domain.APIKeyRequest{Name: "reports", Scopes: []string{"read"}}).
+			Return(&domain.IssuedAPIKey{APIKey: domain.APIKey{ID: "key-1", Name: "reports", Prefix: "stori_0123abcd", Scopes: []string{"read"}}, Key: "stori_0123abcdef"}, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"name":"reports","scopes":["read"]}`))
+		w := httptest.NewRecorder()
+		newAPIKeyRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"key":"stori_0123abcdef"`)
+		assert.NotContains(t, w.Body.String(), `"hash"`)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"scopes":"read"}`))
+		w := httptest.NewRecorder()
+		newAPIKeyRouter(new(MockAPIKeyUseCase)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_body"`)
+	})
+}
+
+func TestAPIKeyController_RotateAndRevoke(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockUseCase := new(MockAPIKeyUseCase)
+	mockUseCase.On("RotateKey", mock.Anything, "key-1").Return(&domain.IssuedAPIKey{APIKey: domain.APIKey{ID: "key-2", RotatedFrom: "key-1"}, Key: "stori_new"}, nil)
+	mockUseCase.On("RevokeKey", mock.Anything, "key-1").Return(nil)
+	mockUseCase.On("RevokeKey", mock.Anything, "key-9").Return(domain.NewError(domain.ErrNotFound, "api_key_not_found", "API key not found"))
+	router := newAPIKeyRouter(mockUseCase)
+
+	req, _ := http.NewRequest(http.MethodPost, "/admin/api-keys/key-1/rotate", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"rotated_from":"key-1"`)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/api-keys/key-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/api-keys/key-9", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"api_key_not_found"`)
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+// ConfigReloader reloads the configuration of the running service.
+type ConfigReloader interface {
+	Reload(ctx context.Context, trigger string) (*domain.ConfigReload, error)
+}
+
+type ConfigController struct {
+	Reloader ConfigReloader
+}
+
+// ReloadConfig loads the configuration again and applies the settings that
+// can change live, answering with what changed and what needs a restart. An
+// invalid configuration is rejected with 422 and the running one is kept.
+func (ctrl *ConfigController) ReloadConfig(c *gin.Context) {
+	result, err := ctrl.Reloader.Reload(c.Request.Context(), "api")
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockConfigReloader struct {
+	mock.Mock
+}
+
+func (m *MockConfigReloader) Reload(ctx context.Context, trigger string) (*domain.ConfigReload, error) {
+	args := m.Called(ctx, trigger)
+	result, _ := args.Get(0).(*domain.ConfigReload)
+	return result, args.Error(1)
+}
+
+func TestConfigController_ReloadConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		result *domain.ConfigReload
+		err    error
+		status int
+		body   string
+	}{
+		{"applied", &domain.ConfigReload{Changed: []string{"RATE_LIMIT"}, RestartRequired: []string{"DB_HOST"}}, nil,
+			http.StatusOK, `{"changed":["RATE_LIMIT"],"restart_required":["DB_HOST"]}`},
+		{"invalid", nil, domain.WrapError(domain.ErrUnprocessable, "invalid_config", "the configuration is invalid: RATE_LIMIT must be at least 1", errors.New("RATE_LIMIT must be at least 1")),
+			http.StatusUnprocessableEntity, `"code":"invalid_config"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloader := new(MockConfigReloader)
+			reloader.On("Reload", mock.Anything, "api").Return(tt.result, tt.err)
+			router := gin.New()
+			router.POST("/admin/config/reload", (&ConfigController{Reloader: reloader}).ReloadConfig)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+			reloader.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+)
+
+type HealthController struct {
+	UseCase usecase.HealthUseCase
+}
+
+// Live answers as long as the process serves requests, without checking
+// any dependency, so a failing database does not get the service restarted.
+func (ctrl *HealthController) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready reports the status of every dependency, answering 503 Service
+// Unavailable while a required one is down. Probes are not authenticated,
+// so why a dependency is down, e.g. the address it could not be reached
+// at, is left to the logs.
+func (ctrl *HealthController) Ready(c *gin.Context) {
+	report := ctrl.UseCase.Readiness(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	dependencies := make(map[string]domain.DependencyStatus, len(report.Dependencies))
+	for name, dependency := range report.Dependencies {
+		dependency.Error = ""
+		dependencies[name] = dependency
+	}
+	report.Dependencies = dependencies
+	c.JSON(status, report)
+}
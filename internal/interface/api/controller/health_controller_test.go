@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHealthUseCase struct {
+	mock.Mock
+}
+
+func (m *MockHealthUseCase) Readiness(ctx context.Context) domain.HealthReport {
+	return m.Called(ctx).Get(0).(domain.HealthReport)
+}
+
+func TestHealthController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(mockUseCase *MockHealthUseCase) *gin.Engine {
+		controller := &HealthController{UseCase: mockUseCase}
+		router := gin.Default()
+		router.GET("/healthz", controller.Live)
+		router.GET("/readyz", controller.Ready)
+		return router
+	}
+
+	t.Run("live", func(t *testing.T) {
+		mockUseCase := new(MockHealthUseCase)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+		mockUseCase.AssertNotCalled(t, "Readiness", mock.Anything)
+	})
+
+	tests := []struct {
+		name   string
+		report domain.HealthReport
+		status int
+	}{
+		{"ready", domain.HealthReport{Status: domain.HealthReady, Dependencies: map[string]domain.DependencyStatus{
+			"postgres": {Status: domain.DependencyUp, Required: true, LatencyMs: 1},
+		}}, http.StatusOK},
+		{"degraded", domain.HealthReport{Status: domain.HealthDegraded, Dependencies: map[string]domain.DependencyStatus{
+			"mail": {Status: domain.DependencyDown, Error: "connection refused"},
+		}}, http.StatusOK},
+		{"unavailable", domain.HealthReport{Status: domain.HealthUnavailable, Dependencies: map[string]domain.DependencyStatus{
+			"migrations": {Status: domain.DependencyDown, Required: true, Error: "pending migrations: 20240710090000"},
+		}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := new(MockHealthUseCase)
+			mockUseCase.On("Readiness", mock.Anything).Return(tt.report)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+			newRouter(mockUseCase).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), `"status":"`+tt.report.Status+`"`)
+			assert.NotContains(t, w.Body.String(), `"error"`, "why dependencies are down is only logged")
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+type JobController struct {
+	UseCase usecase.JobUseCase
+}
+
+// GetJob reports the state, per-stage timings, row counts and error of a job.
+func (ctrl *JobController) GetJob(c *gin.Context) {
+	job, err := ctrl.UseCase.GetJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
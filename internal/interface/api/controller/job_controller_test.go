@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestJobController_GetJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(mockUseCase *MockJobUseCase) *gin.Engine {
+		controller := &JobController{UseCase: mockUseCase}
+		router := gin.Default()
+		router.GET("/jobs/:id", controller.GetJob)
+		return router
+	}
+
+	t.Run("found", func(t *testing.T) {
+		mockUseCase := new(MockJobUseCase)
+		mockUseCase.On("GetJob", mock.Anything, "job-1").Return(&domain.Job{
+			ID:        "job-1",
+			AccountID: "default",
+			State:     domain.JobStateSucceeded,
+			Stages:    []domain.StageTiming{{Name: "save", DurationMs: 12}},
+			RowsRead:  100,
+			RowsSaved: 100,
+			Attempts:  1,
+		}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"state":"succeeded"`)
+		assert.Contains(t, w.Body.String(), `"rows_saved":100`)
+		assert.Contains(t, w.Body.String(), `"duration_ms":12`)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockUseCase := new(MockJobUseCase)
+		mockUseCase.On("GetJob", mock.Anything, "missing").Return(nil, domain.WrapError(domain.ErrNotFound, "job_not_found", "job not found", domain.ErrNotFound))
+
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/missing", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"job not found","instance":"/jobs/missing","code":"job_not_found"}`, w.Body.String())
+	})
+
+	t.Run("internal server error", func(t *testing.T) {
+		mockUseCase := new(MockJobUseCase)
+		mockUseCase.On("GetJob", mock.Anything, "job-2").Return(nil, errors.New("internal error"))
+
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/job-2", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
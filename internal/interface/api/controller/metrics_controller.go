@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsController struct {
+	// Handler serves the metrics in the Prometheus text format.
+	Handler http.Handler
+}
+
+// GetMetrics serves the metrics of the service for Prometheus to scrape.
+func (ctrl *MetricsController) GetMetrics(c *gin.Context) {
+	ctrl.Handler.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+type ScheduleController struct {
+	UseCase usecase.ScheduleUseCase
+}
+
+// ListRuns returns the history of scheduled statement runs, newest first.
+func (ctrl *ScheduleController) ListRuns(c *gin.Context) {
+	limit, err := parseIntQuery(c, "limit")
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	runs, err := ctrl.UseCase.ListRuns(c.Request.Context(), limit)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
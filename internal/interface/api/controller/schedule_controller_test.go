@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduleUseCase struct {
+	mock.Mock
+}
+
+func (m *MockScheduleUseCase) RunDue(ctx context.Context, now time.Time) ([]domain.ScheduleRun, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.ScheduleRun), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockScheduleUseCase) ListRuns(ctx context.Context, limit int) ([]domain.ScheduleRun, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.ScheduleRun), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestScheduleController_ListRuns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(mockUseCase *MockScheduleUseCase) *gin.Engine {
+		controller := &ScheduleController{UseCase: mockUseCase}
+		router := gin.Default()
+		router.GET("/schedule/runs", controller.ListRuns)
+		return router
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockUseCase := new(MockScheduleUseCase)
+		mockUseCase.On("ListRuns", mock.Anything, 10).Return([]domain.ScheduleRun{
+			{ID: 1, AccountID: "acc-1", Period: "2024-05", JobID: "job-1", CatchUp: true},
+		}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/schedule/runs?limit=10", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"period":"2024-05"`)
+		assert.Contains(t, w.Body.String(), `"catch_up":true`)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/schedule/runs?limit=-1", nil)
+		w := httptest.NewRecorder()
+		newRouter(new(MockScheduleUseCase)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"limit must be a non-negative integer","instance":"/schedule/runs","code":"invalid_limit"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SpecController struct {
+	// Document is the OpenAPI document, as JSON.
+	Document []byte
+}
+
+// GetSpec serves the OpenAPI document of the API.
+func (ctrl *SpecController) GetSpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", ctrl.Document)
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+	csvreader "github.com/jordanlanch/stori-test/internal/interface/csvreader"
+)
+
+type StatementController struct {
+	UseCase usecase.StatementUseCase
+}
+
+// PreviewAccountStatement renders the statement for the transactions stored
+// for the account_id query parameter without sending it.
+func (ctrl *StatementController) PreviewAccountStatement(c *gin.Context) {
+	req := domain.PreviewRequest{AccountID: c.Query("account_id")}
+	ctrl.preview(c, req)
+}
+
+// PreviewCSVStatement renders the statement for the CSV sent as the request
+// body without storing or sending anything.
+func (ctrl *StatementController) PreviewCSVStatement(c *gin.Context) {
+	transactions, err := csvreader.ParseTransactions(c.Request.Body)
+	if err != nil {
+		problem.InvalidInput(c, "invalid_csv", "invalid CSV: "+err.Error())
+		return
+	}
+	ctrl.preview(c, domain.PreviewRequest{Transactions: transactions})
+}
+
+func (ctrl *StatementController) preview(c *gin.Context, req domain.PreviewRequest) {
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "text" && format != "json" {
+		problem.InvalidInput(c, "invalid_format", "format must be one of html, text or json")
+		return
+	}
+
+	rendered, err := ctrl.UseCase.PreviewStatement(c.Request.Context(), req)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	switch format {
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+	case "json":
+		c.JSON(http.StatusOK, rendered)
+	default:
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+	}
+}
+
+// ListStatements lists archived statements filtered by the account_id,
+// recipient, period (YYYY-MM), status, since and until (RFC 3339 or
+// YYYY-MM-DD) query parameters, paginated with limit and offset.
+func (ctrl *StatementController) ListStatements(c *gin.Context) {
+	filter := domain.StatementFilter{
+		AccountID: c.Query("account_id"),
+		Recipient: c.Query("recipient"),
+		Status:    c.Query("status"),
+	}
+
+	var err error
+	if filter.Period, err = parsePeriodQuery(c); err != nil {
+		problem.Write(c, err)
+		return
+	}
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		problem.Write(c, err)
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		problem.Write(c, err)
+		return
+	}
+	if filter.Limit, err = parseIntQuery(c, "limit"); err != nil {
+		problem.Write(c, err)
+		return
+	}
+	if filter.Offset, err = parseIntQuery(c, "offset"); err != nil {
+		problem.Write(c, err)
+		return
+	}
+
+	statements, err := ctrl.UseCase.ListStatements(c.Request.Context(), filter)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"statements": statements})
+}
+
+func (ctrl *StatementController) GetStatement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("statement_id"))
+	if err != nil {
+		problem.InvalidInput(c, "invalid_statement_id", "statement id must be an integer")
+		return
+	}
+
+	statement, err := ctrl.UseCase.GetStatement(c.Request.Context(), id)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
+
+func (ctrl *StatementController) ResendStatement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("statement_id"))
+	if err != nil {
+		problem.InvalidInput(c, "invalid_statement_id", "statement id must be an integer")
+		return
+	}
+
+	statement, err := ctrl.UseCase.ResendStatement(c.Request.Context(), id)
+	if err != nil {
+		problem.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
+
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, domain.NewError(domain.ErrInvalidInput, "invalid_"+name, name+" must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+}
+
+func parsePeriodQuery(c *gin.Context) (string, error) {
+	period := c.Query("period")
+	if period == "" {
+		return "", nil
+	}
+	if _, err := time.Parse("2006-01", period); err != nil {
+		return "", domain.NewError(domain.ErrInvalidInput, "invalid_period", "period must be formatted as YYYY-MM")
+	}
+	return period, nil
+}
+
+func parseIntQuery(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, domain.NewError(domain.ErrInvalidInput, "invalid_"+name, name+" must be a non-negative integer")
+	}
+	return n, nil
+}
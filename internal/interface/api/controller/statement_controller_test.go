@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStatementUseCase mocks the StatementUseCase interface
+type MockStatementUseCase struct {
+	mock.Mock
+}
+
+func (m *MockStatementUseCase) PreviewStatement(ctx context.Context, req domain.PreviewRequest) (*domain.RenderedEmail, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.RenderedEmail), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementUseCase) GetStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementUseCase) ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementUseCase) ResendStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementUseCase) SendStatement(ctx context.Context, accountID, period string) (*domain.Statement, error) {
+	args := m.Called(ctx, accountID, period)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementUseCase) RedriveFailed(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Statement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestStatementController_Preview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rendered := &domain.RenderedEmail{Subject: "Monthly Transaction Summary", HTML: "<p>summary</p>", Text: "summary"}
+
+	newRouter := func(mockUseCase *MockStatementUseCase) *gin.Engine {
+		controller := &StatementController{UseCase: mockUseCase}
+		router := gin.Default()
+		router.GET("/statements/preview", controller.PreviewAccountStatement)
+		router.POST("/statements/preview", controller.PreviewCSVStatement)
+		return router
+	}
+
+	t.Run("account as html", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		mockUseCase.On("PreviewStatement", mock.Anything, domain.PreviewRequest{AccountID: "acc-1"}).Return(rendered, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements/preview?account_id=acc-1", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "<p>summary</p>", w.Body.String())
+		mockUseCase.AssertExpectations(t)
+	})
+
+	t.Run("csv as text", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		expected := domain.PreviewRequest{Transactions: []domain.Transaction{
+			{ID: 0, Date: "7/15", Amount: 60.5},
+			{ID: 1, Date: "7/28", Amount: -10.3},
+		}}
+		mockUseCase.On("PreviewStatement", mock.Anything, expected).Return(rendered, nil)
+
+		body := strings.NewReader("Id,Date,Transaction\n0,7/15,+60.5\n1,7/28,-10.3\n")
+		req, _ := http.NewRequest(http.MethodPost, "/statements/preview?format=text", body)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "summary", w.Body.String())
+		mockUseCase.AssertExpectations(t)
+	})
+
+	t.Run("invalid csv", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+
+		req, _ := http.NewRequest(http.MethodPost, "/statements/preview", strings.NewReader("Id,Date,Transaction\nx,7/15,+60.5\n"))
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockUseCase.AssertNotCalled(t, "PreviewStatement", mock.Anything, mock.Anything)
+	})
+
+	t.Run("short csv row", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+
+		req, _ := http.NewRequest(http.MethodPost, "/statements/preview", strings.NewReader("id\n1\n"))
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid CSV: row 2: expected 3 fields, got 1","instance":"/statements/preview","code":"invalid_csv"}`, w.Body.String())
+		mockUseCase.AssertNotCalled(t, "PreviewStatement", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements/preview?format=pdf", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"format must be one of html, text or json","instance":"/statements/preview","code":"invalid_format"}`, w.Body.String())
+	})
+
+	t.Run("usecase error", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		mockUseCase.On("PreviewStatement", mock.Anything, mock.Anything).Return(nil, domain.NewError(domain.ErrSourceNotFound, "no_transactions", "no transactions found for account acc-2"))
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements/preview?account_id=acc-2&format=json", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"no transactions found for account acc-2","instance":"/statements/preview","code":"no_transactions"}`, w.Body.String())
+	})
+}
+
+func TestStatementController_Archive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(mockUseCase *MockStatementUseCase) *gin.Engine {
+		controller := &StatementController{UseCase: mockUseCase}
+		router := gin.Default()
+		router.GET("/statements/preview", controller.PreviewAccountStatement)
+		router.GET("/statements", controller.ListStatements)
+		router.GET("/statements/:statement_id", controller.GetStatement)
+		router.POST("/statements/:statement_id/resend", controller.ResendStatement)
+		return router
+	}
+
+	t.Run("list with filters", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		mockUseCase.On("ListStatements", mock.Anything, domain.StatementFilter{
+			AccountID: "acc-1",
+			Period:    "2024-03",
+			Since:     &since,
+			Limit:     10,
+		}).Return([]domain.Statement{{ID: 3, AccountID: "acc-1", Period: "2024-03", Status: "sent"}}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements?account_id=acc-1&period=2024-03&since=2024-03-01&limit=10", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":3`)
+		mockUseCase.AssertExpectations(t)
+	})
+
+	t.Run("list with invalid period", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements?period=March", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"period must be formatted as YYYY-MM","instance":"/statements","code":"invalid_period"}`, w.Body.String())
+	})
+
+	t.Run("get", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		mockUseCase.On("GetStatement", mock.Anything, 3).Return(&domain.Statement{ID: 3, HTMLBody: "<p>summary</p>"}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements/3", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"html_body":"\u003cp\u003esummary\u003c/p\u003e"`)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		mockUseCase.On("GetStatement", mock.Anything, 4).Return(nil, domain.WrapError(domain.ErrNotFound, "statement_not_found", "statement not found", domain.ErrNotFound))
+
+		req, _ := http.NewRequest(http.MethodGet, "/statements/4", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"statement not found","instance":"/statements/4","code":"statement_not_found"}`, w.Body.String())
+	})
+
+	t.Run("resend", func(t *testing.T) {
+		mockUseCase := new(MockStatementUseCase)
+		resentFrom := 3
+		mockUseCase.On("ResendStatement", mock.Anything, 3).Return(&domain.Statement{ID: 5, ResentFromID: &resentFrom, Status: "sent"}, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/statements/3/resend", nil)
+		w := httptest.NewRecorder()
+		newRouter(mockUseCase).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"resent_from_id":3`)
+		mockUseCase.AssertExpectations(t)
+	})
+}
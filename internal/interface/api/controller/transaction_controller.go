@@ -5,23 +5,28 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
 	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
 )
 
 type TransactionController struct {
-	UseCase usecase.TransactionUseCase
+	UseCase usecase.JobUseCase
 }
 
+// ProcessTransactions enqueues a processing job for the account_id query
+// parameter (the default account without one) and answers 202 with its ID;
+// the job's progress is available at GET /jobs/{id}. It answers 409 while a
+// run for the account is in progress.
 func (ctrl *TransactionController) ProcessTransactions(c *gin.Context) {
 	ctx := c.Request.Context()
-	err := ctrl.UseCase.ProcessTransactions(ctx)
+	job, err := ctrl.UseCase.SubmitProcessing(ctx, domain.ProcessRequest{AccountID: c.Query("account_id")})
 	if err != nil {
-		if err.Error() == "too many requests" {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Write(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Transactions processed and email sent"})
+
+	statusURL := "/jobs/" + job.ID
+	c.Header("Location", statusURL)
+	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "state": job.State, "status_url": statusURL})
 }
@@ -8,26 +8,56 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-// MockTransactionUseCase mocks the TransactionUseCase interface
-type MockTransactionUseCase struct {
+// MockJobUseCase mocks the JobUseCase interface
+type MockJobUseCase struct {
 	mock.Mock
 }
 
-func (m *MockTransactionUseCase) ProcessTransactions(ctx context.Context) error {
-	args := m.Called(ctx)
+func (m *MockJobUseCase) SubmitProcessing(ctx context.Context, req domain.ProcessRequest) (*domain.Job, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobUseCase) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobUseCase) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockJobUseCase) RunJob(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockJobUseCase) AbandonJob(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
 func TestTransactionController_ProcessTransactions(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	t.Run("success", func(t *testing.T) {
-		mockUseCase := new(MockTransactionUseCase)
-		mockUseCase.On("ProcessTransactions", mock.Anything).Return(nil)
+	t.Run("accepted", func(t *testing.T) {
+		mockUseCase := new(MockJobUseCase)
+		mockUseCase.On("SubmitProcessing", mock.Anything, domain.ProcessRequest{}).Return(&domain.Job{ID: "job-1", State: domain.JobStateQueued}, nil)
 
 		controller := &TransactionController{UseCase: mockUseCase}
 		router := gin.Default()
@@ -38,15 +68,16 @@ func TestTransactionController_ProcessTransactions(t *testing.T) {
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusOK, w.Code)
-		assert.JSONEq(t, `{"message":"Transactions processed and email sent"}`, w.Body.String())
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "/jobs/job-1", w.Header().Get("Location"))
+		assert.JSONEq(t, `{"job_id":"job-1","state":"queued","status_url":"/jobs/job-1"}`, w.Body.String())
 
 		mockUseCase.AssertExpectations(t)
 	})
 
-	t.Run("too many requests", func(t *testing.T) {
-		mockUseCase := new(MockTransactionUseCase)
-		mockUseCase.On("ProcessTransactions", mock.Anything).Return(errors.New("too many requests"))
+	t.Run("run in progress", func(t *testing.T) {
+		mockUseCase := new(MockJobUseCase)
+		mockUseCase.On("SubmitProcessing", mock.Anything, mock.Anything).Return(nil, domain.ErrRunInProgress)
 
 		controller := &TransactionController{UseCase: mockUseCase}
 		router := gin.Default()
@@ -57,15 +88,15 @@ func TestTransactionController_ProcessTransactions(t *testing.T) {
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusTooManyRequests, w.Code)
-		assert.JSONEq(t, `{"error":"too many requests"}`, w.Body.String())
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Conflict","status":409,"detail":"a run for this account is already in progress","instance":"/process-transactions","code":"run_in_progress"}`, w.Body.String())
 
 		mockUseCase.AssertExpectations(t)
 	})
 
 	t.Run("internal server error", func(t *testing.T) {
-		mockUseCase := new(MockTransactionUseCase)
-		mockUseCase.On("ProcessTransactions", mock.Anything).Return(errors.New("internal error"))
+		mockUseCase := new(MockJobUseCase)
+		mockUseCase.On("SubmitProcessing", mock.Anything, mock.Anything).Return(nil, errors.New("pq: connection to 10.0.0.5 refused"))
 
 		controller := &TransactionController{UseCase: mockUseCase}
 		router := gin.Default()
@@ -77,7 +108,7 @@ func TestTransactionController_ProcessTransactions(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.JSONEq(t, `{"error":"internal error"}`, w.Body.String())
+		assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/process-transactions","code":"internal_error"}`, w.Body.String())
 
 		mockUseCase.AssertExpectations(t)
 	})
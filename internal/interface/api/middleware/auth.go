@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+const (
+	principalContextKey = "principal"
+	authErrorContextKey = "auth_error"
+)
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, key string) (*domain.APIKey, error)
+}
+
+// TokenVerifier validates bearer tokens, e.g. JWTs issued by an OIDC
+// provider.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*domain.Principal, error)
+}
+
+// Authenticate identifies the caller by a bearer token in the Authorization
+// header or an API key in the X-API-Key header, and makes it available to
+// RequireScope and RequireAccount. Either method is disabled when nil.
+// Requests failing authentication go on without a caller, so that RateLimit
+// counts them per IP, and RequireAuthenticated answers them 401.
+func Authenticate(apiKeys Authenticator, tokens TokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var principal *domain.Principal
+		var err error
+		token, isBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		switch {
+		case isBearer && tokens != nil:
+			principal, err = tokens.Verify(c.Request.Context(), strings.TrimSpace(token))
+		case c.GetHeader("X-API-Key") != "" && apiKeys != nil:
+			var key *domain.APIKey
+			if key, err = apiKeys.Authenticate(c.Request.Context(), c.GetHeader("X-API-Key")); err == nil {
+				principal = key.Principal()
+			}
+		default:
+			err = domain.NewError(domain.ErrUnauthorized, "missing_credentials", "a bearer token or an API key is required")
+		}
+		if err != nil {
+			if tokens != nil {
+				c.Header("WWW-Authenticate", "Bearer")
+			}
+			c.Set(authErrorContextKey, err)
+			c.Next()
+			return
+		}
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// setPrincipal makes principal available to the next handlers and, through
+// the request context, to the use cases.
+func setPrincipal(c *gin.Context, principal *domain.Principal) {
+	c.Set(principalContextKey, principal)
+	c.Request = c.Request.WithContext(domain.WithPrincipal(c.Request.Context(), principal))
+}
+
+// anonymous is the caller of every request when authentication is disabled.
+var anonymous = &domain.Principal{Subject: "anonymous", Scopes: []string{domain.ScopeAdmin}, AllAccounts: true}
+
+// Anonymous lets every request through with all scopes and accounts, for
+// deployments with authentication disabled.
+func Anonymous() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setPrincipal(c, anonymous)
+		c.Next()
+	}
+}
+
+// RequireAuthenticated answers 401, with the reason Authenticate failed,
+// unless the caller was identified.
+func RequireAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := principalOf(c); ok {
+			c.Next()
+		}
+	}
+}
+
+// RequireScope answers 403 unless the caller was granted scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := principalOf(c)
+		if !ok {
+			return
+		}
+		if !principal.HasScope(scope) {
+			problem.Write(c, domain.NewError(domain.ErrForbidden, "insufficient_scope", "the caller lacks the "+scope+" scope"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAccount answers 403 unless the caller may access the account of
+// the request: the account_id path or query parameter, or defaultAccount
+// without one.
+func RequireAccount(defaultAccount string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := principalOf(c)
+		if !ok {
+			return
+		}
+		accountID := c.Param("account_id")
+		if accountID == "" {
+			accountID = c.Query("account_id")
+		}
+		if accountID == "" {
+			accountID = defaultAccount
+		}
+		if !principal.CanAccess(accountID) {
+			problem.Write(c, domain.NewError(domain.ErrForbidden, "account_forbidden", "the caller may not access account "+accountID))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAccountFilter guards listings across accounts: callers restricted
+// to some accounts must filter by one of theirs with account_id.
+func RequireAccountFilter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := principalOf(c)
+		if !ok {
+			return
+		}
+		accountID := c.Query("account_id")
+		if principal.AllAccounts || (accountID != "" && principal.CanAccess(accountID)) {
+			c.Next()
+			return
+		}
+		problem.Write(c, domain.NewError(domain.ErrForbidden, "account_forbidden", "account_id must be one of the caller's accounts"))
+	}
+}
+
+// principalOf returns the caller set by Authenticate, answering 401 with the
+// reason authentication failed if there is none.
+func principalOf(c *gin.Context) (*domain.Principal, bool) {
+	principal, ok := c.Value(principalContextKey).(*domain.Principal)
+	if !ok {
+		err, failed := c.Value(authErrorContextKey).(error)
+		if !failed {
+			err = domain.NewError(domain.ErrUnauthorized, "missing_credentials", "a bearer token or an API key is required")
+		}
+		problem.Write(c, err)
+	}
+	return principal, ok
+}
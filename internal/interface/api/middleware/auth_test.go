@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuthenticator struct {
+	mock.Mock
+}
+
+func (m *MockAuthenticator) Authenticate(ctx context.Context, key string) (*domain.APIKey, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockTokenVerifier struct {
+	mock.Mock
+}
+
+func (m *MockTokenVerifier) Verify(ctx context.Context, token string) (*domain.Principal, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Principal), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auth := new(MockAuthenticator)
+	auth.On("Authenticate", mock.Anything, "stori_reader").Return(&domain.APIKey{Scopes: []string{domain.ScopeRead}}, nil)
+	auth.On("Authenticate", mock.Anything, "stori_admin").Return(&domain.APIKey{Scopes: []string{domain.ScopeAdmin}}, nil)
+	auth.On("Authenticate", mock.Anything, "stori_revoked").Return(nil, domain.NewError(domain.ErrUnauthorized, "invalid_api_key", "invalid, expired or revoked API key"))
+
+	tokens := new(MockTokenVerifier)
+	tokens.On("Verify", mock.Anything, "portal-token").Return(&domain.Principal{Subject: "user-1", Scopes: []string{domain.ScopeRead}, Accounts: []string{"acc-1"}}, nil)
+	tokens.On("Verify", mock.Anything, "expired-token").Return(nil, domain.NewError(domain.ErrUnauthorized, "invalid_token", "invalid or expired bearer token"))
+
+	router := gin.New()
+	router.Use(Authenticate(auth, tokens))
+	router.GET("/statements", RequireScope(domain.ScopeRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/process-transactions", RequireScope(domain.ScopeIngest), func(c *gin.Context) { c.Status(http.StatusAccepted) })
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		token  string
+		status int
+		code   string
+	}{
+		{"missing credentials", http.MethodGet, "/statements", "", "", http.StatusUnauthorized, "missing_credentials"},
+		{"revoked key", http.MethodGet, "/statements", "stori_revoked", "", http.StatusUnauthorized, "invalid_api_key"},
+		{"granted scope", http.MethodGet, "/statements", "stori_reader", "", http.StatusOK, ""},
+		{"missing scope", http.MethodPost, "/process-transactions", "stori_reader", "", http.StatusForbidden, "insufficient_scope"},
+		{"admin holds every scope", http.MethodPost, "/process-transactions", "stori_admin", "", http.StatusAccepted, ""},
+		{"bearer token", http.MethodGet, "/statements", "", "portal-token", http.StatusOK, ""},
+		{"expired bearer token", http.MethodGet, "/statements", "", "expired-token", http.StatusUnauthorized, "invalid_token"},
+		{"bearer token without scope", http.MethodPost, "/process-transactions", "", "portal-token", http.StatusForbidden, "insufficient_scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.code+`"`)
+			}
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Anonymous())
+	router.DELETE("/admin/api-keys/:id", RequireScope(domain.ScopeAdmin), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	req, _ := http.NewRequest(http.MethodDelete, "/admin/api-keys/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestRequireAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	portalUser := &domain.Principal{Subject: "user-1", Scopes: []string{domain.ScopeRead}, Accounts: []string{"acc-1"}}
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(principalContextKey, portalUser) })
+	router.GET("/accounts/:account_id/summary", RequireAccount("default"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/statements/preview", RequireAccount("default"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/statements", RequireAccountFilter(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/accounts/acc-1/summary", http.StatusOK},
+		{"/accounts/acc-2/summary", http.StatusForbidden},
+		{"/statements/preview?account_id=acc-1", http.StatusOK},
+		{"/statements/preview", http.StatusForbidden},
+		{"/statements?account_id=acc-1", http.StatusOK},
+		{"/statements?account_id=acc-2", http.StatusForbidden},
+		{"/statements", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.path)
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+const maxIdempotencyKeyLen = 255
+
+// replayedHeaders are the response headers stored with a response and
+// replayed with it.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+type IdempotencyStore interface {
+	Begin(ctx context.Context, key, fingerprint string) (*domain.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key string, record *domain.IdempotencyRecord) error
+	Release(ctx context.Context, key string, record *domain.IdempotencyRecord) error
+}
+
+// Idempotency makes retries of a request carrying an Idempotency-Key header
+// safe: the response to the first request with a key is stored and replayed,
+// with Idempotent-Replayed: true, to later requests with the same key and
+// payload. Reusing a key for a different payload answers 422, and retrying
+// while the first request is still being handled answers 409. Keys are
+// scoped to the caller. Server errors, 409 and 429 responses are not stored,
+// so the request can be retried with the same key.
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			problem.InvalidInput(c, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters long")
+			return
+		}
+		if principal, ok := c.Value(principalContextKey).(*domain.Principal); ok {
+			key = principal.Subject + ":" + key
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			problem.InvalidInput(c, "invalid_body", "the request body could not be read")
+			return
+		}
+
+		record, started, err := store.Begin(c.Request.Context(), key, fingerprint)
+		if err != nil {
+			problem.Write(c, domain.WrapError(domain.ErrUnavailable, "idempotency_unavailable", "the idempotency key could not be checked", err))
+			return
+		}
+		if !started {
+			replay(c, record, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The request may have been cancelled by now; the record must still
+		// be settled.
+		ctx := context.Background()
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusConflict || status == http.StatusTooManyRequests {
+			if err := store.Release(ctx, key, record); err != nil {
+				slog.ErrorContext(c.Request.Context(), "releasing idempotency key failed", "error", err)
+			}
+			return
+		}
+
+		record.Status = status
+		record.Body = recorder.body.Bytes()
+		record.Header = map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				record.Header[name] = value
+			}
+		}
+		if err := store.Complete(ctx, key, record); err != nil {
+			slog.ErrorContext(c.Request.Context(), "storing idempotent response failed", "error", err)
+		}
+	}
+}
+
+func replay(c *gin.Context, record *domain.IdempotencyRecord, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		problem.Write(c, domain.NewError(domain.ErrUnprocessable, "idempotency_key_reused", "the Idempotency-Key was already used for a different request"))
+	case !record.Completed:
+		problem.Write(c, domain.NewError(domain.ErrConflict, "idempotency_key_in_progress", "a request with this Idempotency-Key is still being processed"))
+	default:
+		for name, value := range record.Header {
+			c.Header(name, value)
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(record.Status)
+		c.Writer.Write(record.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint hashes the method, path, query and body of the request,
+// restoring the body for the handler.
+func requestFingerprint(c *gin.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	hash := sha256.New()
+	io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore keeps records in a map, without expiry.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]domain.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Begin(ctx context.Context, key, fingerprint string) (*domain.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return &record, false, nil
+	}
+	record := domain.IdempotencyRecord{Fingerprint: fingerprint, Token: key}
+	s.records[key] = record
+	return &record, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.Completed = true
+	s.records[key] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &memoryIdempotencyStore{records: map[string]domain.IdempotencyRecord{}}
+	calls := 0
+	status := http.StatusAccepted
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(principalContextKey, &domain.Principal{Subject: c.GetHeader("X-Subject")})
+	})
+	router.POST("/process-transactions", Idempotency(store), func(c *gin.Context) {
+		calls++
+		if status != http.StatusAccepted {
+			c.Status(status)
+			return
+		}
+		c.Header("Location", "/jobs/job-1")
+		c.JSON(status, gin.H{"job_id": "job-1", "call": calls})
+	})
+
+	send := func(path, key, subject string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		req.Header.Set("X-Subject", subject)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("/process-transactions?account_id=acc-1", "key-1", "user-1")
+	assert.Equal(t, http.StatusAccepted, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	replayed := send("/process-transactions?account_id=acc-1", "key-1", "user-1")
+	assert.Equal(t, http.StatusAccepted, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/jobs/job-1", replayed.Header().Get("Location"))
+	assert.Equal(t, "application/json; charset=utf-8", replayed.Header().Get("Content-Type"))
+	assert.JSONEq(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, 1, calls)
+
+	reused := send("/process-transactions?account_id=acc-2", "key-1", "user-1")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Contains(t, reused.Body.String(), `"code":"idempotency_key_reused"`)
+
+	// Keys are scoped to the caller.
+	send("/process-transactions?account_id=acc-1", "key-1", "user-2")
+	assert.Equal(t, 2, calls)
+
+	// Requests without a key are not deduplicated.
+	send("/process-transactions", "", "user-1")
+	send("/process-transactions", "", "user-1")
+	assert.Equal(t, 4, calls)
+
+	// Conflicts are not stored, so the request can be retried.
+	status = http.StatusConflict
+	assert.Equal(t, http.StatusConflict, send("/process-transactions", "key-2", "user-1").Code)
+	status = http.StatusAccepted
+	assert.Equal(t, http.StatusAccepted, send("/process-transactions", "key-2", "user-1").Code)
+	assert.Equal(t, 6, calls)
+
+	store.records["user-1:key-3"] = domain.IdempotencyRecord{Fingerprint: requestFingerprintOf(t, "/process-transactions")}
+	inProgress := send("/process-transactions", "key-3", "user-1")
+	assert.Equal(t, http.StatusConflict, inProgress.Code)
+	assert.Contains(t, inProgress.Body.String(), `"code":"idempotency_key_in_progress"`)
+
+	tooLong := send("/process-transactions", strings.Repeat("k", 256), "user-1")
+	assert.Equal(t, http.StatusBadRequest, tooLong.Code)
+	assert.Equal(t, 6, calls)
+}
+
+func requestFingerprintOf(t *testing.T, path string) string {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodPost, path, nil)
+	fingerprint, err := requestFingerprint(c)
+	assert.NoError(t, err)
+	return fingerprint
+}
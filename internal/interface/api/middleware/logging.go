@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+// RequestIDHeader carries the ID correlating a request with its log lines,
+// jobs and emails.
+const RequestIDHeader = "X-Request-Id"
+
+// validRequestID accepts caller-supplied IDs that are safe to log and store.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,64}$`)
+
+// RequestID stores the caller's X-Request-Id, or a new one when missing or
+// malformed, in the request context and echoes it in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(domain.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// Logger writes an access log line for every request once it has been
+// handled, at warn level for 4xx responses and error level for 5xx ones.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		slog.Log(c.Request.Context(), level, "request handled",
+			"method", c.Request.Method,
+			"route", route,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
+
+// Recovery answers 500 to requests whose handler panicked, logging the panic
+// with its stack.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				slog.ErrorContext(c.Request.Context(), "panic recovered", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+				problem.Write(c, fmt.Errorf("panic: %v", rec))
+			}
+		}()
+		c.Next()
+	}
+}
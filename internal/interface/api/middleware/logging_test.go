@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sends the default logger's records to the returned buffer for
+// the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{})
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, domain.RequestIDFromContext(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{"generated when missing", "", false},
+		{"caller's ID kept", "checkout-42.retry:1", true},
+		{"malformed ID replaced", "bad id\r\nX-Injected: 1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, w.Body.String())
+			assert.Equal(t, tt.kept, id == tt.incoming)
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	buf := captureLogs(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Logger())
+	router.GET("/jobs/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "request handled", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "/jobs/:id", record["route"])
+	assert.Equal(t, "/jobs/job-1", record["path"])
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+}
+
+func TestRecovery(t *testing.T) {
+	buf := captureLogs(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery())
+	router.GET("/", func(c *gin.Context) { panic("boom") })
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, buf.String(), `"msg":"panic recovered","panic":"boom"`)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/jobs/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	ok := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/jobs/:id", "200")
+	unmatched := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "unmatched", "404")
+	okBefore, unmatchedBefore := testutil.ToFloat64(ok), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/jobs/job-1", "/jobs/job-2", "/wp-login.php"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, okBefore+2, testutil.ToFloat64(ok))
+	assert.Equal(t, unmatchedBefore+1, testutil.ToFloat64(unmatched))
+}
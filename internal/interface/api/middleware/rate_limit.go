@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+// Rate limit key kinds: who a limit is counted per.
+const (
+	KeyIP      = "ip"
+	KeyClient  = "client"
+	KeyAccount = "account"
+)
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error)
+}
+
+// RatePolicy is a limit counted separately per IP, client or account.
+type RatePolicy struct {
+	Limit domain.RateLimit
+	Key   string
+}
+
+// RatePolicies holds the policy of each route, keyed by method and route
+// pattern (e.g. "POST /process-transactions"). Routes without their own
+// policy share Default.
+type RatePolicies struct {
+	Default RatePolicy
+	Routes  map[string]RatePolicy
+}
+
+// RatePolicySet holds the policies in force, which can be replaced while
+// requests are served.
+type RatePolicySet struct {
+	current atomic.Pointer[RatePolicies]
+}
+
+func NewRatePolicySet(policies RatePolicies) *RatePolicySet {
+	set := &RatePolicySet{}
+	set.Set(policies)
+	return set
+}
+
+// Get returns the policies in force.
+func (s *RatePolicySet) Get() RatePolicies {
+	return *s.current.Load()
+}
+
+// Set replaces the policies in force.
+func (s *RatePolicySet) Set(policies RatePolicies) {
+	s.current.Store(&policies)
+}
+
+// ParseRatePolicies parses per-route policies overriding fallback, in the
+// form "POST /process-transactions=10/m,burst=5,key=account; *=100/s". The
+// rate is a count per s, m or h; burst defaults to the count and key to the
+// fallback's. The route "*" replaces the default policy.
+func ParseRatePolicies(spec string, fallback RatePolicy) (RatePolicies, error) {
+	policies := RatePolicies{Default: fallback, Routes: map[string]RatePolicy{}}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return policies, fmt.Errorf("rate limit %q: expected ROUTE=RATE", entry)
+		}
+		policy, err := parseRatePolicy(strings.TrimSpace(rule), fallback.Key)
+		if err != nil {
+			return policies, fmt.Errorf("rate limit %q: %w", entry, err)
+		}
+		route = strings.Join(strings.Fields(route), " ")
+		if route == "*" {
+			policies.Default = policy
+		} else {
+			policies.Routes[route] = policy
+		}
+	}
+	return policies, nil
+}
+
+func parseRatePolicy(rule, key string) (RatePolicy, error) {
+	parts := strings.Split(rule, ",")
+	count, unit, ok := strings.Cut(strings.TrimSpace(parts[0]), "/")
+	if !ok {
+		return RatePolicy{}, fmt.Errorf("rate %q: expected COUNT/UNIT", parts[0])
+	}
+	rate, err := strconv.Atoi(count)
+	if err != nil || rate <= 0 {
+		return RatePolicy{}, fmt.Errorf("rate %q: count must be a positive integer", parts[0])
+	}
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[unit]
+	if !ok {
+		return RatePolicy{}, fmt.Errorf("rate %q: unit must be s, m or h", parts[0])
+	}
+
+	policy := RatePolicy{Limit: domain.RateLimit{Rate: rate, Period: period, Burst: rate}, Key: key}
+	for _, option := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch name {
+		case "burst":
+			if policy.Limit.Burst, err = strconv.Atoi(value); err != nil || policy.Limit.Burst <= 0 {
+				return RatePolicy{}, fmt.Errorf("burst %q must be a positive integer", value)
+			}
+		case "key":
+			if value != KeyIP && value != KeyClient && value != KeyAccount {
+				return RatePolicy{}, fmt.Errorf("key %q must be ip, client or account", value)
+			}
+			policy.Key = value
+		default:
+			return RatePolicy{}, fmt.Errorf("unknown option %q", option)
+		}
+	}
+	return policy, nil
+}
+
+// RateLimit limits requests by the policy of their route, answering 429 with
+// Retry-After once exhausted. Every response carries the X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (seconds) headers. Requests are
+// let through if the limiter is unavailable. The policies in force when a
+// request arrives apply to it. It goes after Authenticate, which identifies
+// the clients and accounts requests are counted for.
+func RateLimit(limiter RateLimiter, policySet *RatePolicySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policies := policySet.Get()
+		name := c.Request.Method + " " + c.FullPath()
+		policy, ok := policies.Routes[name]
+		if !ok {
+			name, policy = "*", policies.Default
+		}
+
+		key := name + ":" + policy.Key + ":" + rateLimitKey(c, policy.Key)
+		result, err := limiter.Allow(c.Request.Context(), key, policy.Limit)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "rate limiter unavailable, letting request through", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(policy.Limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		if !result.Allowed {
+			metrics.RateLimitRejections.WithLabelValues(name).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			problem.Write(c, domain.NewError(domain.ErrRateLimited, "rate_limited", "too many requests"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitKey identifies who the request is counted for. Clients are the
+// callers set by Authenticate, and accounts the one named by the account_id
+// path or query parameter when the caller may act on it, the caller
+// otherwise, so that no caller can spend the limit of another account.
+// Requests without an identified caller, unauthenticated or with
+// authentication disabled, are counted per IP.
+func rateLimitKey(c *gin.Context, kind string) string {
+	principal, ok := c.Value(principalContextKey).(*domain.Principal)
+	if !ok || principal == anonymous {
+		return c.ClientIP()
+	}
+	switch kind {
+	case KeyClient:
+		return principal.Subject
+	case KeyAccount:
+		accountID := c.Param("account_id")
+		if accountID == "" {
+			accountID = c.Query("account_id")
+		}
+		if accountID != "" && principal.CanAccess(accountID) {
+			return accountID
+		}
+		return principal.Subject
+	}
+	return c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRateLimiter struct {
+	mock.Mock
+}
+
+func (m *MockRateLimiter) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	args := m.Called(ctx, key, limit)
+	return args.Get(0).(domain.RateLimitResult), args.Error(1)
+}
+
+var defaultPolicy = RatePolicy{Limit: domain.RateLimit{Rate: 1000, Period: time.Second, Burst: 1000}, Key: KeyIP}
+
+func TestParseRatePolicies(t *testing.T) {
+	policies, err := ParseRatePolicies("POST /process-transactions = 10/m,burst=5,key=account; GET /statements/:id=2/s;*=100/h,key=client", defaultPolicy)
+	require.NoError(t, err)
+
+	assert.Equal(t, RatePolicy{Limit: domain.RateLimit{Rate: 10, Period: time.Minute, Burst: 5}, Key: KeyAccount}, policies.Routes["POST /process-transactions"])
+	assert.Equal(t, RatePolicy{Limit: domain.RateLimit{Rate: 2, Period: time.Second, Burst: 2}, Key: KeyIP}, policies.Routes["GET /statements/:id"])
+	assert.Equal(t, RatePolicy{Limit: domain.RateLimit{Rate: 100, Period: time.Hour, Burst: 100}, Key: KeyClient}, policies.Default)
+
+	for _, spec := range []string{"POST /x", "POST /x=10", "POST /x=0/s", "POST /x=1/d", "POST /x=1/s,key=user", "POST /x=1/s,burst=-1", "POST /x=1/s,foo=1"} {
+		_, err := ParseRatePolicies(spec, defaultPolicy)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policies, err := ParseRatePolicies("POST /process-transactions=10/m,key=account", defaultPolicy)
+	require.NoError(t, err)
+
+	caller := &domain.Principal{Subject: "api_key:key-1", Scopes: []string{domain.ScopeIngest}, Accounts: []string{"acc-1"}}
+	newRouter := func(limiter *MockRateLimiter, policySet ...*RatePolicySet) *gin.Engine {
+		if len(policySet) == 0 {
+			policySet = append(policySet, NewRatePolicySet(policies))
+		}
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if c.GetHeader("Authorization") != "" {
+				setPrincipal(c, caller)
+			}
+		}, RateLimit(limiter, policySet[0]))
+		router.POST("/process-transactions", func(c *gin.Context) { c.Status(http.StatusAccepted) })
+		router.GET("/jobs/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		limiter := new(MockRateLimiter)
+		limiter.On("Allow", mock.Anything, "POST /process-transactions:account:acc-1", policies.Routes["POST /process-transactions"].Limit).
+			Return(domain.RateLimitResult{Allowed: true, Remaining: 9, ResetAfter: 6 * time.Second}, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/process-transactions?account_id=acc-1", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		newRouter(limiter).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "10", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "9", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "6", w.Header().Get("X-RateLimit-Reset"))
+		assert.Empty(t, w.Header().Get("Retry-After"))
+		limiter.AssertExpectations(t)
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		limiter := new(MockRateLimiter)
+		limiter.On("Allow", mock.Anything, "*:ip:192.0.2.1", defaultPolicy.Limit).
+			Return(domain.RateLimitResult{Allowed: false, RetryAfter: 1500 * time.Millisecond, ResetAfter: time.Second}, nil)
+
+		rejections := testutil.ToFloat64(metrics.RateLimitRejections.WithLabelValues("*"))
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		newRouter(limiter).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, rejections+1, testutil.ToFloat64(metrics.RateLimitRejections.WithLabelValues("*")))
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+		assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"too many requests","instance":"/jobs/job-1","code":"rate_limited"}`, w.Body.String())
+	})
+
+	t.Run("keyed by caller", func(t *testing.T) {
+		clientPolicies, err := ParseRatePolicies("GET /jobs/:id=10/m,key=client", defaultPolicy)
+		require.NoError(t, err)
+		limiter := new(MockRateLimiter)
+		limiter.On("Allow", mock.Anything, "GET /jobs/:id:client:api_key:key-1", mock.Anything).Return(domain.RateLimitResult{Allowed: true}, nil).Once()
+		limiter.On("Allow", mock.Anything, "POST /process-transactions:account:api_key:key-1", mock.Anything).Return(domain.RateLimitResult{Allowed: true}, nil).Once()
+		limiter.On("Allow", mock.Anything, "GET /jobs/:id:client:192.0.2.1", mock.Anything).Return(domain.RateLimitResult{Allowed: true}, nil).Once()
+		limiter.On("Allow", mock.Anything, "POST /process-transactions:account:192.0.2.1", mock.Anything).Return(domain.RateLimitResult{Allowed: true}, nil).Once()
+
+		requests := []struct {
+			method, path string
+			authorized   bool
+		}{
+			{http.MethodGet, "/jobs/job-1", true},
+			// Another account's limit is not spent by a caller who may not act on it.
+			{http.MethodPost, "/process-transactions?account_id=acc-2", true},
+			{http.MethodGet, "/jobs/job-1", false},
+			{http.MethodPost, "/process-transactions?account_id=acc-1", false},
+		}
+		policySet := NewRatePolicySet(RatePolicies{Default: defaultPolicy, Routes: map[string]RatePolicy{
+			"GET /jobs/:id":              clientPolicies.Routes["GET /jobs/:id"],
+			"POST /process-transactions": policies.Routes["POST /process-transactions"],
+		}})
+		for _, r := range requests {
+			req, _ := http.NewRequest(r.method, r.path, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-API-Key", "spoofed")
+			if r.authorized {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			newRouter(limiter, policySet).ServeHTTP(httptest.NewRecorder(), req)
+		}
+		limiter.AssertExpectations(t)
+	})
+
+	t.Run("limiter unavailable", func(t *testing.T) {
+		limiter := new(MockRateLimiter)
+		limiter.On("Allow", mock.Anything, mock.Anything, mock.Anything).Return(domain.RateLimitResult{}, errors.New("redis: connection refused"))
+
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+		w := httptest.NewRecorder()
+		newRouter(limiter).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("policies replaced", func(t *testing.T) {
+		policySet := NewRatePolicySet(policies)
+		reloaded := RatePolicy{Limit: domain.RateLimit{Rate: 1, Period: time.Second, Burst: 3}, Key: KeyIP}
+		limiter := new(MockRateLimiter)
+		limiter.On("Allow", mock.Anything, "*:ip:192.0.2.1", reloaded.Limit).
+			Return(domain.RateLimitResult{Allowed: true, Remaining: 2}, nil)
+		router := newRouter(limiter, policySet)
+
+		policySet.Set(RatePolicies{Default: reloaded})
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+		limiter.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	router := gin.New()
+	router.Use(Tracing())
+	router.GET("/jobs/:id", func(c *gin.Context) {
+		assert.True(t, trace.SpanContextFromContext(c.Request.Context()).IsValid(), "handlers get the span")
+		c.Status(http.StatusOK)
+	})
+	router.POST("/process-transactions", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+
+	req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get("X-Trace-Id"))
+
+	req, _ = http.NewRequest(http.MethodPost, "/process-transactions", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "GET /jobs/:id", spans[0].Name)
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String(), "the caller's trace is continued")
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", http.StatusOK))
+	assert.Equal(t, "POST /process-transactions", spans[1].Name)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+}
@@ -0,0 +1,152 @@
+// Package openapi embeds the OpenAPI document of the API and validates
+// requests and responses against it.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/interface/api/problem"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+func init() {
+	// Keep validation errors to the failing value, without the schema.
+	openapi3.SchemaErrorDetailsDisabled = true
+	openapi3filter.RegisterBodyDecoder("text/html", func(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+		data, err := io.ReadAll(body)
+		return string(data), err
+	})
+}
+
+// Load parses and validates the embedded document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(specYAML)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Route returns the operation of doc serving the gin route (e.g.
+// "/statements/:statement_id"), or nil if doc does not document it.
+func Route(doc *openapi3.T, method, ginPath string) *routers.Route {
+	path := ginParam.ReplaceAllString(ginPath, "{$1}")
+	item := doc.Paths.Value(path)
+	if item == nil {
+		return nil
+	}
+	operation := item.GetOperation(method)
+	if operation == nil {
+		return nil
+	}
+	return &routers.Route{Spec: doc, Path: path, PathItem: item, Method: method, Operation: operation}
+}
+
+// ValidateRequests answers 400 to requests whose parameters or body do not
+// match their operation in doc. The problem code is invalid_<parameter> for
+// a parameter, e.g. invalid_period, and invalid_body for the body. Routes
+// missing from doc are let through. Authentication is left to the
+// authentication middleware.
+func ValidateRequests(doc *openapi3.T) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input := requestInput(c, doc)
+		if input == nil {
+			c.Next()
+			return
+		}
+		err := openapi3filter.ValidateRequest(c.Request.Context(), input)
+		var requestErr *openapi3filter.RequestError
+		switch {
+		case err == nil:
+			c.Next()
+		case errors.As(err, &requestErr) && requestErr.Parameter != nil:
+			name := strings.ToLower(strings.ReplaceAll(requestErr.Parameter.Name, "-", "_"))
+			problem.InvalidInput(c, "invalid_"+name, requestErr.Error())
+		case errors.As(err, &requestErr) && requestErr.RequestBody != nil:
+			problem.InvalidInput(c, "invalid_body", requestErr.Error())
+		default:
+			problem.InvalidInput(c, "invalid_request", err.Error())
+		}
+	}
+}
+
+// ValidateResponses checks every response to a documented route against
+// doc, including that its status is documented, and passes mismatches to
+// report. Responses are sent unchanged.
+func ValidateResponses(doc *openapi3.T, report func(c *gin.Context, err error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input := requestInput(c, doc)
+		if input == nil {
+			c.Next()
+			return
+		}
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		response := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.Status(),
+			Header:                 recorder.Header(),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		response.SetBodyBytes(recorder.body.Bytes())
+		if err := openapi3filter.ValidateResponse(c.Request.Context(), response); err != nil {
+			report(c, err)
+		}
+	}
+}
+
+func requestInput(c *gin.Context, doc *openapi3.T) *openapi3filter.RequestValidationInput {
+	route := Route(doc, c.Request.Method, c.FullPath())
+	if route == nil {
+		return nil
+	}
+	params := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = param.Value
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request:    c.Request,
+		PathParams: params,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			SkipSettingDefaults: true,
+		},
+	}
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,72 @@
+package problem
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+)
+
+// Details is an RFC 9457 problem details body. Type is always about:blank,
+// so Title is the status text; Code is a stable, machine-readable error code.
+type Details struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+var kinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{domain.ErrInvalidInput, http.StatusBadRequest, "invalid_input"},
+	{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{domain.ErrNotFound, http.StatusNotFound, "not_found"},
+	{domain.ErrSourceNotFound, http.StatusNotFound, "source_not_found"},
+	{domain.ErrConflict, http.StatusConflict, "conflict"},
+	{domain.ErrUnprocessable, http.StatusUnprocessableEntity, "unprocessable"},
+	{domain.ErrRateLimited, http.StatusTooManyRequests, "rate_limited"},
+	{domain.ErrUnavailable, http.StatusServiceUnavailable, "unavailable"},
+}
+
+// Write aborts the request with the problem details for err. Errors of an
+// unknown kind answer 500 without exposing their text, which is logged.
+func Write(c *gin.Context, err error) {
+	status, code, detail := http.StatusInternalServerError, "internal_error", "internal server error"
+	for _, k := range kinds {
+		if errors.Is(err, k.kind) {
+			status, code, detail = k.status, k.code, k.kind.Error()
+			break
+		}
+	}
+
+	var domainErr *domain.Error
+	if status != http.StatusInternalServerError && errors.As(err, &domainErr) {
+		code, detail = domainErr.Code, domainErr.Message
+	}
+	if status == http.StatusInternalServerError || status == http.StatusServiceUnavailable {
+		slog.ErrorContext(c.Request.Context(), "request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "status", status, "error", err)
+	}
+
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, Details{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+	})
+}
+
+// InvalidInput aborts the request with a 400 problem.
+func InvalidInput(c *gin.Context, code, detail string) {
+	Write(c, domain.NewError(domain.ErrInvalidInput, code, detail))
+}
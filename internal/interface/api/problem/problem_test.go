@@ -0,0 +1,60 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"typed", domain.NewError(domain.ErrInvalidInput, "invalid_period", "period must be formatted as YYYY-MM"), http.StatusBadRequest, "invalid_period", "period must be formatted as YYYY-MM"},
+		{"wrapped typed", fmt.Errorf("submitting: %w", domain.ErrRunInProgress), http.StatusConflict, "run_in_progress", "a run for this account is already in progress"},
+		{"kind only", fmt.Errorf("job 7: %w", domain.ErrNotFound), http.StatusNotFound, "not_found", "not found"},
+		{"source not found", domain.WrapError(domain.ErrSourceNotFound, "source_not_found", "transaction source not found", errors.New("open /srv/data/transactions.csv: no such file or directory")), http.StatusNotFound, "source_not_found", "transaction source not found"},
+		{"rate limited", domain.ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "rate limited"},
+		{"unavailable", domain.WrapError(domain.ErrUnavailable, "queue_unavailable", "the job could not be queued", errors.New("dial tcp 10.0.0.7:6379: connection refused")), http.StatusServiceUnavailable, "queue_unavailable", "the job could not be queued"},
+		{"unknown", errors.New(`pq: relation "jobs" does not exist`), http.StatusInternalServerError, "internal_error", "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/things/:id", func(c *gin.Context) { Write(c, tt.err) })
+
+			req, _ := http.NewRequest(http.MethodGet, "/things/7", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+			var details Details
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+			assert.Equal(t, Details{
+				Type:     "about:blank",
+				Title:    http.StatusText(tt.status),
+				Status:   tt.status,
+				Detail:   tt.detail,
+				Instance: "/things/7",
+				Code:     tt.code,
+			}, details)
+		})
+	}
+}
@@ -2,11 +2,70 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
 	"github.com/jordanlanch/stori-test/internal/interface/api/controller"
+	"github.com/jordanlanch/stori-test/internal/interface/api/middleware"
 )
 
-func SetupRouter(transactionController *controller.TransactionController) *gin.Engine {
-	r := gin.Default()
-	r.POST("/process-transactions", transactionController.ProcessTransactions)
+// Controllers are the handlers of every route.
+type Controllers struct {
+	Transaction *controller.TransactionController
+	Job         *controller.JobController
+	Statement   *controller.StatementController
+	Schedule    *controller.ScheduleController
+	APIKey      *controller.APIKeyController
+	Account     *controller.AccountController
+	Spec        *controller.SpecController
+	Health      *controller.HealthController
+	Metrics     *controller.MetricsController
+	Config      *controller.ConfigController
+}
+
+// SetupRouter serves the liveness and readiness probes at /healthz and
+// /readyz and the Prometheus metrics at /metrics ahead of every middleware,
+// so they are never rate limited nor counted. The OpenAPI document at
+// /openapi.json is only rate limited. The other routes go through
+// authenticate, then rateLimit, which counts authenticated requests per
+// caller and the others, failed authentications included, per IP, and only
+// then are unauthenticated requests answered 401. Each route requires the
+// scope of what it does: ingest for processing and sending, read for
+// queries and admin for key management and configuration reloads.
+// Transaction and summary routes are restricted to the caller's accounts,
+// defaultAccount being the one of requests without account_id. Requests
+// that send statements go through idempotent, so they can be retried with
+// an Idempotency-Key. Panics on any route are logged and answered with a
+// 500 problem.
+func SetupRouter(controllers Controllers, authenticate, rateLimit, idempotent gin.HandlerFunc, defaultAccount string, middlewares ...gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.Recovery())
+	r.GET("/healthz", controllers.Health.Live)
+	r.GET("/readyz", controllers.Health.Ready)
+	r.GET("/metrics", controllers.Metrics.GetMetrics)
+	r.Use(middlewares...)
+	r.GET("/openapi.json", rateLimit, controllers.Spec.GetSpec)
+	r.Use(authenticate, rateLimit, middleware.RequireAuthenticated())
+
+	ingest := middleware.RequireScope(domain.ScopeIngest)
+	read := middleware.RequireScope(domain.ScopeRead)
+	admin := middleware.RequireScope(domain.ScopeAdmin)
+	ownAccount := middleware.RequireAccount(defaultAccount)
+	ownAccounts := middleware.RequireAccountFilter()
+
+	r.POST("/process-transactions", ingest, ownAccount, idempotent, controllers.Transaction.ProcessTransactions)
+	r.GET("/jobs/:id", read, controllers.Job.GetJob)
+	r.GET("/accounts/:account_id/transactions", read, ownAccount, controllers.Account.ListTransactions)
+	r.GET("/accounts/:account_id/summary", read, ownAccount, controllers.Account.GetSummary)
+	r.GET("/statements/preview", read, ownAccount, controllers.Statement.PreviewAccountStatement)
+	r.POST("/statements/preview", read, controllers.Statement.PreviewCSVStatement)
+	r.GET("/statements", read, ownAccounts, controllers.Statement.ListStatements)
+	r.GET("/statements/:statement_id", read, controllers.Statement.GetStatement)
+	r.POST("/statements/:statement_id/resend", ingest, idempotent, controllers.Statement.ResendStatement)
+	r.GET("/schedule/runs", read, controllers.Schedule.ListRuns)
+
+	r.POST("/admin/api-keys", admin, controllers.APIKey.CreateKey)
+	r.GET("/admin/api-keys", admin, controllers.APIKey.ListKeys)
+	r.POST("/admin/api-keys/:id/rotate", admin, controllers.APIKey.RotateKey)
+	r.DELETE("/admin/api-keys/:id", admin, controllers.APIKey.RevokeKey)
+	r.POST("/admin/config/reload", admin, controllers.Config.ReloadConfig)
 	return r
 }
@@ -0,0 +1,455 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/core/usecase"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
+	"github.com/jordanlanch/stori-test/internal/interface/api/controller"
+	"github.com/jordanlanch/stori-test/internal/interface/api/middleware"
+	"github.com/jordanlanch/stori-test/internal/interface/api/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 7, 1, 6, 0, 0, 0, time.UTC)
+
+// Stub use cases answering with fixed, fully populated records, or with
+// not found errors for the ID "missing".
+
+type stubJobs struct{}
+
+func (stubJobs) SubmitProcessing(ctx context.Context, req domain.ProcessRequest) (*domain.Job, error) {
+	return &domain.Job{ID: "job-1", State: domain.JobStateQueued}, nil
+}
+
+func (stubJobs) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	if id == "missing" {
+		return nil, domain.NewError(domain.ErrNotFound, "job_not_found", "job not found")
+	}
+	statementID := 1
+	return &domain.Job{
+		ID: id, AccountID: "acc-1", Period: "2024-06", State: domain.JobStateSucceeded,
+		Stages:   []domain.StageTiming{{Name: "hash", StartedAt: now, DurationMs: 3}},
+		RowsRead: 3, RowsSaved: 3, StatementID: &statementID, Attempts: 1,
+		CreatedAt: now, StartedAt: &now, FinishedAt: &now,
+	}, nil
+}
+
+func (s stubJobs) ListJobs(ctx context.Context, filter domain.JobFilter) ([]domain.Job, error) {
+	job, _ := s.GetJob(ctx, "job-1")
+	return []domain.Job{*job}, nil
+}
+
+func (stubJobs) RunJob(ctx context.Context, id string) error     { return nil }
+func (stubJobs) AbandonJob(ctx context.Context, id string) error { return nil }
+
+type stubStatements struct{}
+
+func (stubStatements) PreviewStatement(ctx context.Context, req domain.PreviewRequest) (*domain.RenderedEmail, error) {
+	return &domain.RenderedEmail{To: "client@example.com", Subject: "Your statement", HTML: "<p>Balance</p>", Text: "Balance"}, nil
+}
+
+func (stubStatements) GetStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	if id == 404 {
+		return nil, domain.NewError(domain.ErrNotFound, "statement_not_found", "statement not found")
+	}
+	return &domain.Statement{
+		ID: id, AccountID: "acc-1", Recipient: "client@example.com", Subject: "Your statement", Period: "2024-06",
+		Summary: map[string]interface{}{"TotalBalance": 39.74}, HTMLBody: "<p>Balance</p>", TextBody: "Balance",
+		BodyHash: "abc", MessageID: "<1@example.com>", Status: domain.StatementStatusSent, CreatedAt: now, SentAt: &now,
+	}, nil
+}
+
+func (s stubStatements) ListStatements(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	statement, _ := s.GetStatement(ctx, 1)
+	return []domain.Statement{*statement}, nil
+}
+
+func (s stubStatements) ResendStatement(ctx context.Context, id int) (*domain.Statement, error) {
+	statement, err := s.GetStatement(ctx, id)
+	if err == nil {
+		statement.ResentFromID = &id
+	}
+	return statement, err
+}
+
+func (s stubStatements) SendStatement(ctx context.Context, accountID, period string) (*domain.Statement, error) {
+	return s.GetStatement(ctx, 1)
+}
+
+func (s stubStatements) RedriveFailed(ctx context.Context, filter domain.StatementFilter) ([]domain.Statement, error) {
+	return s.ListStatements(ctx, filter)
+}
+
+type stubSchedule struct{}
+
+func (stubSchedule) RunDue(ctx context.Context, now time.Time) ([]domain.ScheduleRun, error) {
+	return nil, nil
+}
+
+func (stubSchedule) ListRuns(ctx context.Context, limit int) ([]domain.ScheduleRun, error) {
+	return []domain.ScheduleRun{{ID: 1, AccountID: "acc-1", Period: "2024-06", ScheduledFor: now, JobID: "job-1", CreatedAt: now}}, nil
+}
+
+type stubAPIKeys struct{}
+
+func (stubAPIKeys) CreateKey(ctx context.Context, req domain.APIKeyRequest) (*domain.IssuedAPIKey, error) {
+	return &domain.IssuedAPIKey{APIKey: domain.APIKey{ID: "key-1", Name: req.Name, Prefix: "stori_0123abcd", Scopes: req.Scopes, CreatedAt: now}, Key: "stori_0123abcdef"}, nil
+}
+
+func (stubAPIKeys) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return []domain.APIKey{{ID: "key-1", Name: "reports", Prefix: "stori_0123abcd", Scopes: []string{domain.ScopeRead}, CreatedAt: now, ExpiresAt: &now}}, nil
+}
+
+func (stubAPIKeys) RotateKey(ctx context.Context, id string) (*domain.IssuedAPIKey, error) {
+	if id == "missing" {
+		return nil, domain.NewError(domain.ErrNotFound, "api_key_not_found", "API key not found")
+	}
+	return &domain.IssuedAPIKey{APIKey: domain.APIKey{ID: "key-2", Name: "reports", Prefix: "stori_4567abcd", Scopes: []string{domain.ScopeRead}, RotatedFrom: id, CreatedAt: now}, Key: "stori_4567abcdef"}, nil
+}
+
+func (stubAPIKeys) RevokeKey(ctx context.Context, id string) error { return nil }
+
+func (stubAPIKeys) Authenticate(ctx context.Context, key string) (*domain.APIKey, error) {
+	return nil, domain.NewError(domain.ErrUnauthorized, "invalid_api_key", "invalid, expired or revoked API key")
+}
+
+func (stubAPIKeys) EnsureKey(ctx context.Context, name, key string, scopes []string) error {
+	return nil
+}
+
+type stubAccounts struct{}
+
+func (stubAccounts) ListTransactions(ctx context.Context, accountID, period string) ([]domain.Transaction, error) {
+	return []domain.Transaction{{ID: 1, AccountID: accountID, Date: "6/15", Amount: 60.5}}, nil
+}
+
+func (stubAccounts) GetSummary(ctx context.Context, accountID, period string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"TotalBalance": 60.5,
+		"MonthlyData": []map[string]interface{}{{
+			"Month": "June", "Transactions": 1, "DebitSum": 0.0, "DebitCount": 0,
+			"CreditSum": 60.5, "CreditCount": 1, "AverageDebit": 0.0, "AverageCredit": 60.5,
+		}},
+	}, nil
+}
+
+type stubHealth struct{}
+
+func (stubHealth) Readiness(ctx context.Context) domain.HealthReport {
+	return domain.HealthReport{Status: domain.HealthReady, Dependencies: map[string]domain.DependencyStatus{
+		"postgres": {Status: domain.DependencyUp, Required: true, LatencyMs: 1},
+	}}
+}
+
+type stubReloader struct{}
+
+func (stubReloader) Reload(ctx context.Context, trigger string) (*domain.ConfigReload, error) {
+	return &domain.ConfigReload{Changed: []string{"RATE_LIMIT"}, RestartRequired: []string{"DB_HOST"}}, nil
+}
+
+// Stub repositories for the use cases that check which accounts the caller
+// may see, holding the records of the stub use cases above.
+
+type stubJobRepo struct{ stubJobs }
+
+func (stubJobRepo) CreateJob(ctx context.Context, job *domain.Job) error { return nil }
+
+func (stubJobRepo) UpdateJob(ctx context.Context, job *domain.Job) error { return nil }
+
+type stubStatementRepo struct{ stubStatements }
+
+func (stubStatementRepo) CreateStatement(ctx context.Context, statement *domain.Statement) error {
+	return nil
+}
+
+func (stubStatementRepo) UpdateStatement(ctx context.Context, statement *domain.Statement) error {
+	return nil
+}
+
+type stubRunRepo struct{}
+
+func (stubRunRepo) CreateRun(ctx context.Context, run *domain.ScheduleRun) error { return nil }
+
+func (stubRunRepo) UpdateRun(ctx context.Context, run *domain.ScheduleRun) error { return nil }
+
+func (stubRunRepo) LatestRun(ctx context.Context) (*domain.ScheduleRun, error) {
+	return nil, domain.ErrNotFound
+}
+
+func (stubRunRepo) ListRuns(ctx context.Context, filter domain.ScheduleRunFilter) ([]domain.ScheduleRun, error) {
+	runs := []domain.ScheduleRun{}
+	for _, accountID := range []string{"acc-1", "acc-2"} {
+		if filter.Accounts == nil || slices.Contains(filter.Accounts, accountID) {
+			runs = append(runs, domain.ScheduleRun{ID: len(runs) + 1, AccountID: accountID, Period: "2024-06", ScheduledFor: now, CreatedAt: now})
+		}
+	}
+	return runs, nil
+}
+
+type stubEmail struct{}
+
+func (stubEmail) SendEmail(ctx context.Context, email *domain.RenderedEmail) (string, error) {
+	return "<2@example.com>", nil
+}
+
+func (stubEmail) RenderEmail(templatePath string, data interface{}) (*domain.RenderedEmail, error) {
+	return nil, nil
+}
+
+// stubTokens verifies any bearer token as a caller restricted to the account
+// named by the token.
+type stubTokens struct{}
+
+func (stubTokens) Verify(ctx context.Context, token string) (*domain.Principal, error) {
+	return &domain.Principal{Subject: token, Scopes: []string{domain.ScopeRead, domain.ScopeIngest}, Accounts: []string{token}}, nil
+}
+
+// stubControllers returns the controllers over the stub use cases.
+func stubControllers(t *testing.T, doc *openapi3.T) Controllers {
+	t.Helper()
+	document, err := doc.MarshalJSON()
+	require.NoError(t, err)
+	return Controllers{
+		Transaction: &controller.TransactionController{UseCase: stubJobs{}},
+		Job:         &controller.JobController{UseCase: stubJobs{}},
+		Statement:   &controller.StatementController{UseCase: stubStatements{}},
+		Schedule:    &controller.ScheduleController{UseCase: stubSchedule{}},
+		APIKey:      &controller.APIKeyController{UseCase: stubAPIKeys{}},
+		Account:     &controller.AccountController{UseCase: stubAccounts{}},
+		Spec:        &controller.SpecController{Document: document},
+		Health:      &controller.HealthController{UseCase: stubHealth{}},
+		Metrics:     &controller.MetricsController{Handler: metrics.Handler()},
+		Config:      &controller.ConfigController{Reloader: stubReloader{}},
+	}
+}
+
+// newContractRouter builds the router over the stub use cases, validating
+// requests and reporting responses that do not match the OpenAPI document.
+// Overrides replace some of the controllers before the routes are set up.
+func newContractRouter(t *testing.T, authenticate gin.HandlerFunc, overrides ...func(*Controllers)) *gin.Engine {
+	t.Helper()
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+
+	controllers := stubControllers(t, doc)
+	for _, override := range overrides {
+		override(&controllers)
+	}
+	report := openapi.ValidateResponses(doc, func(c *gin.Context, err error) {
+		t.Errorf("%s %s: response does not match the OpenAPI document: %v", c.Request.Method, c.Request.URL, err)
+	})
+	next := func(c *gin.Context) { c.Next() }
+	return SetupRouter(controllers, authenticate, next, next, "default", report, openapi.ValidateRequests(doc))
+}
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+	router := newContractRouter(t, middleware.Anonymous())
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+		assert.NotNil(t, openapi.Route(doc, route.Method, route.Path), "%s %s is not in the OpenAPI document", route.Method, route.Path)
+	}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			ginPath := strings.NewReplacer("{", ":", "}", "").Replace(path)
+			assert.True(t, registered[method+" "+ginPath], "%s %s is documented but not routed", method, path)
+		}
+	}
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newContractRouter(t, middleware.Anonymous())
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/openapi.json", "", http.StatusOK},
+		{http.MethodGet, "/healthz", "", http.StatusOK},
+		{http.MethodGet, "/readyz", "", http.StatusOK},
+		{http.MethodGet, "/metrics", "", http.StatusOK},
+		{http.MethodPost, "/process-transactions?account_id=acc-1", "", http.StatusAccepted},
+		{http.MethodGet, "/jobs/job-1", "", http.StatusOK},
+		{http.MethodGet, "/jobs/missing", "", http.StatusNotFound},
+		{http.MethodGet, "/accounts/acc-1/transactions?period=2024-06", "", http.StatusOK},
+		{http.MethodGet, "/accounts/acc-1/summary", "", http.StatusOK},
+		{http.MethodGet, "/accounts/acc-1/summary?period=June", "", http.StatusBadRequest},
+		{http.MethodGet, "/statements/preview?account_id=acc-1", "", http.StatusOK},
+		{http.MethodGet, "/statements/preview?format=text", "", http.StatusOK},
+		{http.MethodGet, "/statements/preview?format=json", "", http.StatusOK},
+		{http.MethodGet, "/statements/preview?format=xml", "", http.StatusBadRequest},
+		{http.MethodPost, "/statements/preview?format=json", "ID,Date,Transaction\n0,7/15,+60.5\n", http.StatusOK},
+		{http.MethodGet, "/statements?account_id=acc-1&status=sent&limit=10", "", http.StatusOK},
+		{http.MethodGet, "/statements?limit=-1", "", http.StatusBadRequest},
+		{http.MethodGet, "/statements/1", "", http.StatusOK},
+		{http.MethodGet, "/statements/404", "", http.StatusNotFound},
+		{http.MethodGet, "/statements/first", "", http.StatusBadRequest},
+		{http.MethodPost, "/statements/1/resend", "", http.StatusOK},
+		{http.MethodGet, "/schedule/runs?limit=12", "", http.StatusOK},
+		{http.MethodPost, "/admin/api-keys", `{"name":"reports","scopes":["read"],"ttl_sec":60}`, http.StatusCreated},
+		{http.MethodPost, "/admin/api-keys", `{"name":"reports","scopes":["write"]}`, http.StatusBadRequest},
+		{http.MethodGet, "/admin/api-keys", "", http.StatusOK},
+		{http.MethodPost, "/admin/api-keys/key-1/rotate", "", http.StatusCreated},
+		{http.MethodPost, "/admin/api-keys/missing/rotate", "", http.StatusNotFound},
+		{http.MethodDelete, "/admin/api-keys/key-1", "", http.StatusNoContent},
+		{http.MethodPost, "/admin/config/reload", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		switch {
+		case strings.HasPrefix(tt.body, "{"):
+			req.Header.Set("Content-Type", "application/json")
+		case tt.body != "":
+			req.Header.Set("Content-Type", "text/csv")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, "%s %s: %s", tt.method, tt.path, w.Body.String())
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newContractRouter(t, middleware.Anonymous())
+
+	tests := []struct {
+		path string
+		code string
+	}{
+		{"/statements/preview?format=xml", "invalid_format"},
+		{"/statements?period=2024-13", "invalid_period"},
+		{"/statements/first", "invalid_statement_id"},
+		{"/schedule/runs?limit=many", "invalid_limit"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tt.path)
+		assert.Contains(t, w.Body.String(), `"code":"`+tt.code+`"`, tt.path)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/process-transactions", nil)
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_idempotency_key"`)
+}
+
+func TestAuthenticationErrorsMatchOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newContractRouter(t, middleware.Authenticate(stubAPIKeys{}, nil))
+
+	req, _ := http.NewRequest(http.MethodGet, "/statements", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The document and the probes are public.
+	for _, path := range []string{"/openapi.json", "/healthz", "/readyz", "/metrics"} {
+		req, _ = http.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestOtherAccountsRecordsAreNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newContractRouter(t, middleware.Authenticate(nil, stubTokens{}), func(controllers *Controllers) {
+		controllers.Job.UseCase = usecase.NewJobUseCase(stubJobRepo{}, nil, nil, 60, 1, "default")
+		controllers.Statement.UseCase = usecase.NewStatementUseCase(nil, stubStatementRepo{}, stubEmail{}, "default")
+		controllers.Schedule.UseCase = usecase.NewScheduleUseCase(stubRunRepo{}, nil, nil, domain.NewBusinessCalendar(nil), nil, 0, now)
+	})
+
+	tests := []struct {
+		method  string
+		path    string
+		account string
+		status  int
+	}{
+		{http.MethodGet, "/jobs/job-1", "acc-1", http.StatusOK},
+		{http.MethodGet, "/jobs/job-1", "acc-2", http.StatusNotFound},
+		{http.MethodGet, "/statements/1", "acc-1", http.StatusOK},
+		{http.MethodGet, "/statements/1", "acc-2", http.StatusNotFound},
+		{http.MethodPost, "/statements/1/resend", "acc-1", http.StatusOK},
+		{http.MethodPost, "/statements/1/resend", "acc-2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.account)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, "%s %s as %s: %s", tt.method, tt.path, tt.account, w.Body.String())
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/schedule/runs", nil)
+	req.Header.Set("Authorization", "Bearer acc-2")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var runs []domain.ScheduleRun
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, "acc-2", runs[0].AccountID)
+}
+
+// keyRecorder allows every request, recording the keys it counted them for.
+type keyRecorder struct{ keys []string }
+
+func (r *keyRecorder) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	r.keys = append(r.keys, key)
+	return domain.RateLimitResult{Allowed: true}, nil
+}
+
+func TestRateLimitCountsCallersAndFailedAuthentications(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+	limiter := &keyRecorder{}
+	policies := middleware.NewRatePolicySet(middleware.RatePolicies{
+		Default: middleware.RatePolicy{Limit: domain.RateLimit{Rate: 10, Period: time.Second, Burst: 10}, Key: middleware.KeyClient},
+	})
+	next := func(c *gin.Context) { c.Next() }
+	router := SetupRouter(stubControllers(t, doc), middleware.Authenticate(nil, stubTokens{}), middleware.RateLimit(limiter, policies), next, "default")
+
+	for _, token := range []string{"acc-1", "acc-2", ""} {
+		req, _ := http.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if token == "" {
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The unauthenticated request is counted per IP before it is rejected,
+	// as is the public document.
+	assert.Equal(t, []string{"*:client:acc-1", "*:client:acc-2", "*:client:192.0.2.1", "*:client:192.0.2.1"}, limiter.keys)
+}
@@ -2,11 +2,14 @@ package csv
 
 import (
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"sync"
 
 	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/metrics"
 )
 
 type CSVReaderInterface interface {
@@ -28,17 +31,31 @@ func (r *CSVReader) ReadTransactions() ([]domain.Transaction, error) {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	return ParseTransactions(file)
+}
+
+// ParseTransactions parses a transactions CSV (ID,Date,Transaction with a
+// header row) from any reader, e.g. an uploaded file. Rows with fewer than
+// three fields are rejected.
+func ParseTransactions(in io.Reader) ([]domain.Transaction, error) {
+	reader := csv.NewReader(in)
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, err
 	}
+	if len(records) < 2 {
+		return []domain.Transaction{}, nil
+	}
 
 	var wg sync.WaitGroup
 	transactions := make([]domain.Transaction, len(records)-1)
 	errors := make(chan error, len(records)-1)
 
 	for i, record := range records[1:] { // Skip header
+		if len(record) < 3 {
+			errors <- fmt.Errorf("row %d: expected 3 fields, got %d", i+2, len(record))
+			continue
+		}
 		wg.Add(1)
 		go func(i int, record []string) {
 			defer wg.Done()
@@ -63,11 +80,12 @@ func (r *CSVReader) ReadTransactions() ([]domain.Transaction, error) {
 	wg.Wait()
 	close(errors)
 
-	for err := range errors {
-		if err != nil {
-			return nil, err
-		}
+	if rejected := len(errors); rejected > 0 {
+		metrics.CSVRows.WithLabelValues("rejected").Add(float64(rejected))
+		metrics.CSVRows.WithLabelValues("parsed").Add(float64(len(transactions) - rejected))
+		return nil, <-errors
 	}
 
+	metrics.CSVRows.WithLabelValues("parsed").Add(float64(len(transactions)))
 	return transactions, nil
 }
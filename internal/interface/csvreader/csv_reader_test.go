@@ -0,0 +1,38 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jordanlanch/stori-test/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTransactions(t *testing.T) {
+	transactions, err := ParseTransactions(strings.NewReader("Id,Date,Transaction\n0,7/15,+60.5\n1,7/28,-10.3\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Transaction{
+		{ID: 0, Date: "7/15", Amount: 60.5},
+		{ID: 1, Date: "7/28", Amount: -10.3},
+	}, transactions)
+
+	transactions, err = ParseTransactions(strings.NewReader("Id,Date,Transaction\n"))
+	require.NoError(t, err)
+	assert.Empty(t, transactions)
+}
+
+func TestParseTransactions_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"short row":      "id\n1\n",
+		"empty row":      "id\n\"\"\n",
+		"invalid id":     "Id,Date,Transaction\nx,7/15,+60.5\n",
+		"invalid amount": "Id,Date,Transaction\n0,7/15,lots\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			transactions, err := ParseTransactions(strings.NewReader(body))
+			assert.Error(t, err)
+			assert.Nil(t, transactions)
+		})
+	}
+}
@@ -1,56 +1,130 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/app"
 	"github.com/jordanlanch/stori-test/internal/config"
-	"github.com/jordanlanch/stori-test/internal/core/usecase"
-	"github.com/jordanlanch/stori-test/internal/infrastructure/email"
-	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
-	"github.com/jordanlanch/stori-test/internal/interface/api/controller"
-	"github.com/jordanlanch/stori-test/internal/interface/api/router"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/logging"
+	"github.com/jordanlanch/stori-test/internal/infrastructure/tracing"
 )
 
 func main() {
-	env := config.NewEnv(".env")
+	// Log in JSON until the configured logger is set up.
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	env, err := config.Load(".env", os.Args[1:])
+	if errors.Is(err, config.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal("Failed to load the configuration", err)
+	}
 	if err := env.Validate(); err != nil {
-		log.Fatalf("Environment validation failed: %v", err)
+		fatal("Environment validation failed", err)
 	}
-
-	// Setup Redis
-	redisOptions := &redis.Options{
-		Addr: fmt.Sprintf("%s:%d", env.RedisHost, env.RedisPort),
-		DB:   0,
+	if err := logging.Setup(logging.Config{Level: env.LogLevel, Format: env.LogFormat, RedactPII: env.LogRedactPII}); err != nil {
+		fatal("Failed to set up logging", err)
 	}
 
-	if env.RedisPassword != "" {
-		redisOptions.Password = env.RedisPassword
+	// Stop taking new work on SIGINT or SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName: env.OTelServiceName,
+		Exporter:    env.OTelExporter,
+		Endpoint:    env.OTelEndpoint,
+		File:        env.OTelFile,
+		SampleRatio: env.OTelSampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
 	}
 
-	redisClient := redis.NewClient(redisOptions)
+	// New Redis and database connections and emails use the current
+	// passwords, so they can be rotated without a restart.
+	if env.SecretsRefresh > 0 {
+		go env.Secrets().Watch(ctx, time.Duration(env.SecretsRefresh)*time.Second)
+	}
 
-	// Setup Database
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		env.DBHost, env.DBUser, env.DBPassword, env.DBName, env.DBPort)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	server, err := app.NewServer(ctx, env, func() (*config.Env, error) { return config.Load(".env", os.Args[1:]) })
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to start", err)
 	}
 
-	// Setup Repository, Services, and UseCase
-	dbRepo := repository.NewDBTransactionRepository(db, env.CSVFilePath)
-	cacheRepo := repository.NewCacheTransactionRepository(redisClient, env.CacheDurationSec)
-	emailService := &email.EmailService{}
-	transactionUseCase := usecase.NewTransactionUseCase(dbRepo, cacheRepo, emailService, redisClient, env.RateLimit, env.RedisTimeoutSec, env.CacheDurationSec)
-	transactionController := &controller.TransactionController{
-		UseCase: transactionUseCase,
+	// The configuration is also reloaded on changes to the config file and
+	// on SIGHUP.
+	if err := server.Reloader.Watch(ctx, 500*time.Millisecond); err != nil {
+		slog.Warn("Not watching the config file", "error", err)
 	}
+	go reloadOnSignal(ctx, server.Reloader)
+
+	srv := &http.Server{Addr: env.ServerAddress, Handler: server.Handler}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	slog.Info("Listening", "address", env.ServerAddress)
 
-	// Setup Router
-	r := router.SetupRouter(transactionController)
-	r.Run(env.ServerAddress)
+	select {
+	case err := <-serveErr:
+		fatal("Server failed", err)
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("Shutting down", "drain_timeout_sec", env.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(env.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := shutdown(shutdownCtx, srv, server, shutdownTracing); err != nil {
+		slog.Error("Shutdown failed", "error", err)
+	}
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP until ctx is
+// done.
+func reloadOnSignal(ctx context.Context, reloader *config.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			// A rejected reload has been logged.
+			_, _ = reloader.Reload(ctx, "signal")
+		}
+	}
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// shutdown stops accepting requests and waits for the ones in flight, then
+// closes the server, draining the scheduler and the job workers, and
+// flushes pending spans.
+func shutdown(ctx context.Context, srv *http.Server, server *app.Server, shutdownTracing func(context.Context) error) error {
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("stopping server: %w", err))
+	}
+	if err := server.Close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flushing spans: %w", err))
+	}
+	return errors.Join(errs...)
 }
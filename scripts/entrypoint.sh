@@ -1,7 +1,7 @@
 #!/bin/sh
 
-# Ejecutar migraciones
-goose -dir /go/src/github.com/jordanlanch/stori-test/storage/migrations postgres "host=$DB_HOST user=$DB_USER password=$DB_PASSWORD dbname=$DB_NAME port=$DB_PORT sslmode=disable" up
+# Las migraciones, embebidas en el binario, se aplican al iniciar
+export MIGRATE_ON_START="${MIGRATE_ON_START:-true}"
 
 # Iniciar la aplicación
 exec wgo run -buildvcs=false main.go
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE statements (
+    id SERIAL PRIMARY KEY,
+    account_id VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    period VARCHAR(7) NOT NULL,
+    summary JSONB NOT NULL,
+    html_body TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    body_hash VARCHAR(64) NOT NULL,
+    message_id VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    resent_from_id INTEGER REFERENCES statements (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+);
+CREATE INDEX idx_statements_account_period ON statements (account_id, period);
+CREATE INDEX idx_statements_recipient ON statements (recipient);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE statements;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE jobs (
+    id VARCHAR(36) PRIMARY KEY,
+    account_id VARCHAR(64) NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    stages JSONB NOT NULL DEFAULT '[]',
+    rows_read INTEGER NOT NULL DEFAULT 0,
+    rows_saved INTEGER NOT NULL DEFAULT 0,
+    cache_hit BOOLEAN NOT NULL DEFAULT false,
+    statement_id INTEGER REFERENCES statements (id),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+CREATE INDEX idx_jobs_state ON jobs (state);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE jobs;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN period VARCHAR(7) NOT NULL DEFAULT '';
+
+CREATE TABLE schedule_runs (
+    id SERIAL PRIMARY KEY,
+    account_id VARCHAR(64) NOT NULL,
+    period VARCHAR(7) NOT NULL,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    catch_up BOOLEAN NOT NULL DEFAULT false,
+    job_id VARCHAR(36) NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX idx_schedule_runs_account_time ON schedule_runs (account_id, scheduled_for);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE schedule_runs;
+ALTER TABLE jobs DROP COLUMN period;
+-- +goose StatementEnd
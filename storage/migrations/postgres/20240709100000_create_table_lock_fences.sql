@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE lock_fences (
+    key VARCHAR(255) PRIMARY KEY,
+    token BIGINT NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE lock_fences;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SQLite stores NUMERIC values as floating point: amounts are rounded to
+-- cents and bounded as NUMERIC(10, 2) is in Postgres.
+CREATE TABLE transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date VARCHAR(10) NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount > -100000000 AND amount < 100000000)
+);
+CREATE TRIGGER transactions_amount_insert AFTER INSERT ON transactions
+BEGIN
+    UPDATE transactions SET amount = ROUND(NEW.amount, 2) WHERE id = NEW.id;
+END;
+CREATE TRIGGER transactions_amount_update AFTER UPDATE OF amount ON transactions
+BEGIN
+    UPDATE transactions SET amount = ROUND(NEW.amount, 2) WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE transactions;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions ADD COLUMN account_id VARCHAR(64) NOT NULL DEFAULT 'default';
+CREATE INDEX idx_transactions_account_id ON transactions (account_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_transactions_account_id;
+ALTER TABLE transactions DROP COLUMN account_id;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+// Package storage embeds the migrations of the database schema, so that the
+// binary can apply them without the source tree.
+package storage
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// The migrations of each database driver share their versions, so the
+// schema version means the same with every driver.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var embedded embed.FS
+
+// Migrations returns the goose migrations of the database schema for the
+// database driver, postgres or sqlite.
+func Migrations(driver string) (fs.FS, error) {
+	switch driver {
+	case "postgres", "sqlite":
+		return fs.Sub(embedded, "migrations/"+driver)
+	}
+	return nil, fmt.Errorf("no migrations for database driver %q", driver)
+}
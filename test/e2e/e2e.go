@@ -1,73 +1,64 @@
 package e2e
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gavv/httpexpect/v2"
-	"github.com/go-redis/redis/v8"
+	"github.com/jordanlanch/stori-test/internal/app"
 	"github.com/jordanlanch/stori-test/internal/config"
-	"github.com/jordanlanch/stori-test/internal/core/usecase"
-	"github.com/jordanlanch/stori-test/internal/infrastructure/email"
-	"github.com/jordanlanch/stori-test/internal/infrastructure/repository"
-	"github.com/jordanlanch/stori-test/internal/interface/api/controller"
-	"github.com/jordanlanch/stori-test/internal/interface/api/router"
 	"gopkg.in/dnaeon/go-vcr.v3/recorder"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
+// Setup starts the server and returns an expectation builder for it. Requests
+// go through the VCR cassette cassetteName, or straight to the server when it
+// is empty.
 func Setup(t *testing.T, cassetteName string) (expect *httpexpect.Expect, teardown func()) {
 	t.Helper()
 
-	env := config.NewEnv("../.envtest")
+	env, err := config.Load("../.envtest", nil)
+	if err != nil {
+		t.Fatalf("Failed to load the configuration: %v", err)
+	}
 	if err := env.Validate(); err != nil {
 		t.Fatalf("Environment validation failed: %v", err)
 	}
 
-	redisOptions := &redis.Options{
-		Addr: fmt.Sprintf("%s:%d", env.RedisHost, env.RedisPort),
-		DB:   0,
-	}
-
-	if env.RedisPassword != "" {
-		redisOptions.Password = env.RedisPassword
+	// Every test starts with an empty Redis, so that the rate limits and
+	// jobs of the previous ones do not carry over.
+	if env.RedisUsed() {
+		redisClient := app.NewRedisClient(env)
+		err := redisClient.FlushDB(context.Background()).Err()
+		redisClient.Close()
+		if err != nil {
+			t.Fatalf("Failed to flush Redis: %v", err)
+		}
 	}
 
-	redisClient := redis.NewClient(redisOptions)
-
 	// Create new VCR cassette
-	rec, err := recorder.New(cassetteName)
-	if err != nil {
-		log.Fatal(err)
+	var rec *recorder.Recorder
+	httpClient := http.DefaultClient
+	if cassetteName != "" {
+		if rec, err = recorder.New(cassetteName); err != nil {
+			t.Fatalf("Failed to create the cassette recorder: %v", err)
+		}
+		// Use the recorder for all requests
+		httpClient = rec.GetDefaultClient()
 	}
 
-	// Use the recorder for all requests
-	httpClient := rec.GetDefaultClient()
-
-	// Setup Database
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		env.DBHost, env.DBUser, env.DBPassword, env.DBName, env.DBPort)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// The server is wired as by main, the scheduler running until teardown.
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	server, err := app.NewServer(schedulerCtx, env, func() (*config.Env, error) { return config.Load("../.envtest", nil) })
 	if err != nil {
-		t.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	// Setup application components
-	dbRepo := repository.NewDBTransactionRepository(db, env.CSVFilePath)
-	cacheRepo := repository.NewCacheTransactionRepository(redisClient, env.CacheDurationSec)
-	emailService := &email.EmailService{}
-	transactionUseCase := usecase.NewTransactionUseCase(dbRepo, cacheRepo, emailService, redisClient, env.RateLimit, env.RedisTimeoutSec, env.CacheDurationSec)
-	transactionController := &controller.TransactionController{
-		UseCase: transactionUseCase,
+		stopScheduler()
+		t.Fatalf("Failed to start: %v", err)
 	}
 
-	router := router.SetupRouter(transactionController)
-
-	srv := httptest.NewUnstartedServer(router)
+	srv := httptest.NewUnstartedServer(server.Handler)
 	listener, err := net.Listen("tcp", "127.0.0.1:42783")
 	if err != nil {
 		if listener, err = net.Listen("tcp6", "[::1]:0"); err != nil {
@@ -86,8 +77,13 @@ func Setup(t *testing.T, cassetteName string) (expect *httpexpect.Expect, teardo
 	})
 
 	return expect, func() {
-		redisClient.Close()
-		rec.Stop()
 		srv.Close()
+		stopScheduler()
+		if err := server.Close(context.Background()); err != nil {
+			t.Errorf("Failed to close the server: %v", err)
+		}
+		if rec != nil {
+			rec.Stop()
+		}
 	}
 }
@@ -8,11 +8,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jordanlanch/stori-test/internal/config"
 	"github.com/jordanlanch/stori-test/test/e2e"
 )
 
 const (
 	statusOK              = http.StatusOK
+	statusAccepted        = http.StatusAccepted
 	statusTooManyRequests = http.StatusTooManyRequests
 )
 
@@ -54,7 +56,12 @@ func TestProcessTransactionsOK(t *testing.T) {
 	t.Run("Process Transactions OK", func(t *testing.T) {
 		response := expect.POST("/process-transactions").
 			Expect()
-		response.Status(statusOK)
+		response.Status(statusAccepted)
+		jobID := response.JSON().Object().Value("job_id").String().Raw()
+
+		expect.GET("/jobs/" + jobID).
+			Expect().
+			Status(statusOK)
 	})
 }
 
@@ -75,10 +82,40 @@ func TestRateLimitExceeded(t *testing.T) {
 			response := expect.POST("/process-transactions").
 				Expect()
 			if i < 10 {
-				response.Status(statusOK)
+				response.Status(statusAccepted)
 			} else {
 				response.Status(statusTooManyRequests)
 			}
 		}
 	})
 }
+
+func TestHealth(t *testing.T) {
+	// No cassette: the dependencies are checked live.
+	expect, teardown := e2e.Setup(t, "")
+	defer teardown()
+
+	t.Run("Liveness and Readiness", func(t *testing.T) {
+		expect.GET("/healthz").
+			Expect().
+			Status(statusOK)
+
+		dependencies := expect.GET("/readyz").
+			Expect().
+			Status(statusOK).
+			JSON().Object().Value("dependencies").Object()
+		// The database is named after its driver, and Redis is only checked
+		// when something is kept there.
+		env, err := config.Load("../.envtest", nil)
+		if err != nil {
+			t.Fatalf("Failed to load the configuration: %v", err)
+		}
+		names := []string{env.DBDriver, "migrations", "csv_source"}
+		if env.RedisUsed() {
+			names = append(names, "redis")
+		}
+		for _, name := range names {
+			dependencies.Value(name).Object().Value("status").String().IsEqual("up")
+		}
+	})
+}